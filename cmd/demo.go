@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/eventbus"
+	"github.com/jaime1129/fedex/internal/jobs"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+)
+
+// demo data covers the last demoSeedPeriod with a fee every demoSeedInterval,
+// then a new fee is generated every demoLiveInterval so that the stream has
+// something to push
+const (
+	demoSeedPeriod   = 24 * time.Hour
+	demoSeedInterval = 5 * time.Minute
+	demoLiveInterval = 5 * time.Second
+	demoFirstBlock   = 19000000
+)
+
+// demo fees are priced like the live tracker does
+var demoPriceQuery = &repository.PriceQuery{Interval: components.INTERVAL_1MIN}
+
+// demoGenerator produces plausible WETH/USDC fees, it is deterministic so that
+// every demo run serves the same seeded data
+type demoGenerator struct {
+	rnd   *rand.Rand
+	block uint64
+}
+
+func newDemoGenerator() *demoGenerator {
+	return &demoGenerator{rnd: rand.New(rand.NewSource(1)), block: demoFirstBlock}
+}
+
+func (g *demoGenerator) next(trxTime time.Time) repository.UniTrxFee {
+	g.block += uint64(1 + g.rnd.Intn(25))
+	hash := make([]byte, 32)
+	g.rnd.Read(hash)
+	sender := make([]byte, 20)
+	g.rnd.Read(sender)
+
+	fee := repository.UniTrxFee{
+		Symbol:      jobs.WETHUSDC,
+		TrxHash:     fmt.Sprintf("0x%x", hash),
+		TrxTime:     uint64(trxTime.Unix()),
+		GasUsed:     uint64(120000 + g.rnd.Intn(130000)),
+		GasPrice:    util.WeiFromGwei(decimal.NewFromFloat(5 + g.rnd.Float64()*35).Round(3)),
+		BlockNumber: g.block,
+		FromAddress: fmt.Sprintf("0x%x", sender),
+	}
+	price := demoEthPrice(trxTime.Unix()).Add(decimal.NewFromFloat(g.rnd.Float64() * 10))
+	fee.SetPrice(price.Round(2), demoPriceQuery.Source())
+	fee.Status = repository.TrxStatusSuccess
+	if g.rnd.Intn(20) == 0 {
+		fee.Status = repository.TrxStatusFailed
+	}
+	return fee
+}
+
+// seedDemoData fills repo with a day of fees and a pending dead-lettered batch
+func seedDemoData(repo repository.Repository, g *demoGenerator) error {
+	now := time.Now()
+	var fees []repository.UniTrxFee
+	for t := now.Add(-demoSeedPeriod); t.Before(now); t = t.Add(demoSeedInterval) {
+		fees = append(fees, g.next(t))
+	}
+	err := repo.WithTx(context.Background(), func(tx repository.TxRepository) error {
+		if _, err := tx.BatchInsertUniTrxFee(fees, repository.ConflictKeepExisting); err != nil {
+			return err
+		}
+		return tx.RecordMaxBlockNum(jobs.WETHUSDC, g.block)
+	})
+	if err != nil {
+		return err
+	}
+
+	unpriced := g.next(now)
+	return repo.InsertDeadLetter(&repository.DeadLetterBatch{
+		Symbol:     jobs.WETHUSDC,
+		Source:     repository.DeadLetterSourceLive,
+		StartBlock: unpriced.BlockNumber,
+		EndBlock:   unpriced.BlockNumber,
+		Payload: repository.DeadLetterPayload{
+			Fees: []repository.UniTrxFee{unpriced},
+			PriceQuery: &repository.PriceQuery{
+				Start:    int64(unpriced.TrxTime) - 1,
+				End:      int64(unpriced.TrxTime),
+				Interval: "1s",
+			},
+		},
+		Error:       "demo: price query failed",
+		Status:      repository.DeadLetterStatusPending,
+		NextRetryAt: now.Add(time.Hour).Unix(),
+	})
+}
+
+// runDemoFeed inserts a generated fee every demoLiveInterval until ctx is done
+func runDemoFeed(ctx context.Context, repo repository.Repository, bus eventbus.Publisher, g *demoGenerator) {
+	ticker := time.NewTicker(demoLiveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			fees := []repository.UniTrxFee{g.next(now)}
+			if _, err := repo.BatchInsertUniTrxFee(fees, repository.ConflictKeepExisting); err != nil {
+				log.Println("fail to insert demo fee: " + err.Error())
+				continue
+			}
+			bus.Publish(eventbus.FeeIngested{Source: eventbus.SourceLive, Symbol: jobs.WETHUSDC, Fees: fees})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// demoEthPrice is the ETH price in USDT at a unix time in the demo, it drifts
+// around 3000 USDT over the day
+func demoEthPrice(at int64) decimal.Decimal {
+	return decimal.NewFromFloat(3000 + 150*math.Sin(float64(at)/21600))
+}
+
+// demoEthScanCli stands in for etherscan in the demo, which makes no outbound
+// calls: it knows no transaction besides the seeded ones, so looking another
+// one up answers 404
+type demoEthScanCli struct{}
+
+func (demoEthScanCli) QueryTrxFee(string) (*components.EthScanTrxResponse, error) {
+	return &components.EthScanTrxResponse{}, nil
+}
+
+func (demoEthScanCli) QueryBlock(string) (*components.EthScanBlockResponse, error) {
+	return nil, errDemoUpstream
+}
+
+func (demoEthScanCli) GetLatestBlock() (int64, error) {
+	return 0, errDemoUpstream
+}
+
+func (demoEthScanCli) QueryHistoricalTrxs(*components.QueryHistoricalTrxsReq) (*components.QueryHistoricalTrxsResp, error) {
+	return nil, errDemoUpstream
+}
+
+var errDemoUpstream = fmt.Errorf("%w: no etherscan in the demo", components.ErrUpstreamUnavailable)
+
+// demoBnPriceCli stands in for binance in the demo with generated prices: ETH
+// at demoEthPrice and stablecoins at 1 USDT
+type demoBnPriceCli struct{}
+
+func (demoBnPriceCli) QueryETHPrice(start int64, end int64, _ string) (decimal.Decimal, error) {
+	return demoEthPrice(start + (end-start)/2).Round(2), nil
+}
+
+func (demoBnPriceCli) QueryKlines(_ string, start int64, end int64, interval string) ([]components.Kline, error) {
+	step := int64(60)
+	if interval == components.INTERVAL_1DAY {
+		step = 86400
+	}
+	var klines []components.Kline
+	for t := start - start%step; t <= end && len(klines) < 1000; t += step {
+		if t >= start {
+			klines = append(klines, components.Kline{OpenTime: t, Open: decimal.NewFromInt(1), Close: decimal.NewFromInt(1)})
+		}
+	}
+	return klines, nil
+}
+
+// demoTracker stands in for the trackers, which would backfill the whole pool
+// history into memory: the demo feed replaces them, so they are reported as
+// stopped
+type demoTracker struct{}
+
+func (demoTracker) Run() {}
+
+func (demoTracker) Status() jobs.TrackerStatus {
+	return jobs.TrackerStatus{State: jobs.StateStopped, UpdatedAt: time.Now().Unix()}
+}
+
+func (demoTracker) Stop(context.Context) error {
+	return nil
+}
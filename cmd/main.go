@@ -2,19 +2,22 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // fee series timezones don't depend on the host
 
 	"github.com/gin-gonic/gin"
 	"github.com/jaime1129/fedex/config"
 	"github.com/jaime1129/fedex/docs"
 	"github.com/jaime1129/fedex/internal/components"
 	"github.com/jaime1129/fedex/internal/controller"
+	"github.com/jaime1129/fedex/internal/currency"
+	"github.com/jaime1129/fedex/internal/eventbus"
 	"github.com/jaime1129/fedex/internal/jobs"
 	"github.com/jaime1129/fedex/internal/repository"
 	"github.com/jaime1129/fedex/internal/service"
@@ -39,30 +42,88 @@ func main() {
 		log.Fatal(err)
 	}
 
-	ethscanAPIKey := conf.APIKey
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
-		conf.Database.Username,
-		conf.Database.Password,
-		conf.Database.Host,
-		conf.Database.Port,
-		conf.Database.DBName,
-	)
-	ethScanCli := components.NewEthScanCli(ethscanAPIKey)
-	bnPriceCli := components.NewBnPriceCLi()
-
-	repo := repository.NewRepository(dsn)
-	svc := service.NewTrxService(ethScanCli, bnPriceCli, repo)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(conf, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	demo := flag.Bool("demo", false, "run entirely in memory with seeded data, without a database or the trackers")
+	flag.Parse()
+	if conf.Database.AutoMigrate && !*demo {
+		if err := migrateUp(conf); err != nil {
+			log.Fatal("Error migrating the database: ", err)
+		}
+	}
 
-	t := jobs.NewDataTracker(
-		ctx,
-		ethScanCli,
-		bnPriceCli,
-		repo,
+	var (
+		ethScanCli components.EthScanCli
+		bnPriceCli components.BnPriceCli
+		repo       repository.Repository
+	)
+	if *demo {
+		// the demo makes no outbound calls
+		ethScanCli, bnPriceCli = demoEthScanCli{}, demoBnPriceCli{}
+		repo = repository.NewMemoryRepository()
+	} else {
+		ethScanCli = components.NewEthScanCli(conf.APIKey)
+		bnPriceCli = components.NewBnPriceCLi()
+		repo, err = repository.NewRepository(conf.Database.DriverName(), conf.Database.DSN(),
+			repository.WithBulkLoadThreshold(conf.Database.BulkLoadThreshold))
+		if err != nil {
+			log.Fatal("Error connecting to the database: ", err)
+		}
+	}
+	bus := eventbus.New()
+	defer bus.Close()
+	rateTable, err := conf.Currency.RateTable()
+	if err != nil {
+		log.Fatal("Error reading the currency config: ", err)
+	}
+	converter := currency.NewConverter(currency.DefaultSources(bnPriceCli, rateTable))
+	rollupSvc := service.NewRollupService(repo)
+	svc := service.NewTrxService(ethScanCli, bnPriceCli, repo, rollupSvc, service.WithConverter(converter))
+	deadLetterSvc := service.NewDeadLetterService(bnPriceCli, repo, bus)
+	streamSvc := service.NewTrxFeeStreamService(repo, bus)
+
+	var (
+		t jobs.DataTracker
+		// nil in the demo
+		w jobs.DeadLetterWorker
 	)
-	t.Run()
+	if *demo {
+		g := newDemoGenerator()
+		if err = seedDemoData(repo, g); err != nil {
+			log.Fatal("Error seeding demo data: ", err)
+		}
+		feedCtx, stopFeed := context.WithCancel(ctx)
+		defer stopFeed()
+		go runDemoFeed(feedCtx, repo, bus, g)
+		// the demo feed stands in for the trackers, and dead letters are only
+		// retried on demand
+		t = demoTracker{}
+	} else {
+		t = jobs.NewDataTracker(
+			ctx,
+			ethScanCli,
+			bnPriceCli,
+			repo,
+			bus,
+		)
+		t.Run()
+		w = jobs.NewDeadLetterWorker(ctx, deadLetterSvc)
+		w.Run()
+	}
+	rw := jobs.NewRollupWorker(ctx, rollupSvc, bus)
+	rw.Run()
 
 	c := controller.NewTrxController(svc)
-	router := setupRouter(c)
+	c2 := controller.NewTrxV2Controller(svc)
+	tc := controller.NewTrackerController(t)
+	dc := controller.NewDeadLetterController(deadLetterSvc)
+	sc := controller.NewTrxFeeStreamController(streamSvc)
+	ec := controller.NewFeeEstimateController(svc)
+	router := setupRouter(c, c2, sc, ec, tc, dc)
 
 	srv := &http.Server{
 		Addr:    ":8080",
@@ -90,23 +151,67 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
-	{
-		t.Stop()
-		repo.Close()
+	// wait for in-flight batches to be committed before closing the db pool
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer stopCancel()
+	if err := t.Stop(stopCtx); err != nil {
+		log.Println("Data tracker forced to stop:", err)
+	}
+	if w != nil {
+		if err := w.Stop(stopCtx); err != nil {
+			log.Println("Dead letter worker forced to stop:", err)
+		}
+	}
+	if err := rw.Stop(stopCtx); err != nil {
+		log.Println("Rollup worker forced to stop:", err)
 	}
+	repo.Close()
 
-	time.Sleep(5 * time.Second)
 	log.Println("Server exited")
 }
 
-func setupRouter(c controller.TrxFeeController) *gin.Engine {
+func setupRouter(
+	c controller.TrxFeeController,
+	c2 controller.TrxFeeV2Controller,
+	sc controller.TrxFeeStreamController,
+	ec controller.FeeEstimateController,
+	tc controller.TrackerController,
+	dc controller.DeadLetterController,
+) *gin.Engine {
 	r := gin.Default()
-	docs.SwaggerInfo.BasePath = "/api/v1"
+	docs.SwaggerInfo.BasePath = "/api"
 	v1 := r.Group("/api/v1")
 	{
 		trxFee := v1.Group("/trxfee")
 		trxFee.GET(":trx_hash", c.GetSingleTrxFee)
+		trxFee.POST("/batch", c.GetTrxFeeBatch)
 		trxFee.GET("/list", c.GetTrxFeeList)
+		trxFee.GET("/stats", c.GetTrxFeeStats)
+		trxFee.GET("/series", c.GetTrxFeeSeries)
+		trxFee.GET("/stream", sc.StreamTrxFeeSSE)
+		trxFee.GET("/stream/ws", sc.StreamTrxFeeWS)
+
+		fee := v1.Group("/fee")
+		fee.GET("/estimate", ec.EstimateFee)
+
+		tracker := v1.Group("/tracker")
+		tracker.GET("/status", tc.GetStatus)
+
+		deadLetter := v1.Group("/deadletter")
+		deadLetter.GET("/list", dc.ListDeadLetters)
+		deadLetter.POST("/:id/retry", dc.RetryDeadLetter)
+		deadLetter.DELETE("/:id", dc.DiscardDeadLetter)
+	}
+	// v2 serves the fee queries of v1 with responses of their own, not
+	// serializations of the repository models
+	v2 := r.Group("/api/v2")
+	{
+		trxFee := v2.Group("/trxfee")
+		trxFee.GET(":trx_hash", c2.GetSingleTrxFee)
+		trxFee.POST("/batch", c2.GetTrxFeeBatch)
+		trxFee.GET("/list", c2.GetTrxFeeList)
+		trxFee.GET("/stats", c2.GetTrxFeeStats)
+		trxFee.GET("/series", c2.GetTrxFeeSeries)
 	}
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	return r
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jaime1129/fedex/config"
+	"github.com/jaime1129/fedex/internal/migration"
+)
+
+const migrateUsage = `usage: feedex migrate <command> [flags]
+
+commands:
+  up      apply all pending migrations
+  down    revert the last applied migrations, 1 by default
+  status  list migrations and whether they are applied
+
+flags:
+`
+
+// runMigrate implements the `migrate` command, printing to stdout since the
+// standard logger writes to the log file
+func runMigrate(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print the SQL that would be executed without running it")
+	steps := fs.Int("steps", 1, "number of migrations to revert with down")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("missing migrate command")
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	m, err := migration.NewMigrator(conf.Database.DriverName(), conf.Database.DSN())
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch command {
+	case "up":
+		return m.Up(*dryRun, os.Stdout)
+	case "down":
+		return m.Down(*steps, *dryRun, os.Stdout)
+	case "status":
+		return printMigrationStatus(m, os.Stdout)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command %s", command)
+	}
+}
+
+func migrateUp(conf *config.Config) error {
+	m, err := migration.NewMigrator(conf.Database.DriverName(), conf.Database.DSN())
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Up(false, io.Discard)
+}
+
+func printMigrationStatus(m migration.Migrator, out io.Writer) error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		state := "pending"
+		if s.Applied {
+			state = "applied"
+		}
+		fmt.Fprintf(out, "%s\t%s\n", &s.Migration, state)
+	}
+	return nil
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 
+	"github.com/jaime1129/fedex/internal/currency"
+	"github.com/shopspring/decimal"
 	"gopkg.in/yaml.v2"
 )
 
@@ -10,14 +14,86 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	Server   ServerConfig   `yaml:"server"`
 	APIKey   string         `yaml:"apikey"`
+	Currency CurrencyConfig `yaml:"currency"`
 }
 
 type DatabaseConfig struct {
+	// mysql, postgres or sqlite, mysql by default
+	Driver   string `yaml:"driver"`
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 	DBName   string `yaml:"dbname"`
+	// database file used by the sqlite driver
+	Path string `yaml:"path"`
+	// apply pending schema migrations at startup
+	AutoMigrate bool `yaml:"auto_migrate"`
+	// mysql only, batches of at least this many fees are inserted with
+	// LOAD DATA LOCAL INFILE, 0 disables it
+	BulkLoadThreshold int `yaml:"bulk_load_threshold"`
+}
+
+func (c *DatabaseConfig) DriverName() string {
+	if c.Driver == "" {
+		return "mysql"
+	}
+	return c.Driver
+}
+
+func (c *DatabaseConfig) DSN() string {
+	switch c.DriverName() {
+	case "postgres":
+		return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
+			url.PathEscape(c.Username),
+			url.PathEscape(c.Password),
+			c.Host,
+			c.Port,
+			c.DBName,
+		)
+	case "sqlite":
+		// wait for locks instead of failing when the migrator and the app share the file
+		return "file:" + c.Path + "?_pragma=busy_timeout(5000)"
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
+			c.Username,
+			c.Password,
+			c.Host,
+			c.Port,
+			c.DBName,
+		)
+	}
+}
+
+type CurrencyConfig struct {
+	// local rate table of the currencies without a market to read rates from,
+	// like EUR and GBP, by currency
+	FiatRates map[string][]FiatRate `yaml:"fiat_rates"`
+}
+
+type FiatRate struct {
+	// unix timestamp the rate applies from, until the next one
+	Since int64 `yaml:"since"`
+	// units of the currency one USDT is worth
+	Rate string `yaml:"rate"`
+}
+
+// RateTable returns the fiat rates as the table of a currency.NewTableSource
+func (c *CurrencyConfig) RateTable() (map[string][]currency.TableRate, error) {
+	table := make(map[string][]currency.TableRate, len(c.FiatRates))
+	for cur, rates := range c.FiatRates {
+		if !currency.IsSupported(cur) {
+			return nil, fmt.Errorf("unsupported currency %s in fiat_rates", cur)
+		}
+		for _, r := range rates {
+			rate, err := decimal.NewFromString(r.Rate)
+			if err != nil || !rate.IsPositive() {
+				return nil, fmt.Errorf("invalid %s rate %q in fiat_rates", cur, r.Rate)
+			}
+			table[cur] = append(table[cur], currency.TableRate{Since: r.Since, Rate: rate})
+		}
+	}
+	return table, nil
 }
 
 type ServerConfig struct {
@@ -15,143 +15,2350 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/trxfee/list": {
+        "/v1/deadletter/list": {
             "get": {
-                "description": "get trx fee by given time period",
-                "consumes": [
+                "description": "list ingestion batches that failed to be priced or persisted",
+                "produces": [
                     "application/json"
                 ],
+                "summary": "List dead-lettered batches",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "exhausted",
+                            "resolved",
+                            "discarded"
+                        ],
+                        "type": "string",
+                        "description": "all by default",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page starting from 0",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "between 1 and 100, 20 by default",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ListDeadLettersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: unknown status, malformed or out of bounds page or limit",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/deadletter/{id}": {
+            "delete": {
+                "description": "mark a dead-lettered batch as discarded so that it is never retried",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get a list of trx fee",
+                "summary": "Discard a dead-lettered batch",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "symbol",
-                        "name": "symbol",
-                        "in": "query",
+                        "type": "integer",
+                        "description": "dead letter batch id",
+                        "name": "id",
+                        "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.DeadLetterBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "not_found",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
                     },
+                    "409": {
+                        "description": "conflict: the batch is already resolved or discarded",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/deadletter/{id}/retry": {
+            "post": {
+                "description": "replay a dead-lettered batch now, regardless of its backoff",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Retry a dead-lettered batch",
+                "parameters": [
                     {
                         "type": "integer",
-                        "description": "start timestamp",
-                        "name": "start_time",
-                        "in": "query",
+                        "description": "dead letter batch id",
+                        "name": "id",
+                        "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.DeadLetterBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "not_found",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
                     },
+                    "409": {
+                        "description": "conflict: the batch is already resolved or discarded",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/fee/estimate": {
+            "get": {
+                "description": "get what gas_used gas would have cost at timestamp, with the given gas price or a percentile of the gas prices of the fees of the window before timestamp, priced like fees looked up on etherscan and binance",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Estimate the fee of gas at a time",
+                "parameters": [
                     {
                         "type": "integer",
-                        "description": "end timestamp",
-                        "name": "end_time",
+                        "description": "gas units",
+                        "name": "gas_used",
                         "in": "query",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "gas price in gwei, required without gas_price_percentile",
+                        "name": "gas_price",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "page starting from 0",
-                        "name": "page",
-                        "in": "query",
-                        "required": true
+                        "description": "nearest-rank percentile, between 1 and 100, of the observed gas prices, required without gas_price",
+                        "name": "gas_price_percentile",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "symbol of the fees gas prices are observed from, WETH/USDC by default",
+                        "name": "symbol",
+                        "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "20 by default",
-                        "name": "limit",
+                        "description": "seconds before timestamp gas prices are observed over, between 1 and 86400, 3600 by default",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "unix timestamp, not in the future",
+                        "name": "timestamp",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fee to, besides USD and ETH",
+                        "name": "currency",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/service.GetTrxFeeListResponse"
+                            "$ref": "#/definitions/service.EstimateFeeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed or out of bounds parameter, both or none of gas_price and gas_price_percentile, future timestamp, no fee in the window or no rate of the currency at timestamp",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited: binance rate limited the price query",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "upstream_error: binance failed the price query",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "unavailable: binance could not be reached",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/trxfee/{trx_hash}": {
+        "/v1/tracker/status": {
             "get": {
-                "description": "get trx fee by trx hash",
+                "description": "get the state of the data tracker: initializing, running, degraded or stopped",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get data tracker status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.TrackerStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/trxfee/batch": {
+            "post": {
+                "description": "get trx fees of up to 100 trx hashes, stored ones are served from the database and the others looked up concurrently; each result has the status and error the single query of its hash would have responded with",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get trx fee of single trx",
+                "summary": "Get trx fees of several trxs",
                 "parameters": [
                     {
+                        "description": "trx hashes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controller.trxFeeBatchBody"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
                         "type": "string",
-                        "description": "trx hash",
-                        "name": "trx_hash",
-                        "in": "path",
-                        "required": true
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.TrxFeeBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: no or more than 100 trx hashes or unsupported currency",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "internal",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/controller.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "repository.UniTrxFee": {
-            "type": "object",
-            "properties": {
-                "blockNumber": {
-                    "type": "integer"
-                },
-                "ethUsdtPrice": {
-                    "type": "number"
-                },
-                "gasPrice": {
-                    "type": "integer"
-                },
-                "gasUsed": {
-                    "type": "integer"
-                },
-                "symbol": {
-                    "type": "string"
-                },
-                "trxFeeUsdt": {
-                    "type": "number"
-                },
-                "trxHash": {
-                    "type": "string"
-                },
-                "trxTime": {
-                    "type": "integer"
-                }
-            }
         },
-        "service.GetTrxFeeListResponse": {
-            "type": "object",
-            "properties": {
-                "result": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/repository.UniTrxFee"
+        "/v1/trxfee/list": {
+            "get": {
+                "description": "get trx fee by given time period and filters, ordered by the sort field then id; pass next_cursor as cursor to get the next page",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a list of trx fee",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, WETH/USDC by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "start timestamp",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "end timestamp, now by default",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "minimum block number",
+                        "name": "min_block",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "maximum block number",
+                        "name": "max_block",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum fee in USDT",
+                        "name": "min_fee_usdt",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "maximum fee in USDT",
+                        "name": "max_fee_usdt",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum fee in ETH",
+                        "name": "min_fee_eth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "maximum fee in ETH",
+                        "name": "max_fee_eth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum gas price in wei",
+                        "name": "min_gas_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "maximum gas price in wei",
+                        "name": "max_gas_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "minimum gas used",
+                        "name": "min_gas_used",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "maximum gas used",
+                        "name": "max_gas_used",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sender address",
+                        "name": "address",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "success",
+                            "failed",
+                            "unknown"
+                        ],
+                        "type": "string",
+                        "description": "transaction status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "source of the ETH price, like binance_1m or binance_12h",
+                        "name": "price_source",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "trx_time",
+                            "block_num",
+                            "gas_used",
+                            "gas_price",
+                            "trx_fee_eth",
+                            "trx_fee_usdt"
+                        ],
+                        "type": "string",
+                        "description": "trx_time by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "asc by default",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page, with the same filters, sort and order",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "between 1 and 50, 20 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "also count every matching trx",
+                        "name": "include_total",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
                     }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/apiv1.TrxFeeList"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed or out of bounds parameter, unknown symbol, start_time after end_time, cursor of other parameters or no rate of the currency at a trx time",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/trxfee/series": {
+            "get": {
+                "description": "get count, sum, min, max and mean of trx fees in USDT and ETH, with the average gas price and gas used, for every bucket of the given time period, including empty ones",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a time series of trx fee statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, WETH/USDC by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "start timestamp",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "end timestamp, now by default",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "minute",
+                            "hour",
+                            "day",
+                            "week"
+                        ],
+                        "type": "string",
+                        "description": "bucket size, hour by default, at most 1000 buckets",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone buckets are aligned in, UTC by default",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.GetTrxFeeSeriesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed timestamp, unknown symbol, interval or timezone, start_time after end_time, too many buckets or no rate of the currency in the time range",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/trxfee/stats": {
+            "get": {
+                "description": "get count, sum, min, max, mean, median, p90 and p99 of trx fees in USDT and ETH, with the average gas price and gas used, in the given time period",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get trx fee statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, WETH/USDC by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "start timestamp",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "end timestamp, now by default",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.GetTrxFeeStatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed timestamp, unknown symbol, start_time after end_time or no rate of the currency in the time range",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/trxfee/stream": {
+            "get": {
+                "description": "push each trx fee as it is committed; reconnecting clients resume after the Last-Event-ID header and may receive the fees of the 5 minutes before it again",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream newly ingested trx fees over SSE",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, all by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum fee in USDT",
+                        "name": "min_fee",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sender address",
+                        "name": "address",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "resume after this event id, overridden by the Last-Event-ID header",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/apiv1.StreamedTrxFee"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: unknown symbol, malformed min_fee or last event id",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/trxfee/stream/ws": {
+            "get": {
+                "description": "upgrade to a WebSocket that receives each trx fee as a JSON message as it is committed",
+                "summary": "Stream newly ingested trx fees over WebSocket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, all by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum fee in USDT",
+                        "name": "min_fee",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sender address",
+                        "name": "address",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "resume after this event id, the fees of the 5 minutes before it may be received again",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/apiv1.StreamedTrxFee"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: unknown symbol, malformed min_fee or last event id",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/trxfee/{trx_hash}": {
+            "get": {
+                "description": "get trx fee by trx hash with its gas, block, ETH price and how it was computed, whether it was stored or looked up on etherscan and binance, and the tracked pools the trx touched",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get trx fee of single trx",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "trx hash, 0x followed by 64 hex digits",
+                        "name": "trx_hash",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fee to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.GetSingleTrxFeeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed trx hash, unsupported currency or no rate of the currency at the trx time",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "not_found: etherscan has no receipt for the trx",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited: etherscan or binance rate limited the lookup",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "upstream_error: etherscan or binance failed the lookup",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "unavailable: etherscan or binance could not be reached",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/trxfee/batch": {
+            "post": {
+                "description": "get the fees of up to 100 trx hashes, stored ones are served from the database and the others looked up concurrently; each result has the status and error the single query of its hash would have responded with",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get trx fees of several trxs",
+                "parameters": [
+                    {
+                        "description": "trx hashes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controller.trxFeeBatchBody"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/apiv2.TrxFeeBatch"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: no or more than 100 trx hashes or unsupported currency",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/trxfee/list": {
+            "get": {
+                "description": "get trx fees by given time period and filters, ordered by the sort field then id; pass next_cursor as cursor to get the next page",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a list of trx fee",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, WETH/USDC by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "start timestamp",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "end timestamp, now by default",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "minimum block number",
+                        "name": "min_block",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "maximum block number",
+                        "name": "max_block",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum fee in USDT",
+                        "name": "min_fee_usdt",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "maximum fee in USDT",
+                        "name": "max_fee_usdt",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum fee in ETH",
+                        "name": "min_fee_eth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "maximum fee in ETH",
+                        "name": "max_fee_eth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "minimum gas price in wei",
+                        "name": "min_gas_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "maximum gas price in wei",
+                        "name": "max_gas_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "minimum gas used",
+                        "name": "min_gas_used",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "maximum gas used",
+                        "name": "max_gas_used",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sender address",
+                        "name": "address",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "success",
+                            "failed",
+                            "unknown"
+                        ],
+                        "type": "string",
+                        "description": "transaction status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "source of the ETH price, like binance_1m or binance_12h",
+                        "name": "price_source",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "trx_time",
+                            "block_num",
+                            "gas_used",
+                            "gas_price",
+                            "trx_fee_eth",
+                            "trx_fee_usdt"
+                        ],
+                        "type": "string",
+                        "description": "trx_time by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "asc by default",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page, with the same filters, sort and order",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "between 1 and 50, 20 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "also count every matching trx",
+                        "name": "include_total",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/apiv2.TrxFeeList"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed or out of bounds parameter, unknown symbol, start_time after end_time, cursor of other parameters or no rate of the currency at a trx time",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/trxfee/series": {
+            "get": {
+                "description": "get count, sum, min, max and mean of trx fees in USDT and ETH, with the average gas price and gas used, for every bucket of the given time period, including empty ones",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a time series of trx fee statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, WETH/USDC by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "start timestamp",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "end timestamp, now by default",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "minute",
+                            "hour",
+                            "day",
+                            "week"
+                        ],
+                        "type": "string",
+                        "description": "bucket size, hour by default, at most 1000 buckets",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone buckets are aligned in, UTC by default",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/apiv2.TrxFeeSeries"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed timestamp, unknown symbol, interval or timezone, start_time after end_time, too many buckets or no rate of the currency in the time range",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/trxfee/stats": {
+            "get": {
+                "description": "get count, sum, min, max, mean, median, p90 and p99 of trx fees in USDT and ETH, with the average gas price and gas used, in the given time period",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get trx fee statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "symbol, WETH/USDC by default",
+                        "name": "symbol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "start timestamp",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "end timestamp, now by default",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fees to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/apiv2.TrxFeeStats"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed timestamp, unknown symbol, start_time after end_time or no rate of the currency in the time range",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/trxfee/{trx_hash}": {
+            "get": {
+                "description": "get the fee of a trx with how it was priced and the tracked pools it touched, looking it up on etherscan and binance if it isn't stored",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get trx fee of single trx",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "trx hash, 0x followed by 64 hex digits",
+                        "name": "trx_hash",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "USDT",
+                            "USDC",
+                            "DAI",
+                            "EUR",
+                            "GBP"
+                        ],
+                        "type": "string",
+                        "description": "currency to also convert the fee to, besides USDT and ETH",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/apiv2.TrxFeeDetail"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_argument: malformed trx hash, unsupported currency or no rate of the currency at the trx time",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "not_found: etherscan has no receipt for the trx",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited: etherscan or binance rate limited the lookup",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "upstream_error: etherscan or binance failed the lookup",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "unavailable: etherscan or binance could not be reached",
+                        "schema": {
+                            "$ref": "#/definitions/controller.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apiv1.StreamedTrxFee": {
+            "type": "object",
+            "properties": {
+                "BlockNumber": {
+                    "type": "integer"
+                },
+                "EthUsdtPrice": {
+                    "type": "string"
+                },
+                "FromAddress": {
+                    "type": "string"
+                },
+                "GasPrice": {
+                    "type": "integer"
+                },
+                "GasUsed": {
+                    "type": "integer"
+                },
+                "ID": {
+                    "type": "integer"
+                },
+                "Symbol": {
+                    "type": "string"
+                },
+                "TrxFeeUsdt": {
+                    "type": "string"
+                },
+                "TrxHash": {
+                    "type": "string"
+                },
+                "TrxTime": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apiv1.TrxFee": {
+            "type": "object",
+            "properties": {
+                "BlockNumber": {
+                    "type": "integer"
+                },
+                "EthUsdtPrice": {
+                    "description": "decimal strings",
+                    "type": "string"
+                },
+                "GasPrice": {
+                    "type": "integer"
+                },
+                "GasUsed": {
+                    "type": "integer"
+                },
+                "Symbol": {
+                    "type": "string"
+                },
+                "TrxFeeUsdt": {
+                    "type": "string"
+                },
+                "TrxHash": {
+                    "type": "string"
+                },
+                "TrxTime": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apiv1.TrxFeeList": {
+            "type": "object",
+            "properties": {
+                "Result": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiv1.TrxFee"
+                    }
+                },
+                "conversions": {
+                    "description": "the fees of Result in the requested currency, in the same order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.FeeConversion"
+                    }
+                },
+                "next_cursor": {
+                    "description": "opaque cursor of the next page, absent on the last page",
+                    "type": "string"
+                },
+                "total": {
+                    "description": "only if requested",
+                    "type": "integer"
+                }
+            }
+        },
+        "apiv2.Error": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "msg": {
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.FeeConversion": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
+                    "type": "string"
+                },
+                "usdt_rate": {
+                    "description": "units of the currency one USDT was worth at the trx time",
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.FeeDistribution": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "string"
+                },
+                "mean": {
+                    "type": "string"
+                },
+                "median": {
+                    "type": "string"
+                },
+                "min": {
+                    "type": "string"
+                },
+                "p90": {
+                    "type": "string"
+                },
+                "p99": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.FeeDistributionConversion": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
+                    "$ref": "#/definitions/apiv2.FeeDistribution"
+                },
+                "usdt_rate": {
+                    "description": "units of the currency one USDT was worth in the middle of the time range",
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.FeeSummary": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "string"
+                },
+                "mean": {
+                    "type": "string"
+                },
+                "min": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.FeeSummaryConversion": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
+                    "$ref": "#/definitions/apiv2.FeeSummary"
+                },
+                "usdt_rate": {
+                    "description": "units of the currency one USDT was worth in the middle of the bucket",
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.Pool": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.Timestamp": {
+            "type": "object",
+            "properties": {
+                "rfc3339": {
+                    "type": "string"
+                },
+                "unix": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apiv2.TrxFee": {
+            "type": "object",
+            "properties": {
+                "block_number": {
+                    "type": "integer"
+                },
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apiv2.FeeConversion"
+                        }
+                    ]
+                },
+                "eth_usdt_price": {
+                    "description": "ETH price in USDT the fee was converted with",
+                    "type": "string"
+                },
+                "fee_eth": {
+                    "type": "string"
+                },
+                "fee_usdt": {
+                    "type": "string"
+                },
+                "fee_wei": {
+                    "type": "string"
+                },
+                "from_address": {
+                    "type": "string"
+                },
+                "gas_price": {
+                    "description": "in wei",
+                    "type": "string"
+                },
+                "gas_used": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "price_source": {
+                    "description": "binance kline interval the ETH price was averaged from, like binance_1m",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "success, failed or unknown",
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                },
+                "trx_hash": {
+                    "type": "string"
+                },
+                "trx_time": {
+                    "$ref": "#/definitions/apiv2.Timestamp"
+                },
+                "updated_at": {
+                    "$ref": "#/definitions/apiv2.Timestamp"
+                },
+                "version": {
+                    "description": "incremented every time the fee is overwritten, starting at 1",
+                    "type": "integer"
+                }
+            }
+        },
+        "apiv2.TrxFeeBatch": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "description": "in the order of the requested hashes",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiv2.TrxFeeBatchResult"
+                    }
+                }
+            }
+        },
+        "apiv2.TrxFeeBatchResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "only with other statuses",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apiv2.Error"
+                        }
+                    ]
+                },
+                "fee": {
+                    "description": "only with status 200",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apiv2.TrxFee"
+                        }
+                    ]
+                },
+                "status": {
+                    "description": "status code the single query of the hash would have responded with",
+                    "type": "integer"
+                },
+                "trx_hash": {
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.TrxFeeDetail": {
+            "type": "object",
+            "properties": {
+                "block_number": {
+                    "type": "integer"
+                },
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apiv2.FeeConversion"
+                        }
+                    ]
+                },
+                "data_source": {
+                    "description": "database if the fee was stored, live if it was computed from etherscan and binance for the query",
+                    "type": "string",
+                    "enum": [
+                        "database",
+                        "live"
+                    ]
+                },
+                "eth_usdt_price": {
+                    "description": "ETH price in USDT the fee was converted with",
+                    "type": "string"
+                },
+                "fee_eth": {
+                    "type": "string"
+                },
+                "fee_usdt": {
+                    "type": "string"
+                },
+                "fee_wei": {
+                    "type": "string"
+                },
+                "from_address": {
+                    "type": "string"
+                },
+                "gas_price": {
+                    "description": "in wei",
+                    "type": "string"
+                },
+                "gas_used": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "pools": {
+                    "description": "tracked pools the trx touched",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiv2.Pool"
+                    }
+                },
+                "price_method": {
+                    "description": "how the ETH price was computed from its source",
+                    "type": "string"
+                },
+                "price_source": {
+                    "description": "binance kline interval the ETH price was averaged from, like binance_1m",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "success, failed or unknown",
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                },
+                "trx_hash": {
+                    "type": "string"
+                },
+                "trx_time": {
+                    "$ref": "#/definitions/apiv2.Timestamp"
+                },
+                "updated_at": {
+                    "$ref": "#/definitions/apiv2.Timestamp"
+                },
+                "version": {
+                    "description": "incremented every time the fee is overwritten, starting at 1",
+                    "type": "integer"
+                }
+            }
+        },
+        "apiv2.TrxFeeList": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiv2.TrxFee"
+                    }
+                },
+                "next_cursor": {
+                    "description": "opaque cursor of the next page, absent on the last page",
+                    "type": "string"
+                },
+                "total": {
+                    "description": "only if requested",
+                    "type": "integer"
+                }
+            }
+        },
+        "apiv2.TrxFeeSeries": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "description": "every bucket of the time range, including empty ones",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiv2.TrxFeeSeriesBucket"
+                    }
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "type": "string"
+                }
+            }
+        },
+        "apiv2.TrxFeeSeriesBucket": {
+            "type": "object",
+            "properties": {
+                "avg_gas_price": {
+                    "description": "in wei",
+                    "type": "string"
+                },
+                "avg_gas_used": {
+                    "type": "string"
+                },
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apiv2.FeeSummaryConversion"
+                        }
+                    ]
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "fee_eth": {
+                    "$ref": "#/definitions/apiv2.FeeSummary"
+                },
+                "fee_usdt": {
+                    "$ref": "#/definitions/apiv2.FeeSummary"
+                },
+                "start": {
+                    "description": "in the requested timezone",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apiv2.Timestamp"
+                        }
+                    ]
+                }
+            }
+        },
+        "apiv2.TrxFeeStats": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "description": "percentiles are estimated from rollups, within 1%",
+                    "type": "boolean"
+                },
+                "avg_gas_price": {
+                    "description": "in wei",
+                    "type": "string"
+                },
+                "avg_gas_used": {
+                    "type": "string"
+                },
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apiv2.FeeDistributionConversion"
+                        }
+                    ]
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "end_time": {
+                    "$ref": "#/definitions/apiv2.Timestamp"
+                },
+                "fee_eth": {
+                    "$ref": "#/definitions/apiv2.FeeDistribution"
+                },
+                "fee_usdt": {
+                    "$ref": "#/definitions/apiv2.FeeDistribution"
+                },
+                "start_time": {
+                    "$ref": "#/definitions/apiv2.Timestamp"
+                },
+                "symbol": {
+                    "type": "string"
+                }
+            }
+        },
+        "components.Pool": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "lower case",
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "invalid_argument",
+                "not_found",
+                "conflict",
+                "rate_limited",
+                "upstream_error",
+                "unavailable",
+                "internal"
+            ],
+            "x-enum-varnames": [
+                "CodeInvalidArgument",
+                "CodeNotFound",
+                "CodeConflict",
+                "CodeRateLimited",
+                "CodeUpstreamError",
+                "CodeUnavailable",
+                "CodeInternal"
+            ]
+        },
+        "controller.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "enum": [
+                        "invalid_argument",
+                        "not_found",
+                        "conflict",
+                        "rate_limited",
+                        "upstream_error",
+                        "unavailable",
+                        "internal"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/controller.ErrorCode"
+                        }
+                    ]
+                },
+                "field": {
+                    "description": "the request parameter at fault, only for some invalid_argument errors",
+                    "type": "string"
+                },
+                "msg": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.TrxFeeBatchResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "description": "in the order of the requested hashes",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controller.TrxFeeBatchResult"
+                    }
+                }
+            }
+        },
+        "controller.TrxFeeBatchResult": {
+            "type": "object",
+            "properties": {
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.FeeConversion"
+                        }
+                    ]
+                },
+                "error": {
+                    "$ref": "#/definitions/controller.ErrorResponse"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "trx_fee": {
+                    "type": "string"
+                },
+                "trx_hash": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.trxFeeBatchBody": {
+            "type": "object",
+            "required": [
+                "trx_hashes"
+            ],
+            "properties": {
+                "trx_hashes": {
+                    "description": "hashes are validated one by one, so that a malformed one only fails its own result",
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "jobs.TrackerState": {
+            "type": "string",
+            "enum": [
+                "initializing",
+                "running",
+                "degraded",
+                "stopped"
+            ],
+            "x-enum-varnames": [
+                "StateInitializing",
+                "StateRunning",
+                "StateDegraded",
+                "StateStopped"
+            ]
+        },
+        "jobs.TrackerStatus": {
+            "type": "object",
+            "properties": {
+                "init_attempts": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "latest_block": {
+                    "type": "integer"
+                },
+                "state": {
+                    "$ref": "#/definitions/jobs.TrackerState"
+                },
+                "updated_at": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.DeadLetterBatch": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "end_block": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "needs_price": {
+                    "type": "boolean"
+                },
+                "next_retry_at": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "start_block": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                },
+                "trx_count": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.EstimateFeeResponse": {
+            "type": "object",
+            "properties": {
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.FeeConversion"
+                        }
+                    ]
+                },
+                "eth_usdt_price": {
+                    "description": "ETH price in USDT the fee was converted with",
+                    "type": "string"
+                },
+                "fee_eth": {
+                    "type": "string"
+                },
+                "fee_usd": {
+                    "description": "in USDT, the USD stablecoin fees are priced in",
+                    "type": "string"
+                },
+                "fee_wei": {
+                    "type": "string"
+                },
+                "gas_price": {
+                    "description": "in wei, the requested one or the percentile of the observed ones",
+                    "type": "string"
+                },
+                "gas_used": {
+                    "type": "integer"
+                },
+                "observed_gas_prices": {
+                    "description": "only if the gas price is a percentile of the observed ones",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.ObservedGasPrices"
+                        }
+                    ]
+                },
+                "price_method": {
+                    "description": "how the price was computed from its source",
+                    "type": "string"
+                },
+                "price_source": {
+                    "description": "like binance_1m",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.FeeConversion": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
+                    "type": "string"
+                },
+                "usdt_rate": {
+                    "description": "units of the currency one USDT was worth at the trx time",
+                    "type": "string"
+                }
+            }
+        },
+        "service.FeeDistribution": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "string"
+                },
+                "mean": {
+                    "type": "string"
+                },
+                "median": {
+                    "type": "string"
+                },
+                "min": {
+                    "type": "string"
+                },
+                "p90": {
+                    "type": "string"
+                },
+                "p99": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.FeeDistributionConversion": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
+                    "$ref": "#/definitions/service.FeeDistribution"
+                },
+                "usdt_rate": {
+                    "description": "units of the currency one USDT was worth in the middle of the time range",
+                    "type": "string"
+                }
+            }
+        },
+        "service.FeeSeriesBucket": {
+            "type": "object",
+            "properties": {
+                "avg_gas_price": {
+                    "description": "in wei",
+                    "type": "string"
+                },
+                "avg_gas_used": {
+                    "type": "string"
+                },
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.FeeSummaryConversion"
+                        }
+                    ]
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "fee_eth": {
+                    "$ref": "#/definitions/service.FeeSummary"
+                },
+                "fee_usdt": {
+                    "$ref": "#/definitions/service.FeeSummary"
+                },
+                "start": {
+                    "description": "unix timestamp of the bucket start",
+                    "type": "integer"
+                },
+                "time": {
+                    "description": "bucket start in RFC 3339, in the requested timezone",
+                    "type": "string"
+                }
+            }
+        },
+        "service.FeeSummary": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "string"
+                },
+                "mean": {
+                    "type": "string"
+                },
+                "min": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.FeeSummaryConversion": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
+                    "$ref": "#/definitions/service.FeeSummary"
+                },
+                "usdt_rate": {
+                    "description": "units of the currency one USDT was worth in the middle of the bucket",
+                    "type": "string"
+                }
+            }
+        },
+        "service.GetSingleTrxFeeResponse": {
+            "type": "object",
+            "properties": {
+                "block_number": {
+                    "type": "integer"
+                },
+                "block_time": {
+                    "description": "unix timestamp of the block",
+                    "type": "integer"
+                },
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.FeeConversion"
+                        }
+                    ]
+                },
+                "data_source": {
+                    "description": "DataSourceDatabase or DataSourceLive",
+                    "type": "string",
+                    "enum": [
+                        "database",
+                        "live"
+                    ]
+                },
+                "effective_gas_price": {
+                    "type": "string"
+                },
+                "eth_usdt_price": {
+                    "description": "ETH price in USDT the fee was converted with",
+                    "type": "string"
+                },
+                "gas_used": {
+                    "type": "integer"
+                },
+                "pools": {
+                    "description": "tracked pools the trx touched, those emitting its logs for a live lookup\nand the pool of its symbol for a stored fee",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/components.Pool"
+                    }
+                },
+                "price_method": {
+                    "description": "how the price was computed from its source",
+                    "type": "string"
+                },
+                "price_source": {
+                    "description": "like binance_1m",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                },
+                "trx_fee": {
+                    "description": "in USDT, same as trx_fee_usdt",
+                    "type": "string"
+                },
+                "trx_fee_eth": {
+                    "type": "string"
+                },
+                "trx_fee_usdt": {
+                    "type": "string"
+                },
+                "trx_fee_wei": {
+                    "type": "string"
+                },
+                "trx_hash": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.GetTrxFeeSeriesResponse": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "description": "every bucket of the time range, including empty ones",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.FeeSeriesBucket"
+                    }
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.GetTrxFeeStatsResponse": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "description": "percentiles are estimated from rollups, within 1%",
+                    "type": "boolean"
+                },
+                "avg_gas_price": {
+                    "description": "in wei",
+                    "type": "string"
+                },
+                "avg_gas_used": {
+                    "type": "string"
+                },
+                "conversion": {
+                    "description": "only if a currency is requested",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.FeeDistributionConversion"
+                        }
+                    ]
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "fee_eth": {
+                    "$ref": "#/definitions/service.FeeDistribution"
+                },
+                "fee_usdt": {
+                    "$ref": "#/definitions/service.FeeDistribution"
+                }
+            }
+        },
+        "service.ListDeadLettersResponse": {
+            "type": "object",
+            "properties": {
+                "result": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.DeadLetterBatch"
+                    }
+                }
+            }
+        },
+        "service.ObservedGasPrices": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "number of fees in the period",
+                    "type": "integer"
+                },
+                "end_time": {
+                    "type": "integer"
+                },
+                "percentile": {
+                    "type": "integer"
+                },
+                "start_time": {
+                    "type": "integer"
+                },
+                "symbol": {
+                    "type": "string"
                 }
             }
         }
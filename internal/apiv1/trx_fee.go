@@ -0,0 +1,103 @@
+// Package apiv1 holds the fee models of /api/v1 as they were first released,
+// with Go field names and the number and decimal string encodings of the
+// original repository model. They are frozen: the repository model may change,
+// these don't, and new fields go to /api/v2.
+package apiv1
+
+import (
+	"math"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/service"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+)
+
+// TrxFee is the fee of the list, tagged with the field names it was first
+// encoded with
+type TrxFee struct {
+	Symbol      string `json:"Symbol"`
+	TrxHash     string `json:"TrxHash"`
+	TrxTime     uint64 `json:"TrxTime"`
+	GasUsed     uint64 `json:"GasUsed"`
+	GasPrice    uint64 `json:"GasPrice"`
+	BlockNumber uint64 `json:"BlockNumber"`
+	// decimal strings
+	EthUsdtPrice decimal.Decimal `json:"EthUsdtPrice" swaggertype:"string"`
+	TrxFeeUsdt   decimal.Decimal `json:"TrxFeeUsdt" swaggertype:"string"`
+}
+
+func NewTrxFee(fee *repository.UniTrxFee) TrxFee {
+	return TrxFee{
+		Symbol:       fee.Symbol,
+		TrxHash:      fee.TrxHash,
+		TrxTime:      fee.TrxTime,
+		GasUsed:      fee.GasUsed,
+		GasPrice:     weiUint64(fee.GasPrice),
+		BlockNumber:  fee.BlockNumber,
+		EthUsdtPrice: fee.EthUsdtPrice,
+		TrxFeeUsdt:   fee.TrxFeeUsdt,
+	}
+}
+
+// weiUint64 returns the amount as v1 typed it. Gas prices fit in 64 bits, a
+// larger amount is clamped rather than wrapped around.
+func weiUint64(w util.Wei) uint64 {
+	v := w.BigInt()
+	if !v.IsUint64() {
+		return math.MaxUint64
+	}
+	return v.Uint64()
+}
+
+type TrxFeeList struct {
+	Result []TrxFee `json:"Result"`
+	// opaque cursor of the next page, absent on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	// only if requested
+	Total *int64 `json:"total,omitempty"`
+	// the fees of Result in the requested currency, in the same order
+	Conversions []service.FeeConversion `json:"conversions,omitempty"`
+}
+
+func NewTrxFeeList(resp *service.GetTrxFeeListResponse) TrxFeeList {
+	list := TrxFeeList{NextCursor: resp.NextCursor, Total: resp.Total, Conversions: resp.Conversions}
+	// an empty page has always been a null Result
+	if len(resp.Result) > 0 {
+		list.Result = make([]TrxFee, len(resp.Result))
+		for i := range resp.Result {
+			list.Result[i] = NewTrxFee(&resp.Result[i])
+		}
+	}
+	return list
+}
+
+// StreamedTrxFee is the fee pushed by the streams, which were released with the
+// id and sender of the fee besides the fields of the list
+type StreamedTrxFee struct {
+	ID           uint64          `json:"ID"`
+	Symbol       string          `json:"Symbol"`
+	TrxHash      string          `json:"TrxHash"`
+	TrxTime      uint64          `json:"TrxTime"`
+	GasUsed      uint64          `json:"GasUsed"`
+	GasPrice     uint64          `json:"GasPrice"`
+	BlockNumber  uint64          `json:"BlockNumber"`
+	FromAddress  string          `json:"FromAddress"`
+	EthUsdtPrice decimal.Decimal `json:"EthUsdtPrice" swaggertype:"string"`
+	TrxFeeUsdt   decimal.Decimal `json:"TrxFeeUsdt" swaggertype:"string"`
+}
+
+func NewStreamedTrxFee(fee *repository.UniTrxFee) StreamedTrxFee {
+	return StreamedTrxFee{
+		ID:           fee.ID,
+		Symbol:       fee.Symbol,
+		TrxHash:      fee.TrxHash,
+		TrxTime:      fee.TrxTime,
+		GasUsed:      fee.GasUsed,
+		GasPrice:     weiUint64(fee.GasPrice),
+		BlockNumber:  fee.BlockNumber,
+		FromAddress:  fee.FromAddress,
+		EthUsdtPrice: fee.EthUsdtPrice,
+		TrxFeeUsdt:   fee.TrxFeeUsdt,
+	}
+}
@@ -0,0 +1,107 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/service"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trxFeeListGolden is the list as the first v1 release encoded it, byte for
+// byte: changing it breaks v1 clients
+const trxFeeListGolden = `{
+	"Result": [
+		{
+			"Symbol": "WETH/USDC",
+			"TrxHash": "0xabc",
+			"TrxTime": 1700000000,
+			"GasUsed": 21000,
+			"GasPrice": 1000000000,
+			"BlockNumber": 18000000,
+			"EthUsdtPrice": "2000.5",
+			"TrxFeeUsdt": "0.0420105"
+		}
+	]
+}`
+
+func TestTrxFeeListGolden(t *testing.T) {
+	fee := repository.UniTrxFee{
+		ID:          7,
+		Symbol:      "WETH/USDC",
+		TrxHash:     "0xabc",
+		TrxTime:     1700000000,
+		GasUsed:     21000,
+		GasPrice:    util.WeiFromUint64(1e9),
+		BlockNumber: 18000000,
+		FromAddress: "0xsender",
+		Status:      repository.TrxStatusSuccess,
+		Version:     2,
+		CreatedAt:   1700000030,
+		UpdatedAt:   1700000060,
+	}
+	fee.SetPrice(decimal.RequireFromString("2000.5"), "binance_1m")
+
+	b, err := json.MarshalIndent(NewTrxFeeList(&service.GetTrxFeeListResponse{Result: []repository.UniTrxFee{fee}}), "", "\t")
+	require.NoError(t, err)
+	assert.Equal(t, trxFeeListGolden, string(b))
+}
+
+func TestNewTrxFeeList(t *testing.T) {
+	b, err := json.Marshal(NewTrxFeeList(&service.GetTrxFeeListResponse{}))
+	require.NoError(t, err)
+	assert.Equal(t, `{"Result":null}`, string(b))
+
+	total := int64(3)
+	list := NewTrxFeeList(&service.GetTrxFeeListResponse{
+		Result:      []repository.UniTrxFee{{GasPrice: util.NewWei(decimal.RequireFromString("1e20").BigInt())}},
+		NextCursor:  "next",
+		Total:       &total,
+		Conversions: []service.FeeConversion{{Currency: "EUR", UsdtRate: decimal.RequireFromString("0.9"), Fee: decimal.RequireFromString("0.01")}},
+	})
+	// a gas price past 64 bits is clamped
+	assert.Equal(t, uint64(1<<64-1), list.Result[0].GasPrice)
+	b, err = json.Marshal(list)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Result": [{"Symbol": "", "TrxHash": "", "TrxTime": 0, "GasUsed": 0, "GasPrice": 18446744073709551615, "BlockNumber": 0, "EthUsdtPrice": "0", "TrxFeeUsdt": "0"}],
+		"next_cursor": "next",
+		"total": 3,
+		"conversions": [{"currency": "EUR", "usdt_rate": "0.9", "fee": "0.01"}]
+	}`, string(b))
+}
+
+func TestNewStreamedTrxFee(t *testing.T) {
+	fee := repository.UniTrxFee{
+		ID:          7,
+		Symbol:      "WETH/USDC",
+		TrxHash:     "0xabc",
+		TrxTime:     1700000000,
+		GasUsed:     21000,
+		GasPrice:    util.WeiFromUint64(1e9),
+		BlockNumber: 18000000,
+		FromAddress: "0xsender",
+		Version:     2,
+		UpdatedAt:   1700000060,
+	}
+	fee.SetPrice(decimal.RequireFromString("2000.5"), "binance_1m")
+
+	b, err := json.Marshal(NewStreamedTrxFee(&fee))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"ID": 7,
+		"Symbol": "WETH/USDC",
+		"TrxHash": "0xabc",
+		"TrxTime": 1700000000,
+		"GasUsed": 21000,
+		"GasPrice": 1000000000,
+		"BlockNumber": 18000000,
+		"FromAddress": "0xsender",
+		"EthUsdtPrice": "2000.5",
+		"TrxFeeUsdt": "0.0420105"
+	}`, string(b))
+}
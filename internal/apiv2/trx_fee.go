@@ -0,0 +1,160 @@
+// Package apiv2 holds the response models of /api/v2 and their mapping from
+// the repository and service models. Unlike the frozen /api/v1 models of
+// apiv1, which keep the Go field names of the first release, these models only
+// change with the api version: fields are snake_case, decimals are strings and
+// timestamps are both unix and RFC 3339.
+package apiv2
+
+import (
+	"time"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/service"
+)
+
+// Timestamp is a point in time as unix seconds and RFC 3339
+type Timestamp struct {
+	Unix    int64  `json:"unix"`
+	RFC3339 string `json:"rfc3339"`
+}
+
+// NewTimestamp returns the timestamp of a unix time, in UTC
+func NewTimestamp(unix int64) Timestamp {
+	return Timestamp{Unix: unix, RFC3339: time.Unix(unix, 0).UTC().Format(time.RFC3339)}
+}
+
+type TrxFee struct {
+	ID          uint64    `json:"id"`
+	Symbol      string    `json:"symbol"`
+	TrxHash     string    `json:"trx_hash"`
+	BlockNumber uint64    `json:"block_number"`
+	TrxTime     Timestamp `json:"trx_time"`
+	FromAddress string    `json:"from_address"`
+	// success, failed or unknown
+	Status  string `json:"status"`
+	GasUsed uint64 `json:"gas_used"`
+	// in wei
+	GasPrice string `json:"gas_price"`
+	FeeWei   string `json:"fee_wei"`
+	FeeEth   string `json:"fee_eth"`
+	FeeUsdt  string `json:"fee_usdt"`
+	// ETH price in USDT the fee was converted with
+	EthUsdtPrice string `json:"eth_usdt_price"`
+	// binance kline interval the ETH price was averaged from, like binance_1m
+	PriceSource string `json:"price_source"`
+	// incremented every time the fee is overwritten, starting at 1
+	Version   uint64    `json:"version"`
+	UpdatedAt Timestamp `json:"updated_at"`
+	// only if a currency is requested
+	Conversion *FeeConversion `json:"conversion,omitempty"`
+}
+
+// FeeConversion is a fee in the requested currency
+type FeeConversion struct {
+	Currency string `json:"currency"`
+	// units of the currency one USDT was worth at the trx time
+	UsdtRate string `json:"usdt_rate"`
+	Fee      string `json:"fee"`
+}
+
+// NewFeeConversion returns nil if no currency was requested
+func NewFeeConversion(c *service.FeeConversion) *FeeConversion {
+	if c == nil {
+		return nil
+	}
+	return &FeeConversion{Currency: c.Currency, UsdtRate: c.UsdtRate.String(), Fee: c.Fee.String()}
+}
+
+func NewTrxFee(fee *repository.UniTrxFee) TrxFee {
+	return TrxFee{
+		ID:           fee.ID,
+		Symbol:       fee.Symbol,
+		TrxHash:      fee.TrxHash,
+		BlockNumber:  fee.BlockNumber,
+		TrxTime:      NewTimestamp(int64(fee.TrxTime)),
+		FromAddress:  fee.FromAddress,
+		Status:       fee.Status,
+		GasUsed:      fee.GasUsed,
+		GasPrice:     fee.GasPrice.String(),
+		FeeWei:       fee.TrxFeeWei.String(),
+		FeeEth:       fee.TrxFeeEth.String(),
+		FeeUsdt:      fee.TrxFeeUsdt.String(),
+		EthUsdtPrice: fee.EthUsdtPrice.String(),
+		PriceSource:  fee.PriceSource,
+		Version:      fee.Version,
+		UpdatedAt:    NewTimestamp(fee.UpdatedAt),
+	}
+}
+
+// TrxFeeDetail is a fee with how it was priced and where it came from
+type TrxFeeDetail struct {
+	TrxFee
+	// how the ETH price was computed from its source
+	PriceMethod string `json:"price_method"`
+	// database if the fee was stored, live if it was computed from etherscan and binance for the query
+	DataSource string `json:"data_source" enums:"database,live"`
+	// tracked pools the trx touched
+	Pools []Pool `json:"pools"`
+}
+
+type Pool struct {
+	Address string `json:"address"`
+	Symbol  string `json:"symbol"`
+}
+
+func NewTrxFeeDetail(resp *service.GetSingleTrxFeeResponse) TrxFeeDetail {
+	pools := make([]Pool, len(resp.Pools))
+	for i, p := range resp.Pools {
+		pools[i] = Pool{Address: p.Address, Symbol: p.Symbol}
+	}
+	fee := NewTrxFee(resp.Fee)
+	fee.Conversion = NewFeeConversion(resp.Conversion)
+	return TrxFeeDetail{
+		TrxFee:      fee,
+		PriceMethod: resp.PriceMethod,
+		DataSource:  resp.DataSource,
+		Pools:       pools,
+	}
+}
+
+type TrxFeeList struct {
+	Items []TrxFee `json:"items"`
+	// opaque cursor of the next page, absent on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	// only if requested
+	Total *int64 `json:"total,omitempty"`
+}
+
+func NewTrxFeeList(resp *service.GetTrxFeeListResponse) TrxFeeList {
+	items := make([]TrxFee, len(resp.Result))
+	for i := range resp.Result {
+		items[i] = NewTrxFee(&resp.Result[i])
+		if resp.Conversions != nil {
+			items[i].Conversion = NewFeeConversion(&resp.Conversions[i])
+		}
+	}
+	return TrxFeeList{Items: items, NextCursor: resp.NextCursor, Total: resp.Total}
+}
+
+// TrxFeeBatchResult is the outcome of the query of one trx hash of a batch
+type TrxFeeBatchResult struct {
+	TrxHash string `json:"trx_hash"`
+	// status code the single query of the hash would have responded with
+	Status int `json:"status"`
+	// only with status 200
+	Fee *TrxFee `json:"fee,omitempty"`
+	// only with other statuses
+	Error *Error `json:"error,omitempty"`
+}
+
+type TrxFeeBatch struct {
+	// in the order of the requested hashes
+	Results []TrxFeeBatchResult `json:"results"`
+}
+
+// Error is the error of a batch result, with the code and message the single
+// query would have responded with
+type Error struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+}
@@ -0,0 +1,126 @@
+package apiv2
+
+import (
+	"github.com/jaime1129/fedex/internal/service"
+)
+
+// FeeSummary describes fees in one currency
+type FeeSummary struct {
+	Sum  string `json:"sum"`
+	Min  string `json:"min"`
+	Max  string `json:"max"`
+	Mean string `json:"mean"`
+}
+
+func newFeeSummary(s *service.FeeSummary) FeeSummary {
+	return FeeSummary{Sum: s.Sum.String(), Min: s.Min.String(), Max: s.Max.String(), Mean: s.Mean.String()}
+}
+
+// FeeDistribution is a FeeSummary with nearest-rank percentiles
+type FeeDistribution struct {
+	FeeSummary
+	Median string `json:"median"`
+	P90    string `json:"p90"`
+	P99    string `json:"p99"`
+}
+
+func newFeeDistribution(d *service.FeeDistribution) FeeDistribution {
+	return FeeDistribution{
+		FeeSummary: newFeeSummary(&d.FeeSummary),
+		Median:     d.Median.String(),
+		P90:        d.P90.String(),
+		P99:        d.P99.String(),
+	}
+}
+
+// FeeDistributionConversion is the fee_usdt distribution converted to the
+// requested currency
+type FeeDistributionConversion struct {
+	Currency string `json:"currency"`
+	// units of the currency one USDT was worth in the middle of the time range
+	UsdtRate string          `json:"usdt_rate"`
+	Fee      FeeDistribution `json:"fee"`
+}
+
+// FeeSummaryConversion is the fee_usdt summary of a bucket converted to the
+// requested currency
+type FeeSummaryConversion struct {
+	Currency string `json:"currency"`
+	// units of the currency one USDT was worth in the middle of the bucket
+	UsdtRate string     `json:"usdt_rate"`
+	Fee      FeeSummary `json:"fee"`
+}
+
+type TrxFeeStats struct {
+	Symbol    string          `json:"symbol"`
+	StartTime Timestamp       `json:"start_time"`
+	EndTime   Timestamp       `json:"end_time"`
+	Count     int64           `json:"count"`
+	FeeUsdt   FeeDistribution `json:"fee_usdt"`
+	FeeEth    FeeDistribution `json:"fee_eth"`
+	// in wei
+	AvgGasPrice string `json:"avg_gas_price"`
+	AvgGasUsed  string `json:"avg_gas_used"`
+	// percentiles are estimated from rollups, within 1%
+	Approximate bool `json:"approximate"`
+	// only if a currency is requested
+	Conversion *FeeDistributionConversion `json:"conversion,omitempty"`
+}
+
+func NewTrxFeeStats(req *service.GetTrxFeeStatsRequest, resp *service.GetTrxFeeStatsResponse) TrxFeeStats {
+	stats := TrxFeeStats{
+		Symbol:      req.Symbol,
+		StartTime:   NewTimestamp(req.StartTime),
+		EndTime:     NewTimestamp(req.EndTime),
+		Count:       resp.Count,
+		FeeUsdt:     newFeeDistribution(&resp.FeeUsdt),
+		FeeEth:      newFeeDistribution(&resp.FeeEth),
+		AvgGasPrice: resp.AvgGasPrice.String(),
+		AvgGasUsed:  resp.AvgGasUsed.String(),
+		Approximate: resp.Approximate,
+	}
+	if c := resp.Conversion; c != nil {
+		stats.Conversion = &FeeDistributionConversion{Currency: c.Currency, UsdtRate: c.UsdtRate.String(), Fee: newFeeDistribution(&c.Fee)}
+	}
+	return stats
+}
+
+type TrxFeeSeriesBucket struct {
+	// in the requested timezone
+	Start   Timestamp  `json:"start"`
+	Count   int64      `json:"count"`
+	FeeUsdt FeeSummary `json:"fee_usdt"`
+	FeeEth  FeeSummary `json:"fee_eth"`
+	// in wei
+	AvgGasPrice string `json:"avg_gas_price"`
+	AvgGasUsed  string `json:"avg_gas_used"`
+	// only if a currency is requested
+	Conversion *FeeSummaryConversion `json:"conversion,omitempty"`
+}
+
+type TrxFeeSeries struct {
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	Timezone string `json:"timezone"`
+	// every bucket of the time range, including empty ones
+	Buckets []TrxFeeSeriesBucket `json:"buckets"`
+}
+
+func NewTrxFeeSeries(req *service.GetTrxFeeSeriesRequest, resp *service.GetTrxFeeSeriesResponse) TrxFeeSeries {
+	buckets := make([]TrxFeeSeriesBucket, len(resp.Buckets))
+	for i := range resp.Buckets {
+		b := &resp.Buckets[i]
+		buckets[i] = TrxFeeSeriesBucket{
+			Start:       Timestamp{Unix: b.Start, RFC3339: b.Time},
+			Count:       b.Count,
+			FeeUsdt:     newFeeSummary(&b.FeeUsdt),
+			FeeEth:      newFeeSummary(&b.FeeEth),
+			AvgGasPrice: b.AvgGasPrice.String(),
+			AvgGasUsed:  b.AvgGasUsed.String(),
+		}
+		if c := b.Conversion; c != nil {
+			buckets[i].Conversion = &FeeSummaryConversion{Currency: c.Currency, UsdtRate: c.UsdtRate.String(), Fee: newFeeSummary(&c.Fee)}
+		}
+	}
+	return TrxFeeSeries{Symbol: req.Symbol, Interval: resp.Interval, Timezone: resp.Timezone, Buckets: buckets}
+}
@@ -0,0 +1,50 @@
+package apiv2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTrxFee(t *testing.T) {
+	fee := repository.UniTrxFee{
+		ID:          7,
+		Symbol:      "WETH/USDC",
+		TrxHash:     "0xabc",
+		TrxTime:     1700000000,
+		GasUsed:     21000,
+		GasPrice:    util.WeiFromUint64(1e9),
+		BlockNumber: 18000000,
+		FromAddress: "0xsender",
+		Status:      repository.TrxStatusSuccess,
+		Version:     2,
+		UpdatedAt:   1700000060,
+	}
+	fee.SetPrice(decimal.RequireFromString("2000.5"), "binance_1m")
+
+	b, err := json.Marshal(NewTrxFee(&fee))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": 7,
+		"symbol": "WETH/USDC",
+		"trx_hash": "0xabc",
+		"block_number": 18000000,
+		"trx_time": {"unix": 1700000000, "rfc3339": "2023-11-14T22:13:20Z"},
+		"from_address": "0xsender",
+		"status": "success",
+		"gas_used": 21000,
+		"gas_price": "1000000000",
+		"fee_wei": "21000000000000",
+		"fee_eth": "0.000021",
+		"fee_usdt": "0.0420105",
+		"eth_usdt_price": "2000.5",
+		"price_source": "binance_1m",
+		"version": 2,
+		"updated_at": {"unix": 1700000060, "rfc3339": "2023-11-14T22:14:20Z"}
+	}`, string(b))
+}
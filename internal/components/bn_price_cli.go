@@ -2,11 +2,8 @@ package components
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 
 	"github.com/shopspring/decimal"
 	"go.uber.org/ratelimit"
@@ -15,9 +12,24 @@ import (
 const ETHUSDT = "ETHUSDT"
 const INTERVAL_1MIN = "1m"
 const INTERVAL_12HOUR = "12h"
+const INTERVAL_1DAY = "1d"
+
+// most klines binance returns per call
+const maxKlines = 1000
 
 type BnPriceCli interface {
 	QueryETHPrice(start int64, end int64, interval string) (price decimal.Decimal, err error)
+	// QueryKlines returns the klines of a binance symbol opened between start
+	// and end, at most 1000 of them
+	QueryKlines(symbol string, start int64, end int64, interval string) ([]Kline, error)
+}
+
+// Kline is a binance candlestick
+type Kline struct {
+	// unix timestamp
+	OpenTime int64
+	Open     decimal.Decimal
+	Close    decimal.Decimal
 }
 
 type bnPriceCli struct {
@@ -31,37 +43,72 @@ func NewBnPriceCLi() BnPriceCli {
 }
 
 func (c *bnPriceCli) QueryETHPrice(start int64, end int64, interval string) (price decimal.Decimal, err error) {
-	c.rl.Take()
-	url := fmt.Sprintf("https://api.binance.com/api/v3/klines?symbol=%s&interval=%s&startTime=%d&endTime=%d", ETHUSDT, interval, start*1e3, end*1e3)
-	resp, err := http.Get(url)
+	klines, err := c.QueryKlines(ETHUSDT, start, end, interval)
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	if len(klines) == 0 {
+		err = upstreamError("price not found")
 		return
 	}
 
+	for _, k := range klines {
+		price = price.Add(k.Close).Add(k.Open)
+	}
+	price = price.Div(decimal.NewFromInt(int64(len(klines)) * 2))
+	return
+}
+
+func (c *bnPriceCli) QueryKlines(symbol string, start int64, end int64, interval string) ([]Kline, error) {
+	c.rl.Take()
+	url := fmt.Sprintf("https://api.binance.com/api/v3/klines?symbol=%s&interval=%s&startTime=%d&endTime=%d&limit=%d", symbol, interval, start*1e3, end*1e3, maxKlines)
+	body, err := getUpstream(url)
+	if err != nil {
+		return nil, err
+	}
+
 	log.Println("binance api resp body: " + string(body))
 
 	var rawCandlesticks [][]interface{}
 	err = json.Unmarshal(body, &rawCandlesticks)
 	if err != nil {
-		return
+		return nil, fmt.Errorf("%w: %w", ErrUpstream, err)
 	}
 
-	if len(rawCandlesticks) == 0 {
-		err = errors.New("price not found")
-		return
+	klines := make([]Kline, len(rawCandlesticks))
+	for i, c := range rawCandlesticks {
+		if len(c) < 5 {
+			return nil, upstreamError("malformed kline")
+		}
+		// a kline binance didn't encode as documented fails the call rather
+		// than pricing fees at 0
+		openTime, ok := c[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed kline open time %v", ErrUpstream, c[0])
+		}
+		openPrice, err := parseKlinePrice(c[1])
+		if err != nil {
+			return nil, err
+		}
+		closePrice, err := parseKlinePrice(c[4])
+		if err != nil {
+			return nil, err
+		}
+		klines[i] = Kline{OpenTime: int64(openTime) / 1e3, Open: openPrice, Close: closePrice}
 	}
+	return klines, nil
+}
 
-	for _, c := range rawCandlesticks {
-		closePrice, _ := decimal.NewFromString(c[4].(string))
-		openPrice, _ := decimal.NewFromString(c[1].(string))
-		price = price.Add(closePrice).Add(openPrice)
+// parseKlinePrice parses a price of a kline, a decimal string
+func parseKlinePrice(v interface{}) (decimal.Decimal, error) {
+	s, ok := v.(string)
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%w: malformed kline price %v", ErrUpstream, v)
 	}
-	price = price.Div(decimal.NewFromInt(int64(len(rawCandlesticks)) * 2))
-	return
+	price, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("%w: malformed kline price %q: %w", ErrUpstream, s, err)
+	}
+	return price, nil
 }
@@ -22,7 +22,7 @@ var _ = ginkgo.Describe("BnPriceCli", func() {
 
 	ginkgo.It("should return the average price correctly", func() {
 		httpmock.RegisterResponder("GET", "https://api.binance.com/api/v3/klines",
-			httpmock.NewStringResponder(200, `[["", "100.5", "", "", "101.5"]]`))
+			httpmock.NewStringResponder(200, `[[1609459200000, "100.5", "", "", "101.5"]]`))
 
 		price, err := client.QueryETHPrice(1609459200, 1609545600, INTERVAL_1MIN)
 		gomega.Expect(err).Should(gomega.BeNil())
@@ -45,4 +45,30 @@ var _ = ginkgo.Describe("BnPriceCli", func() {
 		gomega.Expect(err).ShouldNot(gomega.BeNil())
 		gomega.Expect(err.Error()).To(gomega.Equal("price not found"))
 	})
+
+	ginkgo.It("should return the klines of a symbol", func() {
+		httpmock.RegisterResponder("GET", "https://api.binance.com/api/v3/klines",
+			httpmock.NewStringResponder(200, `[[1609459200000, "0.999", "", "", "1.001"], [1609545600000, "1.001", "", "", "1.003"]]`))
+
+		klines, err := client.QueryKlines("USDCUSDT", 1609459200, 1609632000, INTERVAL_1DAY)
+		gomega.Expect(err).Should(gomega.BeNil())
+		gomega.Expect(klines).To(gomega.HaveLen(2))
+		gomega.Expect(klines[1].OpenTime).To(gomega.Equal(int64(1609545600)))
+		gomega.Expect(klines[1].Open.String()).To(gomega.Equal("1.001"))
+		gomega.Expect(klines[1].Close.String()).To(gomega.Equal("1.003"))
+	})
+
+	ginkgo.It("should fail on a malformed kline", func() {
+		for _, body := range []string{
+			`[["1609459200000", "0.999", "", "", "1.001"]]`,
+			`[[1609459200000, 0.999, "", "", "1.001"]]`,
+			`[[1609459200000, "0.999", "", "", "one"]]`,
+		} {
+			httpmock.RegisterResponder("GET", "https://api.binance.com/api/v3/klines",
+				httpmock.NewStringResponder(200, body))
+
+			_, err := client.QueryKlines("USDCUSDT", 1609459200, 1609632000, INTERVAL_1DAY)
+			gomega.Expect(err).Should(gomega.MatchError(ErrUpstream), body)
+		}
+	})
 })
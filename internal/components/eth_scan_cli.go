@@ -1,12 +1,9 @@
 package components
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 
 	"github.com/jaime1129/fedex/internal/util"
 	"go.uber.org/ratelimit"
@@ -40,6 +37,15 @@ type EthScanTrxResult struct {
 	EffectiveGasPrice string `json:"effectiveGasPrice"`
 	GasUsed           string `json:"gasUsed"`
 	BlockNumber       string `json:"blockNumber"`
+	From              string `json:"from"`
+	// 0x1 if the trx succeeded, 0x0 if it failed
+	Status string          `json:"status"`
+	Logs   []EthScanTrxLog `json:"logs"`
+}
+
+type EthScanTrxLog struct {
+	// contract that emitted the log
+	Address string `json:"address"`
 }
 
 type EthScanError struct {
@@ -52,13 +58,7 @@ func (c *ethScanCli) QueryTrxFee(trxHash string) (*EthScanTrxResponse, error) {
 	// send query to etherscan api
 	url := fmt.Sprintf("https://api.etherscan.io/api?module=proxy&action=eth_getTransactionReceipt&txhash=%s&apikey=%s", trxHash, c.apiKey)
 	log.Println("ethscan api url: " + url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := getUpstream(url)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +66,7 @@ func (c *ethScanCli) QueryTrxFee(trxHash string) (*EthScanTrxResponse, error) {
 	log.Println("ethscan api resp body: " + string(body))
 
 	trxResp := &EthScanTrxResponse{}
-	err = json.Unmarshal(body, trxResp)
+	err = decodeEthScanResp(body, trxResp)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +78,7 @@ func (c *ethScanCli) QueryTrxFee(trxHash string) (*EthScanTrxResponse, error) {
 			return trxResp, nil
 		}
 		log.Println("ethscan api call returns error: " + trxResp.Error.Message)
-		return nil, errors.New("ethscan api call returns error")
+		return nil, errEthScanCall
 	}
 
 	return trxResp, nil
@@ -98,13 +98,7 @@ func (c *ethScanCli) QueryBlock(blockNumber string) (*EthScanBlockResponse, erro
 	// send query to etherscan api
 	url := fmt.Sprintf("https://api.etherscan.io/api?module=proxy&action=eth_getBlockByNumber&tag=%s&boolean=true&apikey=%s", blockNumber, c.apiKey)
 	log.Println("ethscan api url: " + url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := getUpstream(url)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +106,7 @@ func (c *ethScanCli) QueryBlock(blockNumber string) (*EthScanBlockResponse, erro
 	log.Println("ethscan api resp body: " + string(body))
 
 	trxResp := &EthScanBlockResponse{}
-	err = json.Unmarshal(body, trxResp)
+	err = decodeEthScanResp(body, trxResp)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +114,7 @@ func (c *ethScanCli) QueryBlock(blockNumber string) (*EthScanBlockResponse, erro
 	// check if api call returns error
 	if trxResp.Error.Code != 0 {
 		log.Println("ethscan api call returns error: " + trxResp.Error.Message)
-		return nil, errors.New("ethscan api call returns error")
+		return nil, errEthScanCall
 	}
 
 	return trxResp, nil
@@ -152,11 +146,16 @@ type Transaction struct {
 	Hash              string `json:"hash"`
 	BlockNumber       string `json:"blockNumber"`
 	TimeStamp         string `json:"timeStamp"`
+	From              string `json:"from"`
 	Gas               string `json:"gas"`
 	GasPrice          string `json:"gasPrice"`
 	ContractAddress   string `json:"contractAddress"`
 	CumulativeGasUsed string `json:"cumulativeGasUsed"`
 	GasUsed           string `json:"gasUsed"`
+	// "1" if the transaction reverted
+	IsError string `json:"isError"`
+	// "1" for success and "0" for failure, empty before byzantium
+	TxReceiptStatus string `json:"txreceipt_status"`
 }
 
 func (c *ethScanCli) QueryHistoricalTrxs(req *QueryHistoricalTrxsReq) (*QueryHistoricalTrxsResp, error) {
@@ -179,13 +178,7 @@ func (c *ethScanCli) QueryHistoricalTrxs(req *QueryHistoricalTrxsReq) (*QueryHis
 	}
 
 	log.Println("ethscan api url: " + url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := getUpstream(url)
 	if err != nil {
 		return nil, err
 	}
@@ -193,7 +186,7 @@ func (c *ethScanCli) QueryHistoricalTrxs(req *QueryHistoricalTrxsReq) (*QueryHis
 	log.Println("ethscan api resp body: " + string(body))
 
 	trxResp := &QueryHistoricalTrxsResp{}
-	err = json.Unmarshal(body, trxResp)
+	err = decodeEthScanResp(body, trxResp)
 	if err != nil {
 		return nil, err
 	}
@@ -201,7 +194,7 @@ func (c *ethScanCli) QueryHistoricalTrxs(req *QueryHistoricalTrxsReq) (*QueryHis
 	// check if api call returns error
 	if trxResp.Status != StatusOK {
 		log.Printf("ethscan api call not ok: %s, %s\n", trxResp.Status, trxResp.Message)
-		return nil, errors.New("ethscan api call returns error")
+		return nil, errEthScanCall
 	}
 
 	return trxResp, nil
@@ -217,13 +210,7 @@ func (c *ethScanCli) GetLatestBlock() (int64, error) {
 	// send query to etherscan api
 	url := fmt.Sprintf("https://api.etherscan.io/api?module=proxy&action=eth_blockNumber&apikey=%s", c.apiKey)
 	log.Println("ethscan api url: " + url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := getUpstream(url)
 	if err != nil {
 		return 0, err
 	}
@@ -231,7 +218,7 @@ func (c *ethScanCli) GetLatestBlock() (int64, error) {
 	log.Println("ethscan api resp body: " + string(body))
 
 	trxResp := &GetLatestBlockResp{}
-	err = json.Unmarshal(body, trxResp)
+	err = decodeEthScanResp(body, trxResp)
 	if err != nil {
 		return 0, err
 	}
@@ -239,12 +226,12 @@ func (c *ethScanCli) GetLatestBlock() (int64, error) {
 	// check if api call returns error
 	if trxResp.Error.Code != 0 {
 		log.Println("ethscan api call returns error: " + trxResp.Error.Message)
-		return 0, errors.New("ethscan api call returns error")
+		return 0, errEthScanCall
 	}
 
-	blockNum, err := util.HexToInt(trxResp.BlockNumber)
+	blockNum, err := util.ParseHexUint64(trxResp.BlockNumber)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("%w: malformed block number: %w", ErrUpstream, err)
 	}
-	return blockNum, nil
+	return int64(blockNum), nil
 }
@@ -1,6 +1,7 @@
 package components
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
@@ -49,6 +50,23 @@ var _ = ginkgo.Describe("EthScanCli", func() {
 			_, err := client.QueryTrxFee(trxHash)
 			gomega.Expect(err).ShouldNot(gomega.BeNil())
 			gomega.Expect(err.Error()).To(gomega.Equal("ethscan api call returns error"))
+			gomega.Expect(errors.Is(err, ErrUpstream)).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("should report rate limited calls", func() {
+			httpmock.RegisterResponder("GET", `=~https://api.etherscan.io/api`,
+				httpmock.NewStringResponder(200, `{"status":"0","message":"NOTOK","result":"Max calls per sec rate limit reached (5/sec)"}`))
+
+			_, err := client.QueryTrxFee("some-trx-hash")
+			gomega.Expect(errors.Is(err, ErrRateLimited)).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("should report server errors as unavailable", func() {
+			httpmock.RegisterResponder("GET", `=~https://api.etherscan.io/api`,
+				httpmock.NewStringResponder(503, `Service Unavailable`))
+
+			_, err := client.QueryTrxFee("some-trx-hash")
+			gomega.Expect(errors.Is(err, ErrUpstreamUnavailable)).To(gomega.BeTrue())
 		})
 	})
 
@@ -0,0 +1,53 @@
+package components
+
+import "strings"
+
+const (
+	SymbolWETHUSDC = "WETH/USDC"
+	// UniswapV3 WETH/USDC 0.05% pool
+	PoolWETHUSDC = "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"
+)
+
+// symbols of the tracked pools, by lower case address
+var poolSymbols = map[string]string{
+	PoolWETHUSDC: SymbolWETHUSDC,
+}
+
+// Pool is a tracked pool
+type Pool struct {
+	// lower case
+	Address string `json:"address"`
+	Symbol  string `json:"symbol"`
+}
+
+// TrackedPools returns the tracked pools that emitted logs of a trx receipt,
+// in the order of their first log
+func TrackedPools(logs []EthScanTrxLog) []Pool {
+	var pools []Pool
+	seen := make(map[string]bool)
+	for _, l := range logs {
+		addr := strings.ToLower(l.Address)
+		if symbol, ok := poolSymbols[addr]; ok && !seen[addr] {
+			seen[addr] = true
+			pools = append(pools, Pool{Address: addr, Symbol: symbol})
+		}
+	}
+	return pools
+}
+
+// SymbolPool returns the tracked pool of a symbol, or false if no pool of the
+// symbol is tracked
+func SymbolPool(symbol string) (Pool, bool) {
+	for addr, s := range poolSymbols {
+		if s == symbol {
+			return Pool{Address: addr, Symbol: s}, true
+		}
+	}
+	return Pool{}, false
+}
+
+// IsTrackedSymbol tells whether symbol is the symbol of a tracked pool
+func IsTrackedSymbol(symbol string) bool {
+	_, ok := SymbolPool(symbol)
+	return ok
+}
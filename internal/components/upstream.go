@@ -0,0 +1,70 @@
+package components
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	// ErrUpstream is wrapped by the errors of failed etherscan and binance api calls
+	ErrUpstream = errors.New("upstream api error")
+	// ErrRateLimited is wrapped by the errors of api calls rejected by the rate limit of the api
+	ErrRateLimited = fmt.Errorf("%w: rate limited", ErrUpstream)
+	// ErrUpstreamUnavailable is wrapped by the errors of api calls that didn't
+	// reach the api or that it failed to serve
+	ErrUpstreamUnavailable = fmt.Errorf("%w: unavailable", ErrUpstream)
+)
+
+// upstreamError is an ErrUpstream with its own message
+type upstreamError string
+
+func (e upstreamError) Error() string {
+	return string(e)
+}
+
+func (e upstreamError) Unwrap() error {
+	return ErrUpstream
+}
+
+const errEthScanCall = upstreamError("ethscan api call returns error")
+
+// getUpstream returns the body of a successful api response
+func getUpstream(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	}
+
+	switch {
+	// binance answers 418 to clients banned for ignoring its 429s
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot:
+		return nil, fmt.Errorf("%w: %s", ErrRateLimited, resp.Status)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: %s", ErrUpstreamUnavailable, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %s %s", ErrUpstream, resp.Status, body)
+	}
+	return body, nil
+}
+
+// decodeEthScanResp decodes an etherscan response, which has a string result
+// instead of the expected one when the call is rate limited
+func decodeEthScanResp(body []byte, resp interface{}) error {
+	if bytes.Contains(body, []byte("rate limit reached")) {
+		return fmt.Errorf("%w: %s", ErrRateLimited, body)
+	}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("%w: %w", ErrUpstream, err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaime1129/fedex/internal/service"
+)
+
+type DeadLetterController interface {
+	ListDeadLetters(ctx *gin.Context)
+	RetryDeadLetter(ctx *gin.Context)
+	DiscardDeadLetter(ctx *gin.Context)
+}
+
+type deadLetterController struct {
+	svc service.DeadLetterService
+}
+
+func NewDeadLetterController(svc service.DeadLetterService) DeadLetterController {
+	return &deadLetterController{
+		svc: svc,
+	}
+}
+
+// ListDeadLetters godoc
+//	@Summary		List dead-lettered batches
+//	@Description	list ingestion batches that failed to be priced or persisted
+//	@Produce		json
+//	@Param			status	query		string	false	"all by default"	Enums(pending, exhausted, resolved, discarded)
+//	@Param			page	query		int		false	"page starting from 0"
+//	@Param			limit	query		int		false	"between 1 and 100, 20 by default"
+//	@Success		200		{object}	service.ListDeadLettersResponse
+//	@Failure		400		{object}	ErrorResponse	"invalid_argument: unknown status, malformed or out of bounds page or limit"
+//	@Failure		500		{object}	ErrorResponse	"internal"
+//	@Router			/v1/deadletter/list [get]
+func (c *deadLetterController) ListDeadLetters(ctx *gin.Context) {
+	var query deadLetterListQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.ListDeadLetters(ctx, &service.ListDeadLettersRequest{
+		Status: query.Status,
+		Page:   query.Page,
+		Limit:  query.Limit,
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// RetryDeadLetter godoc
+//	@Summary		Retry a dead-lettered batch
+//	@Description	replay a dead-lettered batch now, regardless of its backoff
+//	@Produce		json
+//	@Param			id	path		int	true	"dead letter batch id"
+//	@Success		200	{object}	service.DeadLetterBatch
+//	@Failure		404	{object}	ErrorResponse	"not_found"
+//	@Failure		409	{object}	ErrorResponse	"conflict: the batch is already resolved or discarded"
+//	@Failure		500	{object}	ErrorResponse	"internal"
+//	@Router			/v1/deadletter/{id}/retry [post]
+func (c *deadLetterController) RetryDeadLetter(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		// no batch has a malformed id
+		respondError(ctx, service.ErrDeadLetterNotFound)
+		return
+	}
+	resp, err := c.svc.RetryDeadLetter(ctx, id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// DiscardDeadLetter godoc
+//	@Summary		Discard a dead-lettered batch
+//	@Description	mark a dead-lettered batch as discarded so that it is never retried
+//	@Produce		json
+//	@Param			id	path		int	true	"dead letter batch id"
+//	@Success		200	{object}	service.DeadLetterBatch
+//	@Failure		404	{object}	ErrorResponse	"not_found"
+//	@Failure		409	{object}	ErrorResponse	"conflict: the batch is already resolved or discarded"
+//	@Failure		500	{object}	ErrorResponse	"internal"
+//	@Router			/v1/deadletter/{id} [delete]
+func (c *deadLetterController) DiscardDeadLetter(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		// no batch has a malformed id
+		respondError(ctx, service.ErrDeadLetterNotFound)
+		return
+	}
+	resp, err := c.svc.DiscardDeadLetter(ctx, id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
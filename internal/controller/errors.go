@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/service"
+)
+
+// ErrorCode is the machine-readable reason of an error response
+type ErrorCode string
+
+const (
+	// 400, a request parameter is malformed or out of bounds
+	CodeInvalidArgument ErrorCode = "invalid_argument"
+	// 404
+	CodeNotFound ErrorCode = "not_found"
+	// 409, the resource is not in a state allowing the operation
+	CodeConflict ErrorCode = "conflict"
+	// 429, etherscan or binance rejected the calls needed to serve the request
+	CodeRateLimited ErrorCode = "rate_limited"
+	// 502, etherscan or binance answered with an error or an unexpected response
+	CodeUpstreamError ErrorCode = "upstream_error"
+	// 503, etherscan or binance could not be reached, or the request was canceled
+	CodeUnavailable ErrorCode = "unavailable"
+	// 500
+	CodeInternal ErrorCode = "internal"
+)
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Code ErrorCode `json:"code" enums:"invalid_argument,not_found,conflict,rate_limited,upstream_error,unavailable,internal"`
+	Msg  string    `json:"msg"`
+	// the request parameter at fault, only for some invalid_argument errors
+	Field string `json:"field,omitempty"`
+}
+
+// paramError is an invalid request parameter
+type paramError struct {
+	field string
+	msg   string
+}
+
+func invalidParam(field string, msg string) error {
+	return &paramError{field: field, msg: msg}
+}
+
+func (e *paramError) Error() string {
+	return e.msg
+}
+
+func (e *paramError) Unwrap() error {
+	return service.ErrInvalidArgument
+}
+
+// respondError responds with the status and code of err
+func respondError(ctx *gin.Context, err error) {
+	status, code := errorStatus(err)
+	resp := ErrorResponse{Code: code, Msg: err.Error()}
+	var pe *paramError
+	if errors.As(err, &pe) {
+		resp.Field = pe.field
+	}
+	ctx.JSON(status, resp)
+}
+
+func errorStatus(err error) (int, ErrorCode) {
+	switch {
+	case errors.Is(err, service.ErrInvalidArgument):
+		return http.StatusBadRequest, CodeInvalidArgument
+	case errors.Is(err, service.ErrTrxNotFound), errors.Is(err, service.ErrDeadLetterNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, service.ErrDeadLetterClosed):
+		return http.StatusConflict, CodeConflict
+	case errors.Is(err, components.ErrRateLimited):
+		return http.StatusTooManyRequests, CodeRateLimited
+	case errors.Is(err, components.ErrUpstreamUnavailable), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, CodeUnavailable
+	case errors.Is(err, components.ErrUpstream):
+		return http.StatusBadGateway, CodeUpstreamError
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
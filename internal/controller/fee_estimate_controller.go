@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaime1129/fedex/internal/service"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+)
+
+type FeeEstimateController interface {
+	EstimateFee(ctx *gin.Context)
+}
+
+type feeEstimateController struct {
+	svc service.TrxFeeService
+}
+
+func NewFeeEstimateController(svc service.TrxFeeService) FeeEstimateController {
+	return &feeEstimateController{
+		svc: svc,
+	}
+}
+
+// EstimateFee godoc
+//	@Summary		Estimate the fee of gas at a time
+//	@Description	get what gas_used gas would have cost at timestamp, with the given gas price or a percentile of the gas prices of the fees of the window before timestamp, priced like fees looked up on etherscan and binance
+//	@Produce		json
+//	@Param			gas_used				query		int		true	"gas units"
+//	@Param			gas_price				query		string	false	"gas price in gwei, required without gas_price_percentile"
+//	@Param			gas_price_percentile	query		int		false	"nearest-rank percentile, between 1 and 100, of the observed gas prices, required without gas_price"
+//	@Param			symbol					query		string	false	"symbol of the fees gas prices are observed from, WETH/USDC by default"
+//	@Param			window					query		int		false	"seconds before timestamp gas prices are observed over, between 1 and 86400, 3600 by default"
+//	@Param			timestamp				query		int		true	"unix timestamp, not in the future"
+//	@Param			currency				query		string	false	"currency to also convert the fee to, besides USD and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200						{object}	service.EstimateFeeResponse
+//	@Failure		400						{object}	ErrorResponse	"invalid_argument: malformed or out of bounds parameter, both or none of gas_price and gas_price_percentile, future timestamp, no fee in the window or no rate of the currency at timestamp"
+//	@Failure		429						{object}	ErrorResponse	"rate_limited: binance rate limited the price query"
+//	@Failure		500						{object}	ErrorResponse	"internal"
+//	@Failure		502						{object}	ErrorResponse	"upstream_error: binance failed the price query"
+//	@Failure		503						{object}	ErrorResponse	"unavailable: binance could not be reached"
+//	@Router			/v1/fee/estimate [get]
+func (c *feeEstimateController) EstimateFee(ctx *gin.Context) {
+	req, err := parseEstimateFeeRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.EstimateFee(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func parseEstimateFeeRequest(ctx *gin.Context) (*service.EstimateFeeRequest, error) {
+	var query feeEstimateQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		return nil, err
+	}
+	var gasPriceGwei *decimal.Decimal
+	if err := optionalQuery(ctx, "gas_price", &gasPriceGwei, decimal.NewFromString); err != nil {
+		return nil, err
+	}
+
+	req := &service.EstimateFeeRequest{
+		GasUsed:            query.GasUsed,
+		GasPricePercentile: query.GasPricePercentile,
+		Symbol:             query.Symbol,
+		Window:             query.Window,
+		Timestamp:          query.Timestamp,
+		Currency:           query.Currency,
+	}
+	if gasPriceGwei != nil {
+		if gasPriceGwei.IsNegative() {
+			return nil, invalidParam("gas_price", "invalid gas_price: must be at least 0")
+		}
+		gasPrice := util.WeiFromGwei(*gasPriceGwei)
+		req.GasPrice = &gasPrice
+	}
+	return req, nil
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/currency"
+	"github.com/jaime1129/fedex/internal/util"
+)
+
+// timeRangeQuery is the symbol and time range of the fee queries
+type timeRangeQuery struct {
+	Symbol    string `form:"symbol,default=WETH/USDC" binding:"symbol"`
+	StartTime int64  `form:"start_time" binding:"gte=0"`
+	// the range ends now if 0
+	EndTime int64 `form:"end_time" binding:"gte=0"`
+}
+
+func (q *timeRangeQuery) endTime() int64 {
+	if q.EndTime == 0 {
+		return time.Now().Unix()
+	}
+	return q.EndTime
+}
+
+// currencyQuery is the currency fees are also converted to, besides USDT and ETH
+type currencyQuery struct {
+	Currency string `form:"currency" binding:"omitempty,currency"`
+}
+
+type trxFeeStatsQuery struct {
+	timeRangeQuery
+	currencyQuery
+}
+
+// trxFeeListQuery is the query of GetTrxFeeList but its range filters, which
+// are parsed by optionalQuery
+type trxFeeListQuery struct {
+	timeRangeQuery
+	currencyQuery
+	Address      string `form:"address"`
+	Status       string `form:"status"`
+	PriceSource  string `form:"price_source"`
+	Sort         string `form:"sort"`
+	Order        string `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor       string `form:"cursor"`
+	Limit        int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	IncludeTotal bool   `form:"include_total"`
+}
+
+type trxFeeSeriesQuery struct {
+	timeRangeQuery
+	currencyQuery
+	Interval string `form:"interval,default=hour" binding:"oneof=minute hour day week"`
+	Timezone string `form:"timezone"`
+}
+
+type trxHashURI struct {
+	TrxHash string `uri:"trx_hash" binding:"trxhash"`
+}
+
+type trxFeeBatchBody struct {
+	// hashes are validated one by one, so that a malformed one only fails its own result
+	TrxHashes []string `json:"trx_hashes" binding:"required,min=1,max=100"`
+}
+
+// feeEstimateQuery is the query of EstimateFee but its gas price in gwei, which
+// is parsed by optionalQuery
+type feeEstimateQuery struct {
+	currencyQuery
+	GasUsed            uint64 `form:"gas_used" binding:"required,min=1"`
+	GasPricePercentile int    `form:"gas_price_percentile" binding:"omitempty,min=1,max=100"`
+	Symbol             string `form:"symbol,default=WETH/USDC" binding:"symbol"`
+	Window             int64  `form:"window,default=3600" binding:"min=1,max=86400"`
+	Timestamp          int64  `form:"timestamp" binding:"required,min=1"`
+}
+
+type streamTrxFeeQuery struct {
+	Symbol  string `form:"symbol" binding:"omitempty,symbol"`
+	MinFee  string `form:"min_fee"`
+	Address string `form:"address"`
+	// overridden by the Last-Event-ID header
+	LastEventID *uint64 `form:"last_event_id"`
+}
+
+type deadLetterListQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending exhausted resolved discarded"`
+	Page   int    `form:"page" binding:"gte=0"`
+	Limit  int    `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+var registerValidators sync.Once
+
+// bindRequest binds the parameters of a request into dest, a request DTO, with
+// one of the ShouldBind methods of the gin context and validates them as their
+// binding tags tell
+func bindRequest(dest interface{}, bind func(obj interface{}) error) error {
+	registerValidators.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			panic("gin validator is not a validator.Validate")
+		}
+		// validation errors name fields as requests do
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"form", "uri", "json"} {
+				if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+					return name
+				}
+			}
+			return f.Name
+		})
+		v.RegisterValidation("trxhash", func(fl validator.FieldLevel) bool {
+			return util.IsTrxHash(fl.Field().String())
+		})
+		v.RegisterValidation("symbol", func(fl validator.FieldLevel) bool {
+			return components.IsTrackedSymbol(fl.Field().String())
+		})
+		v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+			return currency.IsSupported(fl.Field().String())
+		})
+	})
+
+	err := bind(dest)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		// a value that doesn't parse into its field
+		return invalidParam("", "invalid request: "+err.Error())
+	}
+	fe := verrs[0]
+	return invalidParam(fe.Field(), fmt.Sprintf("invalid %s: %s", fe.Field(), validationMessage(fe)))
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "required"
+	case "min", "gte":
+		if fe.Kind() == reflect.Slice {
+			return "must have at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		if fe.Kind() == reflect.Slice {
+			return "must have at most " + fe.Param() + " items"
+		}
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "trxhash":
+		return "must be 0x followed by 64 hex digits"
+	case "symbol":
+		return "unknown symbol"
+	case "currency":
+		return "must be one of " + strings.Join(currency.Supported, ", ")
+	default:
+		return "failed " + fe.Tag()
+	}
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaime1129/fedex/internal/jobs"
+)
+
+type TrackerController interface {
+	GetStatus(ctx *gin.Context)
+}
+
+type trackerController struct {
+	tracker jobs.DataTracker
+}
+
+func NewTrackerController(tracker jobs.DataTracker) TrackerController {
+	return &trackerController{
+		tracker: tracker,
+	}
+}
+
+// GetStatus godoc
+//	@Summary		Get data tracker status
+//	@Description	get the state of the data tracker: initializing, running, degraded or stopped
+//	@Produce		json
+//	@Success		200	{object}	jobs.TrackerStatus
+//	@Router			/v1/tracker/status [get]
+func (c *trackerController) GetStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.tracker.Status())
+}
@@ -1,17 +1,24 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jaime1129/fedex/internal/apiv1"
+	"github.com/jaime1129/fedex/internal/repository"
 	"github.com/jaime1129/fedex/internal/service"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
 )
 
 type TrxFeeController interface {
 	GetSingleTrxFee(ctx *gin.Context)
+	GetTrxFeeBatch(ctx *gin.Context)
 	GetTrxFeeList(ctx *gin.Context)
+	GetTrxFeeStats(ctx *gin.Context)
+	GetTrxFeeSeries(ctx *gin.Context)
 }
 
 type trxFeeController struct {
@@ -26,59 +33,302 @@ func NewTrxController(svc service.TrxFeeService) TrxFeeController {
 
 // GetSingleTrxFee godoc
 //	@Summary		Get trx fee of single trx
-//	@Description	get trx fee by trx hash
+//	@Description	get trx fee by trx hash with its gas, block, ETH price and how it was computed, whether it was stored or looked up on etherscan and binance, and the tracked pools the trx touched
 //	@Accept			json
 //	@Produce		json
-//	@Param			trx_hash	path	string	true	"trx hash"
-//	@Success		200			string	trx_fee
-//	@Failure		500			string	msg
-//	@Router			/trxfee/{trx_hash} [get]
+//	@Param			trx_hash	path		string	true	"trx hash, 0x followed by 64 hex digits"
+//	@Param			currency	query		string	false	"currency to also convert the fee to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200			{object}	service.GetSingleTrxFeeResponse
+//	@Failure		400			{object}	ErrorResponse	"invalid_argument: malformed trx hash, unsupported currency or no rate of the currency at the trx time"
+//	@Failure		404			{object}	ErrorResponse	"not_found: etherscan has no receipt for the trx"
+//	@Failure		429			{object}	ErrorResponse	"rate_limited: etherscan or binance rate limited the lookup"
+//	@Failure		500			{object}	ErrorResponse	"internal"
+//	@Failure		502			{object}	ErrorResponse	"upstream_error: etherscan or binance failed the lookup"
+//	@Failure		503			{object}	ErrorResponse	"unavailable: etherscan or binance could not be reached"
+//	@Router			/v1/trxfee/{trx_hash} [get]
 func (c *trxFeeController) GetSingleTrxFee(ctx *gin.Context) {
-	trxHash := ctx.Param("trx_hash")
-	resp, err := c.svc.GetSingleTrxFee(ctx, &service.GetSingleTrxFeeRequest{
-		TrxHash: trxHash,
-	})
+	req, err := parseGetSingleTrxFeeRequest(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetSingleTrxFee(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, resp)
 }
 
+// TrxFeeBatchResult is the outcome of the query of one trx hash of a batch,
+// with the status and error the single query would have responded with
+type TrxFeeBatchResult struct {
+	TrxHash string `json:"trx_hash"`
+	Status  int    `json:"status"`
+	TrxFee  string `json:"trx_fee,omitempty"`
+	// only if a currency is requested
+	Conversion *service.FeeConversion `json:"conversion,omitempty"`
+	Error      *ErrorResponse         `json:"error,omitempty"`
+}
+
+type TrxFeeBatchResponse struct {
+	// in the order of the requested hashes
+	Results []TrxFeeBatchResult `json:"results"`
+}
+
+// GetTrxFeeBatch godoc
+//	@Summary		Get trx fees of several trxs
+//	@Description	get trx fees of up to 100 trx hashes, stored ones are served from the database and the others looked up concurrently; each result has the status and error the single query of its hash would have responded with
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		trxFeeBatchBody	true	"trx hashes"
+//	@Param			currency	query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200		{object}	TrxFeeBatchResponse
+//	@Failure		400		{object}	ErrorResponse	"invalid_argument: no or more than 100 trx hashes or unsupported currency"
+//	@Failure		500		{object}	ErrorResponse	"internal"
+//	@Router			/v1/trxfee/batch [post]
+func (c *trxFeeController) GetTrxFeeBatch(ctx *gin.Context) {
+	req, err := parseGetTrxFeeBatchRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeBatch(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	results := make([]TrxFeeBatchResult, len(resp.Results))
+	for i, res := range resp.Results {
+		results[i] = TrxFeeBatchResult{TrxHash: res.TrxHash, Status: http.StatusOK}
+		if res.Err != nil {
+			results[i].Status, results[i].Error = batchResultError(res.Err)
+			continue
+		}
+		results[i].TrxFee = res.Fee.TrxFeeUsdt.String()
+		results[i].Conversion = res.Conversion
+	}
+	ctx.JSON(http.StatusOK, TrxFeeBatchResponse{Results: results})
+}
+
 // GetTrxFeeList godoc
 //	@Summary		Get a list of trx fee
-//	@Description	get trx fee by given time period
+//	@Description	get trx fee by given time period and filters, ordered by the sort field then id; pass next_cursor as cursor to get the next page
 //	@Accept			json
 //	@Produce		json
-//	@Param			symbol		query		string	true	"symbol"
-//	@Param			start_time	query		int		true	"start timestamp"
-//	@Param			end_time	query		int		true	"end timestamp"
-//	@Param			page		query		int		true	"page starting from 0"
-//	@Param			limit		query		int		true	"20 by default"
-//	@Success		200			{object}	service.GetTrxFeeListResponse
-//	@Failure		500			string		msg
-//	@Router			/trxfee/list [get]
+//	@Param			symbol			query		string	false	"symbol, WETH/USDC by default"
+//	@Param			start_time		query		int		true	"start timestamp"
+//	@Param			end_time		query		int		false	"end timestamp, now by default"
+//	@Param			min_block		query		int		false	"minimum block number"
+//	@Param			max_block		query		int		false	"maximum block number"
+//	@Param			min_fee_usdt	query		string	false	"minimum fee in USDT"
+//	@Param			max_fee_usdt	query		string	false	"maximum fee in USDT"
+//	@Param			min_fee_eth		query		string	false	"minimum fee in ETH"
+//	@Param			max_fee_eth		query		string	false	"maximum fee in ETH"
+//	@Param			min_gas_price	query		string	false	"minimum gas price in wei"
+//	@Param			max_gas_price	query		string	false	"maximum gas price in wei"
+//	@Param			min_gas_used	query		int		false	"minimum gas used"
+//	@Param			max_gas_used	query		int		false	"maximum gas used"
+//	@Param			address			query		string	false	"sender address"
+//	@Param			status			query		string	false	"transaction status"	Enums(success, failed, unknown)
+//	@Param			price_source	query		string	false	"source of the ETH price, like binance_1m or binance_12h"
+//	@Param			sort			query		string	false	"trx_time by default"	Enums(trx_time, block_num, gas_used, gas_price, trx_fee_eth, trx_fee_usdt)
+//	@Param			order			query		string	false	"asc by default"		Enums(asc, desc)
+//	@Param			cursor			query		string	false	"next_cursor of the previous page, with the same filters, sort and order"
+//	@Param			limit			query		int		false	"between 1 and 50, 20 by default"
+//	@Param			include_total	query		bool	false	"also count every matching trx"
+//	@Param			currency		query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200				{object}	apiv1.TrxFeeList
+//	@Failure		400				{object}	ErrorResponse	"invalid_argument: malformed or out of bounds parameter, unknown symbol, start_time after end_time, cursor of other parameters or no rate of the currency at a trx time"
+//	@Failure		500				{object}	ErrorResponse	"internal"
+//	@Router			/v1/trxfee/list [get]
 func (c *trxFeeController) GetTrxFeeList(ctx *gin.Context) {
-	symbol := ctx.DefaultQuery("symbol", "WETH/USDC")
-	startTime, _ := strconv.ParseInt(ctx.DefaultQuery("start_time", "0"), 10, 64)
-	endTime, _ := strconv.ParseInt(ctx.DefaultQuery("end_time", "0"), 10, 64)
-	page, _ := strconv.ParseInt(ctx.DefaultQuery("page", "0"), 10, 64)
-	limit, _ := strconv.ParseInt(ctx.DefaultQuery("limit", "20"), 10, 64)
-	if endTime == 0 {
-		endTime = time.Now().Unix()
-	}
-	resp, err := c.svc.GetTrxFeeList(ctx, &service.GetTrxFeeListRequest{
-		Symbol:    symbol,
-		StartTime: startTime,
-		EndTime:   endTime,
-		Page:      int(page),
-		Limit:     int(limit),
-	})
+	req, err := parseGetTrxFeeListRequest(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeList(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiv1.NewTrxFeeList(resp))
+}
+
+// GetTrxFeeStats godoc
+//	@Summary		Get trx fee statistics
+//	@Description	get count, sum, min, max, mean, median, p90 and p99 of trx fees in USDT and ETH, with the average gas price and gas used, in the given time period
+//	@Accept			json
+//	@Produce		json
+//	@Param			symbol		query		string	false	"symbol, WETH/USDC by default"
+//	@Param			start_time	query		int		true	"start timestamp"
+//	@Param			end_time	query		int		false	"end timestamp, now by default"
+//	@Param			currency	query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200			{object}	service.GetTrxFeeStatsResponse
+//	@Failure		400			{object}	ErrorResponse	"invalid_argument: malformed timestamp, unknown symbol, start_time after end_time or no rate of the currency in the time range"
+//	@Failure		500			{object}	ErrorResponse	"internal"
+//	@Router			/v1/trxfee/stats [get]
+func (c *trxFeeController) GetTrxFeeStats(ctx *gin.Context) {
+	req, err := parseGetTrxFeeStatsRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeStats(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, resp)
 }
+
+// GetTrxFeeSeries godoc
+//	@Summary		Get a time series of trx fee statistics
+//	@Description	get count, sum, min, max and mean of trx fees in USDT and ETH, with the average gas price and gas used, for every bucket of the given time period, including empty ones
+//	@Accept			json
+//	@Produce		json
+//	@Param			symbol		query		string	false	"symbol, WETH/USDC by default"
+//	@Param			start_time	query		int		true	"start timestamp"
+//	@Param			end_time	query		int		false	"end timestamp, now by default"
+//	@Param			interval	query		string	false	"bucket size, hour by default, at most 1000 buckets"	Enums(minute, hour, day, week)
+//	@Param			timezone	query		string	false	"IANA timezone buckets are aligned in, UTC by default"
+//	@Param			currency	query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200			{object}	service.GetTrxFeeSeriesResponse
+//	@Failure		400			{object}	ErrorResponse	"invalid_argument: malformed timestamp, unknown symbol, interval or timezone, start_time after end_time, too many buckets or no rate of the currency in the time range"
+//	@Failure		500			{object}	ErrorResponse	"internal"
+//	@Router			/v1/trxfee/series [get]
+func (c *trxFeeController) GetTrxFeeSeries(ctx *gin.Context) {
+	req, err := parseGetTrxFeeSeriesRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeSeries(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func parseGetSingleTrxFeeRequest(ctx *gin.Context) (*service.GetSingleTrxFeeRequest, error) {
+	var uri trxHashURI
+	if err := bindRequest(&uri, ctx.ShouldBindUri); err != nil {
+		return nil, err
+	}
+	var query currencyQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		return nil, err
+	}
+	// hex hashes are valid in any case, they are looked up and stored in lower case
+	return &service.GetSingleTrxFeeRequest{TrxHash: strings.ToLower(uri.TrxHash), Currency: query.Currency}, nil
+}
+
+func parseGetTrxFeeBatchRequest(ctx *gin.Context) (*service.GetTrxFeeBatchRequest, error) {
+	var query currencyQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		return nil, err
+	}
+	var body trxFeeBatchBody
+	if err := bindRequest(&body, ctx.ShouldBindJSON); err != nil {
+		return nil, err
+	}
+	return &service.GetTrxFeeBatchRequest{TrxHashes: body.TrxHashes, Currency: query.Currency}, nil
+}
+
+// batchResultError returns the status and error the single query of a trx hash
+// would have responded with
+func batchResultError(err error) (int, *ErrorResponse) {
+	status, code := errorStatus(err)
+	return status, &ErrorResponse{Code: code, Msg: err.Error()}
+}
+
+func parseGetTrxFeeStatsRequest(ctx *gin.Context) (*service.GetTrxFeeStatsRequest, error) {
+	var query trxFeeStatsQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		return nil, err
+	}
+	return &service.GetTrxFeeStatsRequest{
+		Symbol:    query.Symbol,
+		StartTime: query.StartTime,
+		EndTime:   query.endTime(),
+		Currency:  query.Currency,
+	}, nil
+}
+
+func parseGetTrxFeeSeriesRequest(ctx *gin.Context) (*service.GetTrxFeeSeriesRequest, error) {
+	var query trxFeeSeriesQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		return nil, err
+	}
+	return &service.GetTrxFeeSeriesRequest{
+		Symbol:    query.Symbol,
+		StartTime: query.StartTime,
+		EndTime:   query.endTime(),
+		Interval:  query.Interval,
+		Timezone:  query.Timezone,
+		Currency:  query.Currency,
+	}, nil
+}
+
+func parseGetTrxFeeListRequest(ctx *gin.Context) (*service.GetTrxFeeListRequest, error) {
+	var query trxFeeListQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		return nil, err
+	}
+
+	req := &service.GetTrxFeeListRequest{
+		TrxFeeFilter: repository.TrxFeeFilter{
+			Symbol:      query.Symbol,
+			StartTime:   query.StartTime,
+			EndTime:     query.endTime(),
+			FromAddress: query.Address,
+			Status:      query.Status,
+			PriceSource: query.PriceSource,
+		},
+		Sort:         query.Sort,
+		Order:        query.Order,
+		Cursor:       query.Cursor,
+		Limit:        query.Limit,
+		IncludeTotal: query.IncludeTotal,
+		Currency:     query.Currency,
+	}
+
+	f := &req.TrxFeeFilter
+	err := errors.Join(
+		optionalQuery(ctx, "min_block", &f.MinBlock, util.ParseUint64),
+		optionalQuery(ctx, "max_block", &f.MaxBlock, util.ParseUint64),
+		optionalQuery(ctx, "min_fee_usdt", &f.MinFeeUsdt, decimal.NewFromString),
+		optionalQuery(ctx, "max_fee_usdt", &f.MaxFeeUsdt, decimal.NewFromString),
+		optionalQuery(ctx, "min_fee_eth", &f.MinFeeEth, decimal.NewFromString),
+		optionalQuery(ctx, "max_fee_eth", &f.MaxFeeEth, decimal.NewFromString),
+		optionalQuery(ctx, "min_gas_price", &f.MinGasPrice, util.ParseWeiDecimal),
+		optionalQuery(ctx, "max_gas_price", &f.MaxGasPrice, util.ParseWeiDecimal),
+		optionalQuery(ctx, "min_gas_used", &f.MinGasUsed, util.ParseUint64),
+		optionalQuery(ctx, "max_gas_used", &f.MaxGasUsed, util.ParseUint64),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// optionalQuery parses the query parameter into dest, leaving it nil if the parameter is absent
+func optionalQuery[T any](ctx *gin.Context, name string, dest **T, parse func(string) (T, error)) error {
+	s, ok := ctx.GetQuery(name)
+	if !ok || s == "" {
+		return nil
+	}
+	v, err := parse(s)
+	if err != nil {
+		return invalidParam(name, "invalid "+name)
+	}
+	*dest = &v
+	return nil
+}
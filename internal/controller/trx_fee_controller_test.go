@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/service"
+	"github.com/jaime1129/fedex/internal/util"
+	mock_components "github.com/jaime1129/fedex/mock/components"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(svc service.TrxFeeService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	c := NewTrxController(svc)
+	r := gin.New()
+	r.GET("/trxfee/:trx_hash", c.GetSingleTrxFee)
+	r.POST("/trxfee/batch", c.GetTrxFeeBatch)
+	r.GET("/trxfee/list", c.GetTrxFeeList)
+	r.GET("/trxfee/stats", c.GetTrxFeeStats)
+	r.GET("/trxfee/series", c.GetTrxFeeSeries)
+	r.GET("/fee/estimate", NewFeeEstimateController(svc).EstimateFee)
+	return r
+}
+
+func serve(t *testing.T, r *gin.Engine, method string, target string, body string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return w.Code, resp
+}
+
+func testTrxHash(n int) string {
+	return fmt.Sprintf("0x%064x", n)
+}
+
+func TestInvalidRequestsAreRejected(t *testing.T) {
+	r := newTestRouter(service.NewTrxService(nil, nil, repository.NewMemoryRepository(), nil))
+
+	for target, field := range map[string]string{
+		"/trxfee/not-a-hash":                                            "trx_hash",
+		"/trxfee/list?start_time=abc":                                   "",
+		"/trxfee/list?start_time=-1":                                    "start_time",
+		"/trxfee/list?symbol=DOGE/USDC":                                 "symbol",
+		"/trxfee/list?limit=51":                                         "limit",
+		"/trxfee/list?order=up":                                         "order",
+		"/trxfee/list?min_fee_usdt=cheap":                               "min_fee_usdt",
+		"/trxfee/list?start_time=2&end_time=1":                          "",
+		"/trxfee/stats?end_time=yesterday":                              "",
+		"/trxfee/series?start_time=1&interval=decade":                   "interval",
+		"/trxfee/series?start_time=1&timezone=Nowhere":                  "",
+		"/trxfee/stats?currency=JPY":                                    "currency",
+		"/trxfee/" + testTrxHash(1) + "?currency=eur":                   "currency",
+		"/fee/estimate?timestamp=1&gas_price=1":                         "gas_used",
+		"/fee/estimate?gas_used=1&gas_price=cheap":                      "timestamp",
+		"/fee/estimate?gas_used=1&timestamp=1&gas_price=cheap":          "gas_price",
+		"/fee/estimate?gas_used=1&timestamp=1&gas_price=-1":             "gas_price",
+		"/fee/estimate?gas_used=1&timestamp=1&gas_price_percentile=101": "gas_price_percentile",
+		"/fee/estimate?gas_used=1&timestamp=1":                          "",
+	} {
+		status, resp := serve(t, r, http.MethodGet, target, "")
+		assert.Equal(t, http.StatusBadRequest, status, target)
+		assert.Equal(t, string(CodeInvalidArgument), resp["code"], target)
+		assert.NotEmpty(t, resp["msg"], target)
+		if field != "" {
+			assert.Equal(t, field, resp["field"], target)
+		}
+	}
+
+	status, resp := serve(t, r, http.MethodPost, "/trxfee/batch", `{"trx_hashes": []}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "trx_hashes", resp["field"])
+}
+
+func TestErrorStatuses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	r := newTestRouter(service.NewTrxService(mockEthScanCli, nil, repository.NewMemoryRepository(), nil))
+
+	for i, tc := range []struct {
+		err    error
+		status int
+		code   ErrorCode
+	}{
+		{nil, http.StatusNotFound, CodeNotFound},
+		{fmt.Errorf("%w: 429 Too Many Requests", components.ErrRateLimited), http.StatusTooManyRequests, CodeRateLimited},
+		{components.ErrUpstream, http.StatusBadGateway, CodeUpstreamError},
+		{components.ErrUpstreamUnavailable, http.StatusServiceUnavailable, CodeUnavailable},
+	} {
+		hash := testTrxHash(i)
+		if tc.err == nil {
+			// no receipt
+			mockEthScanCli.EXPECT().QueryTrxFee(hash).Return(&components.EthScanTrxResponse{}, nil)
+		} else {
+			mockEthScanCli.EXPECT().QueryTrxFee(hash).Return(nil, tc.err)
+		}
+		status, resp := serve(t, r, http.MethodGet, "/trxfee/"+hash, "")
+		assert.Equal(t, tc.status, status, tc.code)
+		assert.Equal(t, string(tc.code), resp["code"])
+	}
+}
+
+func TestGetTrxFeeBatchReportsStatusPerHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	r := newTestRouter(service.NewTrxService(mockEthScanCli, nil, repository.NewMemoryRepository(), nil))
+
+	mockEthScanCli.EXPECT().QueryTrxFee(testTrxHash(1)).Return(nil, components.ErrRateLimited)
+	status, resp := serve(t, r, http.MethodPost, "/trxfee/batch", fmt.Sprintf(`{"trx_hashes": [%q, "0x1"]}`, testTrxHash(1)))
+	require.Equal(t, http.StatusOK, status)
+
+	results := resp["results"].([]interface{})
+	require.Len(t, results, 2)
+	limited, malformed := results[0].(map[string]interface{}), results[1].(map[string]interface{})
+	assert.Equal(t, float64(http.StatusTooManyRequests), limited["status"])
+	assert.Equal(t, string(CodeRateLimited), limited["error"].(map[string]interface{})["code"])
+	assert.Equal(t, float64(http.StatusBadRequest), malformed["status"])
+	assert.Equal(t, string(CodeInvalidArgument), malformed["error"].(map[string]interface{})["code"])
+}
+
+func TestV2KeepsV1Compatible(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	fee := repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: testTrxHash(1), TrxTime: 100, GasUsed: 21000, GasPrice: util.WeiFromUint64(1e9)}
+	fee.SetPrice(decimal.NewFromInt(2000), "binance_1m")
+	_, err := repo.BatchInsertUniTrxFee([]repository.UniTrxFee{fee}, repository.ConflictKeepExisting)
+	require.NoError(t, err)
+
+	svc := service.NewTrxService(nil, nil, repo, nil)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v1/trxfee/list", NewTrxController(svc).GetTrxFeeList)
+	r.GET("/v2/trxfee/list", NewTrxV2Controller(svc).GetTrxFeeList)
+
+	// v1 keeps the field names and encodings of its first release
+	status, resp := serve(t, r, http.MethodGet, "/v1/trxfee/list?end_time=200", "")
+	require.Equal(t, http.StatusOK, status)
+	v1Fee := resp["Result"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, testTrxHash(1), v1Fee["TrxHash"])
+	assert.Equal(t, "0.042", v1Fee["TrxFeeUsdt"])
+	assert.Equal(t, float64(1e9), v1Fee["GasPrice"])
+	assert.NotContains(t, v1Fee, "ID")
+
+	status, resp = serve(t, r, http.MethodGet, "/v2/trxfee/list?end_time=200", "")
+	require.Equal(t, http.StatusOK, status)
+	v2Fee := resp["items"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, testTrxHash(1), v2Fee["trx_hash"])
+	assert.Equal(t, "0.042", v2Fee["fee_usdt"])
+	assert.Equal(t, map[string]interface{}{"unix": float64(100), "rfc3339": "1970-01-01T00:01:40Z"}, v2Fee["trx_time"])
+}
+
+func TestGetSingleTrxFeeNormalizesHashCase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	repo := repository.NewMemoryRepository()
+	r := newTestRouter(service.NewTrxService(mockEthScanCli, mockBnPriceCli, repo, nil))
+
+	hash := "0x" + strings.Repeat("ab", 32)
+	// looked up once, in lower case
+	mockEthScanCli.EXPECT().QueryTrxFee(hash).Return(&components.EthScanTrxResponse{
+		Result: components.EthScanTrxResult{
+			GasUsed:           "0x5208",
+			EffectiveGasPrice: "0x3B9ACA00",
+			BlockNumber:       "0x10FB78",
+			Logs:              []components.EthScanTrxLog{{Address: "0x88E6A0c2dDD26FEEb64F039a2c41296FcB3f5640"}},
+		},
+	}, nil).Times(1)
+	mockEthScanCli.EXPECT().QueryBlock("0x10FB78").Return(&components.EthScanBlockResponse{
+		Result: components.EthScanBlockResult{Timestamp: "0x5BA46680"},
+	}, nil).Times(1)
+	mockBnPriceCli.EXPECT().QueryETHPrice(gomock.Any(), gomock.Any(), "1m").Return(decimal.NewFromInt(2000), nil).Times(1)
+
+	status, resp := serve(t, r, http.MethodGet, "/trxfee/0x"+strings.Repeat("AB", 32), "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, hash, resp["trx_hash"])
+	assert.Equal(t, string(service.DataSourceLive), resp["data_source"])
+
+	// the other casing is the same stored fee, not a second row
+	status, resp = serve(t, r, http.MethodGet, "/trxfee/0x"+strings.Repeat("aB", 32), "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, string(service.DataSourceDatabase), resp["data_source"])
+	fees, err := repo.ListTrxFee(repository.TrxFeeListQuery{Filter: repository.TrxFeeFilter{Symbol: "WETH/USDC", EndTime: 1 << 40}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, fees, 1)
+	assert.Equal(t, hash, fees[0].TrxHash)
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jaime1129/fedex/internal/apiv1"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/service"
+	"github.com/shopspring/decimal"
+)
+
+const wsWriteTimeout = 10 * time.Second
+
+type TrxFeeStreamController interface {
+	StreamTrxFeeSSE(ctx *gin.Context)
+	StreamTrxFeeWS(ctx *gin.Context)
+}
+
+type trxFeeStreamController struct {
+	svc      service.TrxFeeStreamService
+	upgrader websocket.Upgrader
+}
+
+func NewTrxFeeStreamController(svc service.TrxFeeStreamService) TrxFeeStreamController {
+	return &trxFeeStreamController{
+		svc: svc,
+		upgrader: websocket.Upgrader{
+			// the stream is read-only public data
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// StreamTrxFeeSSE godoc
+//	@Summary		Stream newly ingested trx fees over SSE
+//	@Description	push each trx fee as it is committed; reconnecting clients resume after the Last-Event-ID header and may receive the fees of the 5 minutes before it again
+//	@Produce		text/event-stream
+//	@Param			symbol			query		string	false	"symbol, all by default"
+//	@Param			min_fee			query		string	false	"minimum fee in USDT"
+//	@Param			address			query		string	false	"sender address"
+//	@Param			last_event_id	query		int		false	"resume after this event id, overridden by the Last-Event-ID header"
+//	@Success		200				{object}	apiv1.StreamedTrxFee
+//	@Failure		400				{object}	ErrorResponse	"invalid_argument: unknown symbol, malformed min_fee or last event id"
+//	@Router			/v1/trxfee/stream [get]
+func (c *trxFeeStreamController) StreamTrxFeeSSE(ctx *gin.Context) {
+	req, err := parseStreamTrxFeeRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	err = c.svc.StreamTrxFee(ctx.Request.Context(), req, func(fee *repository.UniTrxFee) error {
+		ctx.Render(-1, sse.Event{
+			Id:    strconv.FormatUint(fee.ID, 10),
+			Event: "trx_fee",
+			Data:  apiv1.NewStreamedTrxFee(fee),
+		})
+		ctx.Writer.Flush()
+		return ctx.Request.Context().Err()
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Println("trx fee sse stream err: " + err.Error())
+	}
+}
+
+// StreamTrxFeeWS godoc
+//	@Summary		Stream newly ingested trx fees over WebSocket
+//	@Description	upgrade to a WebSocket that receives each trx fee as a JSON message as it is committed
+//	@Param			symbol			query		string	false	"symbol, all by default"
+//	@Param			min_fee			query		string	false	"minimum fee in USDT"
+//	@Param			address			query		string	false	"sender address"
+//	@Param			last_event_id	query		int		false	"resume after this event id, the fees of the 5 minutes before it may be received again"
+//	@Success		101				{object}	apiv1.StreamedTrxFee
+//	@Failure		400				{object}	ErrorResponse	"invalid_argument: unknown symbol, malformed min_fee or last event id"
+//	@Router			/v1/trxfee/stream/ws [get]
+func (c *trxFeeStreamController) StreamTrxFeeWS(ctx *gin.Context) {
+	req, err := parseStreamTrxFeeRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	conn, err := c.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// the upgrader has already replied to the client
+		log.Println("websocket upgrade err: " + err.Error())
+		return
+	}
+	defer conn.Close()
+
+	// the client never sends anything meaningful, reading only detects that it went away
+	streamCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	err = c.svc.StreamTrxFee(streamCtx, req, func(fee *repository.UniTrxFee) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(apiv1.NewStreamedTrxFee(fee))
+	})
+	if err != nil {
+		log.Println("trx fee websocket stream err: " + err.Error())
+		return
+	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteTimeout))
+}
+
+func parseStreamTrxFeeRequest(ctx *gin.Context) (*service.StreamTrxFeeRequest, error) {
+	var query streamTrxFeeQuery
+	if err := bindRequest(&query, ctx.ShouldBindQuery); err != nil {
+		return nil, err
+	}
+	req := &service.StreamTrxFeeRequest{
+		Symbol:      query.Symbol,
+		FromAddress: query.Address,
+		LastEventID: query.LastEventID,
+	}
+
+	if query.MinFee != "" {
+		fee, err := decimal.NewFromString(query.MinFee)
+		if err != nil {
+			return nil, invalidParam("min_fee", "invalid min_fee")
+		}
+		req.MinFeeUsdt = fee
+	}
+
+	if lastEventID := ctx.GetHeader("Last-Event-ID"); lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return nil, invalidParam("Last-Event-ID", "invalid last event id")
+		}
+		req.LastEventID = &id
+	}
+
+	return req, nil
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaime1129/fedex/internal/apiv2"
+	"github.com/jaime1129/fedex/internal/service"
+)
+
+// TrxFeeV2Controller serves the fee queries of /api/v2, which take the same
+// parameters as those of /api/v1 and respond with the models of apiv2
+type TrxFeeV2Controller interface {
+	GetSingleTrxFee(ctx *gin.Context)
+	GetTrxFeeBatch(ctx *gin.Context)
+	GetTrxFeeList(ctx *gin.Context)
+	GetTrxFeeStats(ctx *gin.Context)
+	GetTrxFeeSeries(ctx *gin.Context)
+}
+
+type trxFeeV2Controller struct {
+	svc service.TrxFeeService
+}
+
+func NewTrxV2Controller(svc service.TrxFeeService) TrxFeeV2Controller {
+	return &trxFeeV2Controller{
+		svc: svc,
+	}
+}
+
+// GetSingleTrxFee godoc
+//	@Summary		Get trx fee of single trx
+//	@Description	get the fee of a trx with how it was priced and the tracked pools it touched, looking it up on etherscan and binance if it isn't stored
+//	@Produce		json
+//	@Param			trx_hash	path		string	true	"trx hash, 0x followed by 64 hex digits"
+//	@Param			currency	query		string	false	"currency to also convert the fee to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200			{object}	apiv2.TrxFeeDetail
+//	@Failure		400			{object}	ErrorResponse	"invalid_argument: malformed trx hash, unsupported currency or no rate of the currency at the trx time"
+//	@Failure		404			{object}	ErrorResponse	"not_found: etherscan has no receipt for the trx"
+//	@Failure		429			{object}	ErrorResponse	"rate_limited: etherscan or binance rate limited the lookup"
+//	@Failure		500			{object}	ErrorResponse	"internal"
+//	@Failure		502			{object}	ErrorResponse	"upstream_error: etherscan or binance failed the lookup"
+//	@Failure		503			{object}	ErrorResponse	"unavailable: etherscan or binance could not be reached"
+//	@Router			/v2/trxfee/{trx_hash} [get]
+func (c *trxFeeV2Controller) GetSingleTrxFee(ctx *gin.Context) {
+	req, err := parseGetSingleTrxFeeRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetSingleTrxFee(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiv2.NewTrxFeeDetail(resp))
+}
+
+// GetTrxFeeBatch godoc
+//	@Summary		Get trx fees of several trxs
+//	@Description	get the fees of up to 100 trx hashes, stored ones are served from the database and the others looked up concurrently; each result has the status and error the single query of its hash would have responded with
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		trxFeeBatchBody	true	"trx hashes"
+//	@Param			currency	query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200		{object}	apiv2.TrxFeeBatch
+//	@Failure		400		{object}	ErrorResponse	"invalid_argument: no or more than 100 trx hashes or unsupported currency"
+//	@Failure		500		{object}	ErrorResponse	"internal"
+//	@Router			/v2/trxfee/batch [post]
+func (c *trxFeeV2Controller) GetTrxFeeBatch(ctx *gin.Context) {
+	req, err := parseGetTrxFeeBatchRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeBatch(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	results := make([]apiv2.TrxFeeBatchResult, len(resp.Results))
+	for i, res := range resp.Results {
+		results[i] = apiv2.TrxFeeBatchResult{TrxHash: res.TrxHash, Status: http.StatusOK}
+		if res.Err != nil {
+			status, e := batchResultError(res.Err)
+			results[i].Status, results[i].Error = status, &apiv2.Error{Code: string(e.Code), Msg: e.Msg}
+			continue
+		}
+		fee := apiv2.NewTrxFee(res.Fee)
+		fee.Conversion = apiv2.NewFeeConversion(res.Conversion)
+		results[i].Fee = &fee
+	}
+	ctx.JSON(http.StatusOK, apiv2.TrxFeeBatch{Results: results})
+}
+
+// GetTrxFeeList godoc
+//	@Summary		Get a list of trx fee
+//	@Description	get trx fees by given time period and filters, ordered by the sort field then id; pass next_cursor as cursor to get the next page
+//	@Produce		json
+//	@Param			symbol			query		string	false	"symbol, WETH/USDC by default"
+//	@Param			start_time		query		int		true	"start timestamp"
+//	@Param			end_time		query		int		false	"end timestamp, now by default"
+//	@Param			min_block		query		int		false	"minimum block number"
+//	@Param			max_block		query		int		false	"maximum block number"
+//	@Param			min_fee_usdt	query		string	false	"minimum fee in USDT"
+//	@Param			max_fee_usdt	query		string	false	"maximum fee in USDT"
+//	@Param			min_fee_eth		query		string	false	"minimum fee in ETH"
+//	@Param			max_fee_eth		query		string	false	"maximum fee in ETH"
+//	@Param			min_gas_price	query		string	false	"minimum gas price in wei"
+//	@Param			max_gas_price	query		string	false	"maximum gas price in wei"
+//	@Param			min_gas_used	query		int		false	"minimum gas used"
+//	@Param			max_gas_used	query		int		false	"maximum gas used"
+//	@Param			address			query		string	false	"sender address"
+//	@Param			status			query		string	false	"transaction status"	Enums(success, failed, unknown)
+//	@Param			price_source	query		string	false	"source of the ETH price, like binance_1m or binance_12h"
+//	@Param			sort			query		string	false	"trx_time by default"	Enums(trx_time, block_num, gas_used, gas_price, trx_fee_eth, trx_fee_usdt)
+//	@Param			order			query		string	false	"asc by default"		Enums(asc, desc)
+//	@Param			cursor			query		string	false	"next_cursor of the previous page, with the same filters, sort and order"
+//	@Param			limit			query		int		false	"between 1 and 50, 20 by default"
+//	@Param			include_total	query		bool	false	"also count every matching trx"
+//	@Param			currency		query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200				{object}	apiv2.TrxFeeList
+//	@Failure		400				{object}	ErrorResponse	"invalid_argument: malformed or out of bounds parameter, unknown symbol, start_time after end_time, cursor of other parameters or no rate of the currency at a trx time"
+//	@Failure		500				{object}	ErrorResponse	"internal"
+//	@Router			/v2/trxfee/list [get]
+func (c *trxFeeV2Controller) GetTrxFeeList(ctx *gin.Context) {
+	req, err := parseGetTrxFeeListRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeList(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiv2.NewTrxFeeList(resp))
+}
+
+// GetTrxFeeStats godoc
+//	@Summary		Get trx fee statistics
+//	@Description	get count, sum, min, max, mean, median, p90 and p99 of trx fees in USDT and ETH, with the average gas price and gas used, in the given time period
+//	@Produce		json
+//	@Param			symbol		query		string	false	"symbol, WETH/USDC by default"
+//	@Param			start_time	query		int		true	"start timestamp"
+//	@Param			end_time	query		int		false	"end timestamp, now by default"
+//	@Param			currency	query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200			{object}	apiv2.TrxFeeStats
+//	@Failure		400			{object}	ErrorResponse	"invalid_argument: malformed timestamp, unknown symbol, start_time after end_time or no rate of the currency in the time range"
+//	@Failure		500			{object}	ErrorResponse	"internal"
+//	@Router			/v2/trxfee/stats [get]
+func (c *trxFeeV2Controller) GetTrxFeeStats(ctx *gin.Context) {
+	req, err := parseGetTrxFeeStatsRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeStats(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiv2.NewTrxFeeStats(req, resp))
+}
+
+// GetTrxFeeSeries godoc
+//	@Summary		Get a time series of trx fee statistics
+//	@Description	get count, sum, min, max and mean of trx fees in USDT and ETH, with the average gas price and gas used, for every bucket of the given time period, including empty ones
+//	@Produce		json
+//	@Param			symbol		query		string	false	"symbol, WETH/USDC by default"
+//	@Param			start_time	query		int		true	"start timestamp"
+//	@Param			end_time	query		int		false	"end timestamp, now by default"
+//	@Param			interval	query		string	false	"bucket size, hour by default, at most 1000 buckets"	Enums(minute, hour, day, week)
+//	@Param			timezone	query		string	false	"IANA timezone buckets are aligned in, UTC by default"
+//	@Param			currency	query		string	false	"currency to also convert the fees to, besides USDT and ETH"	Enums(USDT, USDC, DAI, EUR, GBP)
+//	@Success		200			{object}	apiv2.TrxFeeSeries
+//	@Failure		400			{object}	ErrorResponse	"invalid_argument: malformed timestamp, unknown symbol, interval or timezone, start_time after end_time, too many buckets or no rate of the currency in the time range"
+//	@Failure		500			{object}	ErrorResponse	"internal"
+//	@Router			/v2/trxfee/series [get]
+func (c *trxFeeV2Controller) GetTrxFeeSeries(ctx *gin.Context) {
+	req, err := parseGetTrxFeeSeriesRequest(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	resp, err := c.svc.GetTrxFeeSeries(ctx, req)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiv2.NewTrxFeeSeries(req, resp))
+}
@@ -0,0 +1,83 @@
+// Package currency converts fees to other currencies than USDT. Fees are
+// stored in ETH with the ETH price in USDT they were priced with, so a fee in
+// any currency is derived from its ETH value, that price and the rate of the
+// currency to USDT at the trx time, which a RateSource tells.
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	USDT = "USDT"
+	USDC = "USDC"
+	DAI  = "DAI"
+	EUR  = "EUR"
+	GBP  = "GBP"
+)
+
+// Supported are the currencies fees can be requested in
+var Supported = []string{USDT, USDC, DAI, EUR, GBP}
+
+// ErrNoRate is returned when a currency has no rate at the requested time
+var ErrNoRate = errors.New("no rate")
+
+// decimal places of converted amounts, as many as stored fees have
+const places = 18
+
+func IsSupported(currency string) bool {
+	for _, c := range Supported {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// RateSource tells how many units of a currency one USDT was worth at a unix time
+type RateSource interface {
+	Rate(currency string, at int64) (decimal.Decimal, error)
+}
+
+// Converter converts amounts to the supported currencies with the rate source
+// of each of them. USDT needs none.
+type Converter struct {
+	sources map[string]RateSource
+}
+
+func NewConverter(sources map[string]RateSource) *Converter {
+	return &Converter{sources: sources}
+}
+
+// Rate returns how many units of currency one USDT was worth at a unix time
+func (c *Converter) Rate(currency string, at int64) (decimal.Decimal, error) {
+	if currency == USDT {
+		return decimal.NewFromInt(1), nil
+	}
+	src, ok := c.sources[currency]
+	if !ok {
+		if !IsSupported(currency) {
+			return decimal.Zero, fmt.Errorf("%w: unsupported currency %s, must be one of %s", ErrNoRate, currency, strings.Join(Supported, ", "))
+		}
+		return decimal.Zero, fmt.Errorf("%w: no source of %s rates is configured", ErrNoRate, currency)
+	}
+	return src.Rate(currency, at)
+}
+
+// FromETH converts an amount of ETH priced at ethUsdtPrice at a unix time
+func (c *Converter) FromETH(eth decimal.Decimal, ethUsdtPrice decimal.Decimal, currency string, at int64) (amount decimal.Decimal, rate decimal.Decimal, err error) {
+	rate, err = c.Rate(currency, at)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	return eth.Mul(ethUsdtPrice).Mul(rate).Round(places), rate, nil
+}
+
+// FromUSDT converts an amount of USDT with a rate returned by Rate
+func FromUSDT(usdt decimal.Decimal, rate decimal.Decimal) decimal.Decimal {
+	return usdt.Mul(rate).Round(places)
+}
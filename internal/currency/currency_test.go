@@ -0,0 +1,133 @@
+package currency
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jaime1129/fedex/internal/components"
+	mock_components "github.com/jaime1129/fedex/mock/components"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter(t *testing.T) {
+	conv := NewConverter(map[string]RateSource{
+		DAI: NewPegSource(),
+		EUR: NewTableSource(map[string][]TableRate{
+			EUR: {{Since: 1000, Rate: decimal.RequireFromString("0.8")}, {Since: 0, Rate: decimal.RequireFromString("0.9")}},
+		}),
+	})
+
+	for _, tc := range []struct {
+		currency string
+		at       int64
+		amount   string
+		rate     string
+	}{
+		{USDT, 500, "42", "1"},
+		{DAI, 500, "42", "1"},
+		{EUR, 500, "37.8", "0.9"},
+		{EUR, 1000, "33.6", "0.8"},
+	} {
+		// 0.021 ETH at 2000 USDT
+		amount, rate, err := conv.FromETH(decimal.RequireFromString("0.021"), decimal.NewFromInt(2000), tc.currency, tc.at)
+		require.NoError(t, err, tc.currency)
+		assert.Equal(t, tc.amount, amount.String(), tc.currency)
+		assert.Equal(t, tc.rate, rate.String(), tc.currency)
+	}
+
+	_, err := conv.Rate(EUR, -1)
+	assert.ErrorIs(t, err, ErrNoRate)
+	// supported but not configured
+	_, err = conv.Rate(GBP, 500)
+	assert.ErrorIs(t, err, ErrNoRate)
+	_, err = conv.Rate("JPY", 500)
+	assert.ErrorIs(t, err, ErrNoRate)
+}
+
+func TestBinanceSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	src := NewBinanceSource(mockBnPriceCli, map[string]string{USDC: "USDCUSDT"})
+
+	// 2021-01-01 and 2021-01-02 are in the block of days starting 2019-04-14
+	blockStart := int64(18000 * 86400)
+	mockBnPriceCli.EXPECT().QueryKlines("USDCUSDT", blockStart, blockStart+1000*86400-1, components.INTERVAL_1DAY).Return([]components.Kline{
+		{OpenTime: 1609459200, Open: decimal.RequireFromString("0.99"), Close: decimal.RequireFromString("1.01")},
+		{OpenTime: 1609545600, Open: decimal.RequireFromString("1.24"), Close: decimal.RequireFromString("1.26")},
+	}, nil).Times(1)
+
+	// the block is fetched once
+	rate, err := src.Rate(USDC, 1609459200+3600)
+	require.NoError(t, err)
+	assert.Equal(t, "1", rate.String())
+	rate, err = src.Rate(USDC, 1609545600)
+	require.NoError(t, err)
+	assert.Equal(t, "0.8", rate.String())
+
+	// a day binance has no kline of
+	_, err = src.Rate(USDC, 1609632000)
+	assert.ErrorIs(t, err, ErrNoRate)
+
+	// upstream errors are returned as they are
+	mockBnPriceCli.EXPECT().QueryKlines("USDCUSDT", gomock.Any(), gomock.Any(), components.INTERVAL_1DAY).Return(nil, components.ErrRateLimited)
+	_, err = src.Rate(USDC, 0)
+	assert.ErrorIs(t, err, components.ErrRateLimited)
+}
+
+func TestBinanceSourceFetchesWithoutLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	src := NewBinanceSource(mockBnPriceCli, map[string]string{USDC: "USDCUSDT"})
+
+	blockStart := int64(18000 * 86400)
+	mockBnPriceCli.EXPECT().QueryKlines("USDCUSDT", blockStart, gomock.Any(), components.INTERVAL_1DAY).Return([]components.Kline{
+		{OpenTime: 1609459200, Open: decimal.RequireFromString("0.99"), Close: decimal.RequireFromString("1.01")},
+	}, nil).Times(1)
+	_, err := src.Rate(USDC, 1609459200)
+	require.NoError(t, err)
+
+	// the fetch of the next block hangs, and is shared by its concurrent rates
+	release := make(chan struct{})
+	called := make(chan struct{})
+	mockBnPriceCli.EXPECT().QueryKlines("USDCUSDT", blockStart+1000*86400, gomock.Any(), components.INTERVAL_1DAY).DoAndReturn(
+		func(string, int64, int64, string) ([]components.Kline, error) {
+			close(called)
+			<-release
+			return []components.Kline{{OpenTime: blockStart + 1000*86400, Open: decimal.NewFromInt(1), Close: decimal.NewFromInt(1)}}, nil
+		}).Times(1)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rate, err := src.Rate(USDC, blockStart+1000*86400)
+			assert.NoError(t, err)
+			assert.Equal(t, "1", rate.String())
+		}()
+	}
+	<-called
+
+	// meanwhile the kept rates are served
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rate, err := src.Rate(USDC, 1609459200)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", rate.String())
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a kept rate waits for the fetch of another block")
+	}
+	close(release)
+	wg.Wait()
+}
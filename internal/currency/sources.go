@@ -0,0 +1,181 @@
+package currency
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultSources returns the rate sources of the supported currencies: USDC
+// from binance, DAI pegged to USDT as binance has no DAI pair anymore, and the
+// fiat currencies of the local rate table, which is keyed by currency
+func DefaultSources(cli components.BnPriceCli, fiat map[string][]TableRate) map[string]RateSource {
+	sources := map[string]RateSource{
+		USDC: NewBinanceSource(cli, map[string]string{USDC: "USDCUSDT"}),
+		DAI:  NewPegSource(),
+	}
+	table := NewTableSource(fiat)
+	for c := range fiat {
+		sources[c] = table
+	}
+	return sources
+}
+
+type pegSource struct{}
+
+// NewPegSource returns a source of currencies worth one USDT at any time
+func NewPegSource() RateSource {
+	return pegSource{}
+}
+
+func (pegSource) Rate(string, int64) (decimal.Decimal, error) {
+	return decimal.NewFromInt(1), nil
+}
+
+// TableRate is the rate of a currency from a unix time until the next rate of
+// the table
+type TableRate struct {
+	Since int64
+	// units of the currency one USDT is worth
+	Rate decimal.Decimal
+}
+
+type tableSource struct {
+	// by currency, sorted by time
+	rates map[string][]TableRate
+}
+
+// NewTableSource returns a source of the rates of a local table, for
+// currencies without a market to read them from. A currency has no rate
+// before its first one.
+func NewTableSource(table map[string][]TableRate) RateSource {
+	rates := make(map[string][]TableRate, len(table))
+	for c, r := range table {
+		r = append([]TableRate(nil), r...)
+		sort.Slice(r, func(i, j int) bool { return r[i].Since < r[j].Since })
+		rates[c] = r
+	}
+	return &tableSource{rates: rates}
+}
+
+func (s *tableSource) Rate(currency string, at int64) (decimal.Decimal, error) {
+	rates := s.rates[currency]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Since > at })
+	if i == 0 {
+		return decimal.Zero, fmt.Errorf("%w: no %s rate before %d in the rate table", ErrNoRate, currency, at)
+	}
+	return rates[i-1].Rate, nil
+}
+
+const (
+	day = int64(24 * time.Hour / time.Second)
+	// days of the klines fetched at once, as many as binance returns per call
+	binanceBlockDays = 1000
+	// a block of days is fetched again after a miss, for the days that weren't
+	// over when it was last fetched
+	binanceRefetchAfter = time.Hour
+)
+
+// binanceSource reads the rates of stablecoins from the daily binance klines
+// of their USDT pairs, the rate of a day being the mean of its open and close
+// prices. Days are fetched by blocks of 1000 and kept in memory, so that the
+// fees of a list or a series cost a call or two.
+type binanceSource struct {
+	cli components.BnPriceCli
+	// binance symbol of each currency, quoted in USDT like USDCUSDT
+	symbols map[string]string
+
+	// fetches of a block of days share a single call, which holds no lock
+	fetches singleflight.Group
+
+	mu sync.Mutex
+	// by symbol and UTC day
+	rates map[string]map[int64]decimal.Decimal
+	// when each block of days of a symbol was last fetched
+	fetched map[string]map[int64]time.Time
+}
+
+func NewBinanceSource(cli components.BnPriceCli, symbols map[string]string) RateSource {
+	return &binanceSource{
+		cli:     cli,
+		symbols: symbols,
+		rates:   make(map[string]map[int64]decimal.Decimal),
+		fetched: make(map[string]map[int64]time.Time),
+	}
+}
+
+func (s *binanceSource) Rate(currency string, at int64) (decimal.Decimal, error) {
+	symbol, ok := s.symbols[currency]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%w: no binance pair of %s", ErrNoRate, currency)
+	}
+	d := at - at%day
+	block := d / (binanceBlockDays * day)
+
+	rate, ok, stale := s.cached(symbol, d, block)
+	if ok {
+		return rate, nil
+	}
+	if stale {
+		_, err, _ := s.fetches.Do(fmt.Sprintf("%s/%d", symbol, block), func() (interface{}, error) {
+			// a fetch that ended while this one waited to start is enough
+			if _, _, stale := s.cached(symbol, d, block); !stale {
+				return nil, nil
+			}
+			return nil, s.fetchBlock(symbol, block)
+		})
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if rate, ok, _ = s.cached(symbol, d, block); ok {
+			return rate, nil
+		}
+	}
+	return decimal.Zero, fmt.Errorf("%w: binance has no %s kline at %d", ErrNoRate, symbol, at)
+}
+
+// cached returns the kept rate of a day, and whether its block of days is due
+// to be fetched
+func (s *binanceSource) cached(symbol string, d, block int64) (rate decimal.Decimal, ok, stale bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rate, ok = s.rates[symbol][d]; ok {
+		return rate, true, false
+	}
+	fetchedAt, fetched := s.fetched[symbol][block]
+	return decimal.Zero, false, !fetched || time.Since(fetchedAt) > binanceRefetchAfter
+}
+
+func (s *binanceSource) fetchBlock(symbol string, block int64) error {
+	start := block * binanceBlockDays * day
+	klines, err := s.cli.QueryKlines(symbol, start, start+binanceBlockDays*day-1, components.INTERVAL_1DAY)
+	if err != nil {
+		return err
+	}
+	rates := make(map[int64]decimal.Decimal, len(klines))
+	two := decimal.NewFromInt(2)
+	for _, k := range klines {
+		price := k.Open.Add(k.Close).Div(two)
+		if price.IsPositive() {
+			// the pair is quoted in USDT, the rate is the other way around
+			rates[k.OpenTime] = decimal.NewFromInt(1).DivRound(price, places)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rates[symbol] == nil {
+		s.rates[symbol] = make(map[int64]decimal.Decimal)
+		s.fetched[symbol] = make(map[int64]time.Time)
+	}
+	for d, rate := range rates {
+		s.rates[symbol][d] = rate
+	}
+	s.fetched[symbol][block] = time.Now()
+	return nil
+}
@@ -0,0 +1,210 @@
+package eventbus
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy decides what happens when a subscriber's buffer is full
+type Policy int
+
+const (
+	// DropNewest discards the event being published
+	DropNewest Policy = iota
+	// DropOldest discards the oldest buffered event to make room
+	DropOldest
+	// Block makes the publisher wait for room, up to SubscribeOptions.BlockTimeout
+	Block
+)
+
+const defaultBufferSize = 64
+
+type SubscribeOptions struct {
+	// capacity of the subscriber channel, 64 by default
+	BufferSize int
+	Policy     Policy
+	// only used by Block, 0 waits until the subscriber has room or unsubscribes
+	BlockTimeout time.Duration
+	// event types to deliver, all of them if empty
+	Types []EventType
+}
+
+type Publisher interface {
+	Publish(event Event)
+}
+
+type Bus interface {
+	Publisher
+	Subscribe(name string, opts SubscribeOptions) Subscription
+	// Close unsubscribes every subscriber; later publishes are dropped
+	Close()
+}
+
+type Subscription interface {
+	Events() <-chan Event
+	// Dropped returns how many events were discarded for this subscriber
+	Dropped() uint64
+	Unsubscribe()
+}
+
+type bus struct {
+	mu     sync.RWMutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+func New() Bus {
+	return &bus{
+		subs: make(map[*subscription]struct{}),
+	}
+}
+
+func (b *bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if sub.accepts(event.Type()) {
+			sub.deliver(event)
+		}
+	}
+}
+
+func (b *bus) Subscribe(name string, opts SubscribeOptions) Subscription {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	sub := &subscription{
+		bus:    b,
+		name:   name,
+		opts:   opts,
+		events: make(chan Event, opts.BufferSize),
+		done:   make(chan struct{}),
+	}
+	if len(opts.Types) > 0 {
+		sub.types = make(map[EventType]struct{}, len(opts.Types))
+		for _, t := range opts.Types {
+			sub.types[t] = struct{}{}
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+func (b *bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*subscription, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+}
+
+func (b *bus) remove(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	// no publisher holds the read lock anymore, so nothing can send on events
+	close(sub.events)
+}
+
+type subscription struct {
+	bus     *bus
+	name    string
+	opts    SubscribeOptions
+	types   map[EventType]struct{}
+	events  chan Event
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	dropped atomic.Uint64
+}
+
+func (s *subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *subscription) Unsubscribe() {
+	s.once.Do(func() {
+		// release publishers blocked on this subscriber before taking the bus lock
+		close(s.done)
+		s.bus.remove(s)
+	})
+}
+
+func (s *subscription) accepts(t EventType) bool {
+	if s.types == nil {
+		return true
+	}
+	_, ok := s.types[t]
+	return ok
+}
+
+func (s *subscription) deliver(event Event) {
+	select {
+	case s.events <- event:
+		return
+	case <-s.done:
+		return
+	default:
+	}
+
+	switch s.opts.Policy {
+	case DropOldest:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for {
+			select {
+			case s.events <- event:
+				return
+			default:
+			}
+			select {
+			case <-s.events:
+				s.drop(event)
+			default:
+			}
+		}
+	case Block:
+		var timeout <-chan time.Time
+		if s.opts.BlockTimeout > 0 {
+			timer := time.NewTimer(s.opts.BlockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case s.events <- event:
+		case <-s.done:
+		case <-timeout:
+			s.drop(event)
+		}
+	default:
+		s.drop(event)
+	}
+}
+
+func (s *subscription) drop(event Event) {
+	if s.dropped.Add(1)%100 == 1 {
+		log.Printf("event bus subscriber %s is full, dropped %d events so far (latest %s)\n", s.name, s.dropped.Load(), event.Type())
+	}
+}
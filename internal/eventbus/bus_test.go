@@ -0,0 +1,107 @@
+package eventbus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishFiltersByType(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	all := b.Subscribe("all", SubscribeOptions{})
+	reorgs := b.Subscribe("reorgs", SubscribeOptions{Types: []EventType{EventReorgRollback}})
+
+	b.Publish(FeeIngested{Symbol: "WETH/USDC"})
+	b.Publish(ReorgRollback{Symbol: "WETH/USDC"})
+
+	assert.Equal(t, EventFeeIngested, (<-all.Events()).Type())
+	assert.Equal(t, EventReorgRollback, (<-all.Events()).Type())
+	assert.Equal(t, EventReorgRollback, (<-reorgs.Events()).Type())
+	assert.Len(t, reorgs.Events(), 0)
+}
+
+func TestDropNewest(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	sub := b.Subscribe("sub", SubscribeOptions{BufferSize: 1, Policy: DropNewest})
+	b.Publish(FeeIngested{Source: "first"})
+	b.Publish(FeeIngested{Source: "second"})
+
+	assert.Equal(t, uint64(1), sub.Dropped())
+	assert.Equal(t, "first", (<-sub.Events()).(FeeIngested).Source)
+}
+
+func TestDropOldest(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	sub := b.Subscribe("sub", SubscribeOptions{BufferSize: 1, Policy: DropOldest})
+	b.Publish(FeeIngested{Source: "first"})
+	b.Publish(FeeIngested{Source: "second"})
+
+	assert.Equal(t, uint64(1), sub.Dropped())
+	assert.Equal(t, "second", (<-sub.Events()).(FeeIngested).Source)
+}
+
+func TestBlockWithTimeout(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	sub := b.Subscribe("sub", SubscribeOptions{BufferSize: 1, Policy: Block, BlockTimeout: 10 * time.Millisecond})
+	b.Publish(FeeIngested{Source: "first"})
+
+	go func() {
+		time.Sleep(time.Millisecond)
+		<-sub.Events()
+	}()
+	b.Publish(FeeIngested{Source: "second"})
+	assert.Equal(t, uint64(0), sub.Dropped())
+
+	b.Publish(FeeIngested{Source: "third"})
+	assert.Equal(t, uint64(1), sub.Dropped())
+}
+
+func TestUnsubscribeReleasesBlockedPublisher(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	sub := b.Subscribe("sub", SubscribeOptions{BufferSize: 1, Policy: Block})
+	b.Publish(FeeIngested{})
+
+	published := make(chan struct{})
+	go func() {
+		b.Publish(FeeIngested{})
+		close(published)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	sub.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publisher still blocked after unsubscribe")
+	}
+
+	// the channel is closed once drained
+	<-sub.Events()
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+}
+
+func TestCloseStopsDelivery(t *testing.T) {
+	b := New()
+	sub := b.Subscribe("sub", SubscribeOptions{})
+	b.Close()
+
+	b.Publish(FeeIngested{})
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+
+	late := b.Subscribe("late", SubscribeOptions{})
+	_, ok = <-late.Events()
+	assert.False(t, ok)
+}
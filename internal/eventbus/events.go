@@ -0,0 +1,57 @@
+package eventbus
+
+import "github.com/jaime1129/fedex/internal/repository"
+
+type EventType string
+
+const (
+	EventFeeIngested   EventType = "fee_ingested"
+	EventReorgRollback EventType = "reorg_rollback"
+	EventRepriced      EventType = "repriced"
+)
+
+// sources of ingested fees
+const (
+	SourceLive       = "live"
+	SourceHistorical = "historical"
+	SourceDeadLetter = "dead_letter"
+)
+
+type Event interface {
+	Type() EventType
+}
+
+// FeeIngested is published after a batch of fees has been committed, with the
+// fees it inserted. Stored fees the batch overwrote are published as Repriced.
+type FeeIngested struct {
+	Source string
+	Symbol string
+	Fees   []repository.UniTrxFee
+}
+
+func (FeeIngested) Type() EventType {
+	return EventFeeIngested
+}
+
+// ReorgRollback is published after fees of blocks that were reorged out have
+// been removed
+type ReorgRollback struct {
+	Symbol    string
+	FromBlock uint64
+	ToBlock   uint64
+	TrxHashes []string
+}
+
+func (ReorgRollback) Type() EventType {
+	return EventReorgRollback
+}
+
+// Repriced is published after stored fees have been updated with a new ETH price
+type Repriced struct {
+	Symbol string
+	Fees   []repository.UniTrxFee
+}
+
+func (Repriced) Type() EventType {
+	return EventRepriced
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/service"
+)
+
+const deadLetterPollInterval = 30 * time.Second
+
+// DeadLetterWorker periodically retries dead-lettered batches whose backoff has elapsed
+type DeadLetterWorker interface {
+	Run()
+	Stop(ctx context.Context) error
+}
+
+type deadLetterWorker struct {
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+	svc    service.DeadLetterService
+}
+
+func NewDeadLetterWorker(ctx context.Context, svc service.DeadLetterService) DeadLetterWorker {
+	ctx, cancel := context.WithCancel(ctx)
+	return &deadLetterWorker{
+		ctx:    ctx,
+		cancel: cancel,
+		svc:    svc,
+	}
+}
+
+func (w *deadLetterWorker) Run() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(deadLetterPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				resolved, err := w.svc.RetryDueDeadLetters(w.ctx)
+				if err != nil {
+					log.Println("retry dead letters err: " + err.Error())
+					continue
+				}
+				if resolved > 0 {
+					log.Printf("resolved %d dead letter batches\n", resolved)
+				}
+			case <-w.ctx.Done():
+				log.Println("dead letter worker stopped")
+				return
+			}
+		}
+	}()
+}
+
+func (w *deadLetterWorker) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,30 +2,97 @@ package jobs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"math"
-	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/eventbus"
 	"github.com/jaime1129/fedex/internal/repository"
 	"github.com/jaime1129/fedex/internal/util"
 )
 
-const WETHUSDC = "WETH/USDC"
-const WETHUSDCPOOLADDRESS = "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"
+const WETHUSDC = components.SymbolWETHUSDC
+const WETHUSDCPOOLADDRESS = components.PoolWETHUSDC
 
 type DataTracker interface {
+	// Run initializes the trackers in the background; it never blocks on
+	// upstream availability.
 	Run()
-	Stop()
+	Status() TrackerStatus
+	// Stop cancels the trackers and blocks until every in-flight batch has been
+	// committed or rolled back, or until ctx expires.
+	Stop(ctx context.Context) error
+}
+
+type TrackerState string
+
+const (
+	StateInitializing TrackerState = "initializing"
+	StateRunning      TrackerState = "running"
+	StateDegraded     TrackerState = "degraded"
+	StateStopped      TrackerState = "stopped"
+)
+
+// initial and maximum delay between attempts to fetch the latest block
+const (
+	initRetryBaseDelay = time.Second
+	initRetryMaxDelay  = time.Minute
+)
+
+type TrackerStatus struct {
+	State        TrackerState `json:"state"`
+	LatestBlock  int64        `json:"latest_block"`
+	InitAttempts int          `json:"init_attempts"`
+	LastError    string       `json:"last_error,omitempty"`
+	UpdatedAt    int64        `json:"updated_at"`
 }
 
 type dataTracker struct {
 	ctx        context.Context
 	cancel     func()
+	wg         sync.WaitGroup
+	mu         sync.RWMutex
+	status     TrackerStatus
 	ethScanCli components.EthScanCli
 	bnCli      components.BnPriceCli
 	repo       repository.Repository
+	bus        eventbus.Publisher
+
+	liveStats       flushStats
+	historicalStats flushStats
+}
+
+// flushStats counts what a tracker has written, for the summary logged on exit
+type flushStats struct {
+	batches      atomic.Int64
+	rows         atomic.Int64
+	repriced     atomic.Int64
+	duplicates   atomic.Int64
+	failed       atomic.Int64
+	deadLettered atomic.Int64
+	invalid      atomic.Int64
+}
+
+func (s *flushStats) recordFlush(report repository.InsertReport) {
+	s.batches.Add(1)
+	s.rows.Add(int64(report.Inserted))
+	s.repriced.Add(int64(report.Updated))
+	s.duplicates.Add(int64(report.Skipped))
+}
+
+func (s *flushStats) recordFailure() {
+	s.failed.Add(1)
+}
+
+func (s *flushStats) String() string {
+	return fmt.Sprintf("batches=%d rows=%d repriced=%d duplicates=%d failed=%d dead_lettered=%d invalid=%d",
+		s.batches.Load(), s.rows.Load(), s.repriced.Load(), s.duplicates.Load(), s.failed.Load(), s.deadLettered.Load(), s.invalid.Load())
 }
 
 func NewDataTracker(
@@ -33,6 +100,7 @@ func NewDataTracker(
 	ethScanCli components.EthScanCli,
 	bnCli components.BnPriceCli,
 	repo repository.Repository,
+	bus eventbus.Publisher,
 ) DataTracker {
 	ctx, cancel := context.WithCancel(ctx)
 	return &dataTracker{
@@ -41,31 +109,113 @@ func NewDataTracker(
 		ethScanCli: ethScanCli,
 		bnCli:      bnCli,
 		repo:       repo,
+		bus:        bus,
+		status: TrackerStatus{
+			State:     StateInitializing,
+			UpdatedAt: time.Now().Unix(),
+		},
 	}
 }
 
 func (t *dataTracker) Run() {
-	resp, err := t.ethScanCli.GetLatestBlock()
-	if err != nil || resp == 0 {
-		log.Fatal("get latest block err: " + err.Error())
-		return
-	}
-
+	t.wg.Add(1)
 	go func() {
-		t.TrackLiveData(t.ctx, resp)
-	}()
+		defer t.wg.Done()
+		latestBlock, ok := t.initialize(t.ctx)
+		if !ok {
+			return
+		}
 
-	go func() {
-		t.TrackHistoricalData(t.ctx, resp)
+		t.wg.Add(2)
+		go func() {
+			defer t.wg.Done()
+			t.TrackLiveData(t.ctx, latestBlock)
+		}()
+
+		go func() {
+			defer t.wg.Done()
+			t.TrackHistoricalData(t.ctx, latestBlock)
+		}()
 	}()
 }
 
-func (t *dataTracker) Stop() {
+// initialize fetches the latest block, retrying with exponential backoff until
+// it succeeds or ctx is cancelled. The tracker is reported as degraded while
+// retrying so that the api can keep serving stored data.
+func (t *dataTracker) initialize(ctx context.Context) (int64, bool) {
+	delay := initRetryBaseDelay
+	for {
+		latestBlock, err := t.ethScanCli.GetLatestBlock()
+		if err == nil && latestBlock == 0 {
+			err = errors.New("latest block is 0")
+		}
+		if err == nil {
+			t.setStatus(func(s *TrackerStatus) {
+				s.State = StateRunning
+				s.LatestBlock = latestBlock
+				s.InitAttempts++
+				s.LastError = ""
+			})
+			return latestBlock, true
+		}
+
+		log.Printf("get latest block err: %s, retrying in %s\n", err.Error(), delay)
+		t.setStatus(func(s *TrackerStatus) {
+			s.State = StateDegraded
+			s.InitAttempts++
+			s.LastError = err.Error()
+		})
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return 0, false
+		}
+		delay = min(delay*2, initRetryMaxDelay)
+	}
+}
+
+func (t *dataTracker) setStatus(update func(s *TrackerStatus)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	update(&t.status)
+	t.status.UpdatedAt = time.Now().Unix()
+}
+
+func (t *dataTracker) Status() TrackerStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status
+}
+
+func (t *dataTracker) Stop(ctx context.Context) error {
 	t.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Println("data tracker stop deadline exceeded: " + err.Error())
+	}
+
+	t.setStatus(func(s *TrackerStatus) {
+		s.State = StateStopped
+	})
+	log.Printf("live data tracker flushed: %s\n", &t.liveStats)
+	log.Printf("historical data tracker flushed: %s\n", &t.historicalStats)
+	return err
 }
 
 func (t *dataTracker) TrackLiveData(ctx context.Context, latestBlockNumber int64) {
 	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 	initialPage := int64(1)
 	offset := int64(20)
 	for {
@@ -73,11 +223,6 @@ func (t *dataTracker) TrackLiveData(ctx context.Context, latestBlockNumber int64
 		case <-ticker.C:
 			log.Println("refresh live data...")
 			currentUnix := time.Now().Unix()
-			price, err := t.bnCli.QueryETHPrice(currentUnix-60, currentUnix, components.INTERVAL_1MIN)
-			if err != nil {
-				log.Println("query price err: " + err.Error())
-				continue
-			}
 			resp, err := t.ethScanCli.QueryHistoricalTrxs(&components.QueryHistoricalTrxsReq{
 				Address:    WETHUSDCPOOLADDRESS,
 				StartBlock: latestBlockNumber,
@@ -96,29 +241,37 @@ func (t *dataTracker) TrackLiveData(ctx context.Context, latestBlockNumber int64
 			}
 
 			// save transaction to db
-			res := make([]repository.UniTrxFee, len(resp.Result))
-			for i, r := range resp.Result {
-				timeStamp, _ := strconv.Atoi(r.TimeStamp)
-				gasUsed, _ := strconv.Atoi(r.GasUsed)
-				gasPrice, _ := strconv.Atoi(r.GasPrice)
-				blockNum, _ := strconv.Atoi(r.BlockNumber)
-				res[i] = repository.UniTrxFee{
-					Symbol:       WETHUSDC,
-					TrxHash:      r.Hash,
-					TrxTime:      uint64(timeStamp),
-					GasUsed:      uint64(gasUsed),
-					GasPrice:     uint64(gasPrice),
-					BlockNumber:  uint64(blockNum),
-					EthUsdtPrice: price,
-					TrxFeeUsdt:   util.CalculateFeeInETH(int64(gasUsed), int64(gasPrice)).Mul(price),
+			res := t.parseTransactions(&t.liveStats, resp.Result)
+			if len(res) == 0 {
+				initialPage++
+				continue
+			}
+
+			priceQuery := &repository.PriceQuery{Start: currentUnix - 60, End: currentUnix, Interval: components.INTERVAL_1MIN}
+			price, err := t.bnCli.QueryETHPrice(priceQuery.Start, priceQuery.End, priceQuery.Interval)
+			if err != nil {
+				log.Println("query price err: " + err.Error())
+				if t.deadLetter(&t.liveStats, repository.DeadLetterSourceLive, res, priceQuery, err) {
+					initialPage++
 				}
+				continue
 			}
 
-			err = t.repo.BatchInsertUniTrxFee(res)
+			for i := range res {
+				res[i].SetPrice(price, priceQuery.Source())
+			}
+
+			// a trx stored by the historical tracker gets the finer live price
+			report, err := t.repo.BatchInsertUniTrxFee(res, repository.ConflictOverwriteIfBetterPrice)
 			if err != nil {
 				log.Println("batch insertion err: " + err.Error())
+				if t.deadLetter(&t.liveStats, repository.DeadLetterSourceLive, res, nil, err) {
+					initialPage++
+				}
 				continue
 			}
+			t.liveStats.recordFlush(report)
+			t.publish(eventbus.SourceLive, res, &report)
 
 			initialPage++
 		case <-ctx.Done():
@@ -131,6 +284,7 @@ func (t *dataTracker) TrackLiveData(ctx context.Context, latestBlockNumber int64
 
 func (t *dataTracker) TrackHistoricalData(ctx context.Context, latestBlockNumber int64) {
 	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 	initialPage := int64(1)
 	offset := int64(20)
 
@@ -161,47 +315,55 @@ func (t *dataTracker) TrackHistoricalData(ctx context.Context, latestBlockNumber
 				return
 			}
 
+			// save transaction to db
+			res := t.parseTransactions(&t.historicalStats, resp.Result)
+			if len(res) == 0 {
+				initialPage++
+				continue
+			}
+
 			maxBlockNum := uint64(0)
 			minTime := int64(math.MaxInt64)
 			maxTime := int64(0)
-			// save transaction to db
-			res := make([]repository.UniTrxFee, len(resp.Result))
-			for i, r := range resp.Result {
-				timeStamp, _ := strconv.Atoi(r.TimeStamp)
-				gasUsed, _ := strconv.Atoi(r.GasUsed)
-				gasPrice, _ := strconv.Atoi(r.GasPrice)
-				blockNum, _ := strconv.Atoi(r.BlockNumber)
-				maxBlockNum = uint64(math.Max(float64(maxBlockNum), float64(blockNum)))
-				minTime = int64(math.Min(float64(minTime), float64(timeStamp)))
-				maxTime = int64(math.Max(float64(maxTime), float64(timeStamp)))
-				res[i] = repository.UniTrxFee{
-					Symbol:      WETHUSDC,
-					TrxHash:     r.Hash,
-					TrxTime:     uint64(timeStamp),
-					GasUsed:     uint64(gasUsed),
-					GasPrice:    uint64(gasPrice),
-					BlockNumber: uint64(blockNum),
-				}
+			for _, r := range res {
+				maxBlockNum = max(maxBlockNum, r.BlockNumber)
+				minTime = min(minTime, int64(r.TrxTime))
+				maxTime = max(maxTime, int64(r.TrxTime))
 			}
 			// query the daily average price
 			avgTime := (minTime + maxTime) / 2
 			daySecs := 24 * 60 * 60
-			price, err := t.bnCli.QueryETHPrice(avgTime-int64(daySecs), maxTime+int64(daySecs), components.INTERVAL_12HOUR)
+			priceQuery := &repository.PriceQuery{Start: avgTime - int64(daySecs), End: maxTime + int64(daySecs), Interval: components.INTERVAL_12HOUR}
+			price, err := t.bnCli.QueryETHPrice(priceQuery.Start, priceQuery.End, priceQuery.Interval)
 			if err != nil {
 				log.Println("query price err: " + err.Error())
+				if t.deadLetter(&t.historicalStats, repository.DeadLetterSourceHistorical, res, priceQuery, err) {
+					initialPage++
+				}
 				continue
 			}
 
 			for i := range res {
-				res[i].EthUsdtPrice = price
-				res[i].TrxFeeUsdt = util.CalculateFeeInETH(int64(res[i].GasUsed), int64(res[i].GasPrice)).Mul(price)
+				res[i].SetPrice(price, priceQuery.Source())
 			}
 
-			err = t.repo.BatchRecordHistoricalTrx(res, WETHUSDC, maxBlockNum)
+			// the checkpoint only moves along with the trxs it covers
+			var report repository.InsertReport
+			err = t.repo.WithTx(ctx, func(tx repository.TxRepository) (err error) {
+				if report, err = tx.BatchInsertUniTrxFee(res, repository.ConflictOverwriteIfBetterPrice); err != nil {
+					return err
+				}
+				return tx.RecordMaxBlockNum(WETHUSDC, maxBlockNum)
+			})
 			if err != nil {
 				log.Println("batch insertion err: " + err.Error())
+				if t.deadLetter(&t.historicalStats, repository.DeadLetterSourceHistorical, res, nil, err) {
+					initialPage++
+				}
 				continue
 			}
+			t.historicalStats.recordFlush(report)
+			t.publish(eventbus.SourceHistorical, res, &report)
 
 			initialPage++
 		case <-ctx.Done():
@@ -211,3 +373,107 @@ func (t *dataTracker) TrackHistoricalData(ctx context.Context, latestBlockNumber
 
 	}
 }
+
+// parseTransactions converts etherscan txlist entries into unpriced fees.
+// Entries with malformed numbers are logged and skipped rather than stored as 0.
+func (t *dataTracker) parseTransactions(stats *flushStats, trxs []components.Transaction) []repository.UniTrxFee {
+	res := make([]repository.UniTrxFee, 0, len(trxs))
+	for _, r := range trxs {
+		fee, err := newUniTrxFee(r)
+		if err != nil {
+			stats.invalid.Add(1)
+			log.Printf("skip invalid transaction %s: %s\n", r.Hash, err.Error())
+			continue
+		}
+		res = append(res, fee)
+	}
+	return res
+}
+
+func newUniTrxFee(r components.Transaction) (repository.UniTrxFee, error) {
+	timeStamp, err := util.ParseUint64(r.TimeStamp)
+	if err != nil {
+		return repository.UniTrxFee{}, fmt.Errorf("timeStamp: %w", err)
+	}
+	gasUsed, err := util.ParseUint64(r.GasUsed)
+	if err != nil {
+		return repository.UniTrxFee{}, fmt.Errorf("gasUsed: %w", err)
+	}
+	gasPrice, err := util.ParseWeiDecimal(r.GasPrice)
+	if err != nil {
+		return repository.UniTrxFee{}, fmt.Errorf("gasPrice: %w", err)
+	}
+	blockNum, err := util.ParseUint64(r.BlockNumber)
+	if err != nil {
+		return repository.UniTrxFee{}, fmt.Errorf("blockNumber: %w", err)
+	}
+
+	return repository.UniTrxFee{
+		Symbol:      WETHUSDC,
+		TrxHash:     r.Hash,
+		TrxTime:     timeStamp,
+		GasUsed:     gasUsed,
+		GasPrice:    gasPrice,
+		BlockNumber: blockNum,
+		FromAddress: strings.ToLower(r.From),
+		Status:      trxStatus(r),
+	}, nil
+}
+
+func trxStatus(r components.Transaction) string {
+	switch {
+	case r.IsError == "1" || r.TxReceiptStatus == "0":
+		return repository.TrxStatusFailed
+	case r.IsError == "0":
+		return repository.TrxStatusSuccess
+	default:
+		return repository.TrxStatusUnknown
+	}
+}
+
+// publish announces the fees a committed batch inserted, and the stored ones it
+// repriced. Skipped fees were already announced when they were stored.
+func (t *dataTracker) publish(source string, fees []repository.UniTrxFee, report *repository.InsertReport) {
+	if report.Inserted > 0 {
+		t.bus.Publish(eventbus.FeeIngested{Source: source, Symbol: WETHUSDC, Fees: report.Fees(fees, repository.OutcomeInserted)})
+	}
+	if report.Updated > 0 {
+		t.bus.Publish(eventbus.Repriced{Symbol: WETHUSDC, Fees: report.Fees(fees, repository.OutcomeUpdated)})
+	}
+}
+
+// deadLetter stores a batch that failed to be priced or persisted so that it
+// can be retried later instead of being re-fetched. It returns false if the
+// batch could not be stored either, in which case the caller should re-fetch it.
+func (t *dataTracker) deadLetter(stats *flushStats, source string, fees []repository.UniTrxFee, priceQuery *repository.PriceQuery, cause error) bool {
+	stats.recordFailure()
+
+	startBlock, endBlock := uint64(math.MaxUint64), uint64(0)
+	for _, fee := range fees {
+		startBlock = min(startBlock, fee.BlockNumber)
+		endBlock = max(endBlock, fee.BlockNumber)
+	}
+
+	batch := &repository.DeadLetterBatch{
+		Symbol:     WETHUSDC,
+		Source:     source,
+		StartBlock: startBlock,
+		EndBlock:   endBlock,
+		Payload: repository.DeadLetterPayload{
+			Fees:       fees,
+			PriceQuery: priceQuery,
+		},
+		Error:       cause.Error(),
+		Attempts:    1,
+		Status:      repository.DeadLetterStatusPending,
+		NextRetryAt: time.Now().Unix(),
+	}
+	if err := t.repo.InsertDeadLetter(batch); err != nil {
+		log.Println("dead letter insertion err: " + err.Error())
+		return false
+	}
+
+	stats.deadLettered.Add(1)
+	log.Printf("dead lettered %s batch %d of %d trxs, blocks [%d, %d]\n", source, batch.ID, len(fees), startBlock, endBlock)
+	return true
+}
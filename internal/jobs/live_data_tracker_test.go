@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/eventbus"
+	"github.com/jaime1129/fedex/internal/repository"
+	mock_components "github.com/jaime1129/fedex/mock/components"
+	mock_repository "github.com/jaime1129/fedex/mock/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDegradedWhenLatestBlockUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+
+	mockEthScanCli.EXPECT().GetLatestBlock().Return(int64(0), errors.New("etherscan down")).AnyTimes()
+
+	tracker := NewDataTracker(context.TODO(), mockEthScanCli, mockBnPriceCli, mockRepo, eventbus.New())
+	assert.Equal(t, StateInitializing, tracker.Status().State)
+
+	tracker.Run()
+	assert.Eventually(t, func() bool {
+		return tracker.Status().State == StateDegraded
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "etherscan down", tracker.Status().LastError)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	assert.NoError(t, tracker.Stop(ctx))
+	assert.Equal(t, StateStopped, tracker.Status().State)
+}
+
+func TestRunDegradedWhenLatestBlockIsZero(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	mockEthScanCli.EXPECT().GetLatestBlock().Return(int64(0), nil).AnyTimes()
+
+	tracker := NewDataTracker(context.TODO(), mockEthScanCli, nil, nil, eventbus.New())
+	tracker.Run()
+	assert.Eventually(t, func() bool {
+		return tracker.Status().State == StateDegraded
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	assert.NoError(t, tracker.Stop(ctx))
+}
+
+func TestNewUniTrxFeeRejectsMalformedNumbers(t *testing.T) {
+	trx := components.Transaction{
+		Hash:        "hash123",
+		BlockNumber: "19000000",
+		TimeStamp:   "1700000000",
+		GasPrice:    "123456789012345678901234567890",
+		GasUsed:     "21000",
+		From:        "0xABC",
+	}
+	fee, err := newUniTrxFee(trx)
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789012345678901234567890", fee.GasPrice.String())
+	assert.Equal(t, "0xabc", fee.FromAddress)
+
+	trx.GasUsed = "not a number"
+	_, err = newUniTrxFee(trx)
+	assert.ErrorContains(t, err, "gasUsed")
+}
+
+func TestTrxStatus(t *testing.T) {
+	assert.Equal(t, repository.TrxStatusSuccess, trxStatus(components.Transaction{IsError: "0", TxReceiptStatus: "1"}))
+	assert.Equal(t, repository.TrxStatusSuccess, trxStatus(components.Transaction{IsError: "0"}))
+	assert.Equal(t, repository.TrxStatusFailed, trxStatus(components.Transaction{IsError: "1", TxReceiptStatus: "0"}))
+	assert.Equal(t, repository.TrxStatusFailed, trxStatus(components.Transaction{IsError: "0", TxReceiptStatus: "0"}))
+	assert.Equal(t, repository.TrxStatusUnknown, trxStatus(components.Transaction{}))
+}
+
+type recordingPublisher struct {
+	events []eventbus.Event
+}
+
+func (p *recordingPublisher) Publish(event eventbus.Event) {
+	p.events = append(p.events, event)
+}
+
+func TestPublishAnnouncesInsertedAndRepricedFees(t *testing.T) {
+	bus := &recordingPublisher{}
+	tracker := &dataTracker{bus: bus}
+	fees := []repository.UniTrxFee{{TrxHash: "0x1"}, {TrxHash: "0x2"}, {TrxHash: "0x3"}}
+
+	tracker.publish(eventbus.SourceLive, fees, &repository.InsertReport{
+		Inserted: 1, Updated: 1, Skipped: 1,
+		Outcomes: []repository.InsertOutcome{repository.OutcomeSkipped, repository.OutcomeInserted, repository.OutcomeUpdated},
+	})
+	assert.Equal(t, []eventbus.Event{
+		eventbus.FeeIngested{Source: eventbus.SourceLive, Symbol: WETHUSDC, Fees: fees[1:2]},
+		eventbus.Repriced{Symbol: WETHUSDC, Fees: fees[2:]},
+	}, bus.events)
+
+	// nothing to announce for a batch of duplicates
+	bus.events = nil
+	tracker.publish(eventbus.SourceLive, fees[:1], &repository.InsertReport{Skipped: 1, Outcomes: []repository.InsertOutcome{repository.OutcomeSkipped}})
+	assert.Empty(t, bus.events)
+}
@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/eventbus"
+	"github.com/jaime1129/fedex/internal/service"
+)
+
+const rollupInterval = time.Minute
+
+// RollupWorker keeps the fee rollups up to date, it rolls up new fees
+// periodically and invalidates the hours of repriced and reorged fees
+type RollupWorker interface {
+	Run()
+	Stop(ctx context.Context) error
+}
+
+type rollupWorker struct {
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+	svc    service.RollupService
+	bus    eventbus.Bus
+}
+
+func NewRollupWorker(ctx context.Context, svc service.RollupService, bus eventbus.Bus) RollupWorker {
+	ctx, cancel := context.WithCancel(ctx)
+	return &rollupWorker{
+		ctx:    ctx,
+		cancel: cancel,
+		svc:    svc,
+		bus:    bus,
+	}
+}
+
+func (w *rollupWorker) Run() {
+	// every update reads the fees written since its watermark, by their
+	// gmt_modified time, events only roll up reprices and reorgs sooner
+	sub := w.bus.Subscribe("trxfee-rollup", eventbus.SubscribeOptions{
+		BufferSize: 256,
+		Types:      []eventbus.EventType{eventbus.EventRepriced, eventbus.EventReorgRollback},
+	})
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer sub.Unsubscribe()
+		ticker := time.NewTicker(rollupInterval)
+		defer ticker.Stop()
+
+		events := sub.Events()
+		w.update()
+		for {
+			select {
+			case <-ticker.C:
+				w.update()
+			case event, ok := <-events:
+				if !ok {
+					// the bus is closed, updates go on without events
+					events = nil
+					continue
+				}
+				w.handle(event)
+			case <-w.ctx.Done():
+				log.Println("rollup worker stopped")
+				return
+			}
+		}
+	}()
+}
+
+func (w *rollupWorker) update() {
+	if err := w.svc.Update(w.ctx); err != nil && w.ctx.Err() == nil {
+		log.Println("update rollups err: " + err.Error())
+	}
+}
+
+func (w *rollupWorker) handle(event eventbus.Event) {
+	switch e := event.(type) {
+	case eventbus.Repriced:
+		w.svc.InvalidateFees(e.Symbol, e.Fees)
+	case eventbus.ReorgRollback:
+		if err := w.svc.InvalidateBlocks(e.Symbol, e.FromBlock, e.ToBlock); err != nil {
+			log.Println("invalidate reorged rollups err: " + err.Error())
+		}
+	}
+}
+
+func (w *rollupWorker) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
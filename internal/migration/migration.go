@@ -0,0 +1,495 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/util"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// each supported database driver has its own migrations directory, versions
+// are kept aligned across drivers
+//
+//go:embed migrations/*/*.sql
+var embedded embed.FS
+
+// migration files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the up script, so that an applied migration that was
+// edited afterwards is detected
+func (m *Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+type AppliedMigration struct {
+	Version  int
+	Name     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+type Migrator interface {
+	// Up applies every pending migration in order. With dryRun the pending SQL
+	// is only written to out, and the database is neither locked nor written.
+	Up(dryRun bool, out io.Writer) error
+	// Down reverts the last steps applied migrations
+	Down(steps int, dryRun bool, out io.Writer) error
+	Status() ([]MigrationStatus, error)
+	Close()
+}
+
+// the advisory lock serializing the migrators of a database, and how long one
+// waits for the others
+const (
+	lockName    = "feedex_schema_migrations"
+	lockTimeout = 5 * time.Minute
+)
+
+type migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+}
+
+// NewMigrator opens the database with one of the mysql, postgres or sqlite drivers
+func NewMigrator(driver string, dsn string) (Migrator, error) {
+	sqlDriverName, ok := map[string]string{"mysql": "mysql", "postgres": "pgx", "sqlite": "sqlite"}[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+	migrations, err := load(embedded, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(sqlDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+func (m *migrator) Close() {
+	m.db.Close()
+}
+
+func (m *migrator) Up(dryRun bool, out io.Writer) (err error) {
+	ctx := context.Background()
+	conn, applied, unlock, err := m.begin(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	pending, err := pendingMigrations(m.migrations, applied)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(out, "-- no pending migrations")
+		return nil
+	}
+
+	for _, mig := range pending {
+		fmt.Fprintf(out, "-- migration %s (up)\n", &mig)
+		if dryRun {
+			fmt.Fprintln(out, mig.Up)
+			continue
+		}
+		err = m.apply(ctx, conn, mig.Up,
+			"INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)", mig.Version, mig.Name, mig.Checksum())
+		if err != nil {
+			return fmt.Errorf("apply migration %s: %w", &mig, err)
+		}
+		log.Printf("applied migration %s\n", &mig)
+	}
+	return nil
+}
+
+func (m *migrator) Down(steps int, dryRun bool, out io.Writer) (err error) {
+	ctx := context.Background()
+	conn, applied, unlock, err := m.begin(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	if _, err = pendingMigrations(m.migrations, applied); err != nil {
+		return err
+	}
+
+	byVersion := make(map[int]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+	for i := len(applied) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+		mig := byVersion[applied[i].Version]
+		fmt.Fprintf(out, "-- migration %s (down)\n", &mig)
+		if dryRun {
+			fmt.Fprintln(out, mig.Down)
+			continue
+		}
+		err = m.apply(ctx, conn, mig.Down, "DELETE FROM schema_migrations WHERE version = ?", mig.Version)
+		if err != nil {
+			return fmt.Errorf("revert migration %s: %w", &mig, err)
+		}
+		log.Printf("reverted migration %s\n", &mig)
+	}
+	return nil
+}
+
+func (m *migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied(context.Background(), m.db, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = pendingMigrations(m.migrations, applied); err != nil {
+		return nil, err
+	}
+
+	appliedVersions := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		appliedVersions[a.Version] = true
+	}
+	res := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		res[i] = MigrationStatus{Migration: mig, Applied: appliedVersions[mig.Version]}
+	}
+	return res, nil
+}
+
+// execer is what *sql.DB, *sql.Conn and *sql.Tx have in common
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// begin locks the migrations and returns the locked connection with the
+// applied migrations. A dry run reads them without locking, and without
+// creating schema_migrations: the conn is nil and unlock does nothing.
+func (m *migrator) begin(ctx context.Context, dryRun bool) (conn *sql.Conn, applied []AppliedMigration, unlock func() error, err error) {
+	if dryRun {
+		applied, err = m.applied(ctx, m.db, false)
+		return nil, applied, func() error { return nil }, err
+	}
+	conn, unlock, err = m.lock(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if applied, err = m.applied(ctx, conn, true); err != nil {
+		unlock()
+		return nil, nil, nil, err
+	}
+	return conn, applied, unlock, nil
+}
+
+// lock takes a connection out of the pool and keeps the other migrators of the
+// database waiting until unlock, so that they never apply the same migration.
+// mysql and postgres take an advisory lock of the session, sqlite a write
+// transaction that the migrations then run in.
+func (m *migrator) lock(ctx context.Context) (conn *sql.Conn, unlock func() error, err error) {
+	conn, err = m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	switch m.driver {
+	case "mysql":
+		var got sql.NullInt64
+		err = conn.QueryRowContext(lockCtx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&got)
+		if err == nil && got.Int64 != 1 {
+			err = fmt.Errorf("timed out waiting for lock %s", lockName)
+		}
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+			return errors.Join(err, conn.Close())
+		}
+	case "postgres":
+		_, err = conn.ExecContext(lockCtx, "SELECT pg_advisory_lock(hashtext($1))", lockName)
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", lockName)
+			return errors.Join(err, conn.Close())
+		}
+	default:
+		// waits up to the busy timeout of the dsn for other writers
+		_, err = conn.ExecContext(lockCtx, "BEGIN IMMEDIATE")
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "COMMIT")
+			return errors.Join(err, conn.Close())
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("lock schema migrations: %w", err)
+	}
+	return conn, unlock, nil
+}
+
+// applied returns the applied migrations ordered by version. With create the
+// schema_migrations table is created if needed, else its absence means that
+// none was applied.
+func (m *migrator) applied(ctx context.Context, db execer, create bool) ([]AppliedMigration, error) {
+	if !create {
+		exists, err := m.tableExists(ctx, db, "schema_migrations")
+		if err != nil || !exists {
+			return nil, err
+		}
+		return m.listApplied(ctx, db)
+	}
+
+	ddl := "CREATE TABLE IF NOT EXISTS schema_migrations (" +
+		"version bigint NOT NULL, " +
+		"name varchar(255) NOT NULL DEFAULT '', " +
+		"checksum char(64) NOT NULL DEFAULT '', " +
+		"applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+		"PRIMARY KEY (version))"
+	if m.driver == "mysql" {
+		ddl += " ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci"
+	}
+	_, err := db.ExecContext(ctx, ddl)
+	if err != nil {
+		return nil, err
+	}
+	return m.listApplied(ctx, db)
+}
+
+func (m *migrator) listApplied(ctx context.Context, db execer) ([]AppliedMigration, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, name, checksum FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		if err = rows.Scan(&a.Version, &a.Name, &a.Checksum); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// tableExists tells whether the database has a table, without creating it
+func (m *migrator) tableExists(ctx context.Context, db execer, table string) (bool, error) {
+	var query string
+	switch m.driver {
+	case "mysql":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	case "postgres":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	default:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	}
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var n int
+	if rows.Next() {
+		if err = rows.Scan(&n); err != nil {
+			return false, err
+		}
+	}
+	return n > 0, rows.Err()
+}
+
+// apply runs the statements of a script and then the record statement that
+// updates schema_migrations, all or none of them in postgres and sqlite. mysql
+// DDL is not transactional so a failed script has to be fixed forward.
+func (m *migrator) apply(ctx context.Context, conn *sql.Conn, script string, record string, args ...any) error {
+	run := func(db execer) error {
+		for _, stmt := range splitStatements(script) {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		query := record
+		if m.driver == "postgres" {
+			query = util.RebindDollar(record)
+		}
+		_, err := db.ExecContext(ctx, query, args...)
+		return err
+	}
+
+	switch m.driver {
+	case "mysql":
+		return run(conn)
+	case "postgres":
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err = run(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	default:
+		// already in the transaction of the lock, the migrations applied
+		// before a failed one are still committed on unlock
+		if _, err := conn.ExecContext(ctx, "SAVEPOINT migration"); err != nil {
+			return err
+		}
+		if err := run(conn); err != nil {
+			conn.ExecContext(ctx, "ROLLBACK TO migration")
+			conn.ExecContext(ctx, "RELEASE migration")
+			return err
+		}
+		_, err := conn.ExecContext(ctx, "RELEASE migration")
+		return err
+	}
+}
+
+// pendingMigrations verifies the checksums of applied migrations and returns
+// the ones that still have to be applied
+func pendingMigrations(migrations []Migration, applied []AppliedMigration) ([]Migration, error) {
+	known := make(map[int]Migration, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = mig
+	}
+
+	appliedVersions := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		mig, ok := known[a.Version]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %04d_%s is unknown to this build", a.Version, a.Name)
+		}
+		if mig.Checksum() != a.Checksum {
+			return nil, fmt.Errorf("checksum mismatch for applied migration %s", &mig)
+		}
+		appliedVersions[a.Version] = true
+	}
+
+	var pending []Migration
+	for _, mig := range migrations {
+		if !appliedVersions[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// load reads the migrations of the given driver
+func load(fsys fs.FS, driver string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, path.Join("migrations", driver, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		match := fileNamePattern.FindStringSubmatch(path.Base(file))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", file)
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down script", mig)
+		}
+		migrations = append(migrations, *mig)
+	}
+	if len(migrations) == 0 {
+		return nil, fmt.Errorf("no migrations for driver %s", driver)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements splits a script on semicolons that end a line, skipping
+// comment-only lines
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
@@ -0,0 +1,186 @@
+package migration
+
+import (
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	mysqlMigrations, err := load(embedded, "mysql")
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, mysqlMigrations)
+	for i, mig := range mysqlMigrations {
+		assert.Equal(t, i+1, mig.Version, "migration versions must be contiguous")
+		assert.NotEmpty(t, splitStatements(mig.Up))
+		assert.NotEmpty(t, splitStatements(mig.Down))
+	}
+
+	// other drivers may have no-op versions but must stay aligned with mysql
+	for _, driver := range []string{"postgres", "sqlite"} {
+		migrations, err := load(embedded, driver)
+		assert.NoError(t, err, driver)
+		assert.Len(t, migrations, len(mysqlMigrations), driver)
+		for i := range migrations {
+			assert.Equal(t, mysqlMigrations[i].String(), migrations[i].String(), driver)
+		}
+		assert.NotEmpty(t, splitStatements(migrations[0].Up), driver)
+	}
+}
+
+func TestLoadRejectsMissingDownScript(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/mysql/0001_init.up.sql": {Data: []byte("CREATE TABLE a (id int);")},
+	}
+
+	_, err := load(fsys, "mysql")
+	assert.Error(t, err)
+}
+
+func TestPendingMigrations(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE a (id int);"},
+		{Version: 2, Name: "add_b", Up: "CREATE TABLE b (id int);"},
+	}
+
+	pending, err := pendingMigrations(migrations, nil)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	pending, err = pendingMigrations(migrations, []AppliedMigration{{Version: 1, Name: "init", Checksum: migrations[0].Checksum()}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{migrations[1]}, pending)
+
+	_, err = pendingMigrations(migrations, []AppliedMigration{{Version: 1, Name: "init", Checksum: "edited"}})
+	assert.ErrorContains(t, err, "checksum mismatch")
+
+	_, err = pendingMigrations(migrations, []AppliedMigration{{Version: 3, Name: "future"}})
+	assert.ErrorContains(t, err, "unknown")
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment
+CREATE TABLE a (
+  id int
+);
+
+ALTER TABLE a ADD COLUMN b int;
+`
+	assert.Equal(t, []string{"CREATE TABLE a (\n  id int\n)", "ALTER TABLE a ADD COLUMN b int"}, splitStatements(script))
+}
+
+func TestConcurrentUpAppliesEachMigrationOnce(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "trx_fee.db") + "?_pragma=busy_timeout(5000)"
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		m, err := NewMigrator("sqlite", dsn)
+		require.NoError(t, err)
+		defer m.Close()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = m.Up(false, io.Discard)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	m, err := NewMigrator("sqlite", dsn)
+	require.NoError(t, err)
+	defer m.Close()
+	status, err := m.Status()
+	require.NoError(t, err)
+	for _, s := range status {
+		assert.True(t, s.Applied, s.String())
+	}
+
+	// reverting everything and applying it again leaves the same schema
+	require.NoError(t, m.Down(len(status), false, io.Discard))
+	require.NoError(t, m.Up(false, io.Discard))
+}
+
+func TestSQLiteSortableDecimals(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "trx_fee.db")
+	m, err := NewMigrator("sqlite", dsn)
+	require.NoError(t, err)
+	defer m.Close()
+	require.NoError(t, m.Up(false, io.Discard))
+	require.NoError(t, m.Down(1, false, io.Discard))
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	// as stored before 0011, compared as text 10 sorts before 9.5
+	_, err = db.Exec("INSERT INTO uni_trx_fee (trx_hash, trx_fee_usdt) VALUES ('0x1', '10'), ('0x2', '9.5'), ('0x3', '0')")
+	require.NoError(t, err)
+
+	order := func() []string {
+		rows, err := db.Query("SELECT trx_fee_usdt FROM uni_trx_fee ORDER BY trx_fee_usdt")
+		require.NoError(t, err)
+		defer rows.Close()
+		var fees []string
+		for rows.Next() {
+			var fee string
+			require.NoError(t, rows.Scan(&fee))
+			fees = append(fees, fee)
+		}
+		require.NoError(t, rows.Err())
+		return fees
+	}
+	require.NoError(t, m.Up(false, io.Discard))
+	padded := order()
+	require.Len(t, padded, 3)
+	assert.Equal(t, strings.Repeat("0", 65), padded[0])
+	assert.Equal(t, strings.Repeat("0", 64)+"9.5", padded[1])
+	assert.Equal(t, strings.Repeat("0", 63)+"10", padded[2])
+
+	require.NoError(t, m.Down(1, false, io.Discard))
+	assert.Equal(t, []string{"0", "10", "9.5"}, order())
+}
+
+func TestDryRunDoesNotWrite(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "trx_fee.db")
+	m, err := NewMigrator("sqlite", dsn)
+	require.NoError(t, err)
+	defer m.Close()
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	tables := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&n))
+		return n
+	}
+
+	// nothing is applied to a database without schema_migrations
+	var out strings.Builder
+	require.NoError(t, m.Up(true, &out))
+	assert.Contains(t, out.String(), "-- migration 0001_init (up)")
+	assert.Zero(t, tables())
+	status, err := m.Status()
+	require.NoError(t, err)
+	assert.False(t, status[0].Applied)
+	assert.Zero(t, tables())
+
+	require.NoError(t, m.Up(false, io.Discard))
+	created := tables()
+	out.Reset()
+	require.NoError(t, m.Down(1, true, &out))
+	assert.Contains(t, out.String(), "(down)")
+	assert.Equal(t, created, tables())
+	status, err = m.Status()
+	require.NoError(t, err)
+	assert.True(t, status[len(status)-1].Applied)
+}
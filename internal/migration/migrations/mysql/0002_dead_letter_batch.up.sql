@@ -0,0 +1,18 @@
+-- failed ingestion batches kept for retry
+
+CREATE TABLE IF NOT EXISTS `dead_letter_batch` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `symbol` varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  `source` varchar(20) NOT NULL DEFAULT '' COMMENT 'tracker that produced the batch: live or historical',
+  `start_block` bigint unsigned NOT NULL DEFAULT '0',
+  `end_block` bigint unsigned NOT NULL DEFAULT '0',
+  `payload` json NOT NULL COMMENT 'serialized transactions and pending price query',
+  `error` text NOT NULL COMMENT 'last error',
+  `attempts` int unsigned NOT NULL DEFAULT '0',
+  `status` varchar(20) NOT NULL DEFAULT 'pending' COMMENT 'pending, exhausted, resolved or discarded',
+  `next_retry_at` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'unix timestamp of next automatic retry',
+  `gmt_created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `gmt_modified` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `dead_letter_batch_status_retry_IDX` (`status`,`next_retry_at`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,3 @@
+ALTER TABLE `uni_trx_fee`
+  DROP KEY `uni_trx_fee_from_address_IDX`,
+  DROP COLUMN `from_address`;
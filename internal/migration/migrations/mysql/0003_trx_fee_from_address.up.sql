@@ -0,0 +1,21 @@
+-- sender of the transaction, to filter fees by address. Databases created by
+-- scripts/mysql/init.sql while it defined the column already have it, so the
+-- column and its index are only added when missing.
+
+SET @add_from_address = (SELECT IF(COUNT(*) = 0,
+  'ALTER TABLE `uni_trx_fee` ADD COLUMN `from_address` varchar(42) NOT NULL DEFAULT '''' COMMENT ''sender address, lower case'' AFTER `gas_price`',
+  'DO 0')
+  FROM information_schema.COLUMNS
+  WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'uni_trx_fee' AND COLUMN_NAME = 'from_address');
+PREPARE add_from_address FROM @add_from_address;
+EXECUTE add_from_address;
+DEALLOCATE PREPARE add_from_address;
+
+SET @add_from_address_index = (SELECT IF(COUNT(*) = 0,
+  'CREATE INDEX `uni_trx_fee_from_address_IDX` ON `uni_trx_fee` (`from_address`) USING BTREE',
+  'DO 0')
+  FROM information_schema.STATISTICS
+  WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'uni_trx_fee' AND INDEX_NAME = 'uni_trx_fee_from_address_IDX');
+PREPARE add_from_address_index FROM @add_from_address_index;
+EXECUTE add_from_address_index;
+DEALLOCATE PREPARE add_from_address_index;
@@ -0,0 +1,7 @@
+-- reverting rounds prices and fees back to integers
+ALTER TABLE `uni_trx_fee`
+  DROP COLUMN `trx_fee_eth`,
+  DROP COLUMN `trx_fee_wei`,
+  MODIFY COLUMN `trx_fee_usdt` decimal(10,0) NOT NULL DEFAULT '0',
+  MODIFY COLUMN `eth_usdt_price` decimal(10,0) NOT NULL DEFAULT '0',
+  MODIFY COLUMN `gas_price` bigint unsigned NOT NULL DEFAULT '0';
@@ -0,0 +1,14 @@
+-- store prices and fees without rounding, gas price in wei can exceed bigint
+ALTER TABLE `uni_trx_fee`
+  MODIFY COLUMN `gas_price` decimal(65,0) unsigned NOT NULL DEFAULT '0' COMMENT 'effective gas price in wei',
+  MODIFY COLUMN `eth_usdt_price` decimal(36,18) NOT NULL DEFAULT '0' COMMENT 'ETH price in USDT used for conversion',
+  MODIFY COLUMN `trx_fee_usdt` decimal(36,18) NOT NULL DEFAULT '0' COMMENT 'fee in USDT',
+  ADD COLUMN `trx_fee_wei` decimal(65,0) unsigned NOT NULL DEFAULT '0' COMMENT 'fee in wei' AFTER `eth_usdt_price`,
+  ADD COLUMN `trx_fee_eth` decimal(36,18) NOT NULL DEFAULT '0' COMMENT 'fee in ETH' AFTER `trx_fee_wei`;
+
+-- recompute fees of existing rows from gas used and gas price; multiplying by
+-- 1e-18 instead of dividing keeps all 18 decimal places
+UPDATE `uni_trx_fee` SET
+  `trx_fee_wei` = `gas_used` * `gas_price`,
+  `trx_fee_eth` = `gas_used` * `gas_price` * 0.000000000000000001,
+  `trx_fee_usdt` = `gas_used` * `gas_price` * 0.000000000000000001 * `eth_usdt_price`;
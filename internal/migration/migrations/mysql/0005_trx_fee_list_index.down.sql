@@ -0,0 +1 @@
+DROP INDEX `uni_trx_fee_symbol_trx_time_IDX` ON `uni_trx_fee`;
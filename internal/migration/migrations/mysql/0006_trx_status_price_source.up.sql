@@ -0,0 +1,5 @@
+-- status of the transaction and origin of the ETH price used for the fee
+
+ALTER TABLE `uni_trx_fee`
+  ADD COLUMN `trx_status` varchar(10) NOT NULL DEFAULT 'unknown' COMMENT 'success, failed or unknown' AFTER `from_address`,
+  ADD COLUMN `price_source` varchar(20) NOT NULL DEFAULT 'unknown' COMMENT 'binance kline interval the price was averaged from, like binance_1m' AFTER `eth_usdt_price`;
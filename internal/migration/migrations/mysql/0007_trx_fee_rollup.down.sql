@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `job_watermark`;
+DROP TABLE IF EXISTS `trx_fee_rollup_day`;
+DROP TABLE IF EXISTS `trx_fee_rollup_hour`;
@@ -0,0 +1,53 @@
+-- hourly and daily aggregates of uni_trx_fee per symbol, maintained by the rollup worker
+
+CREATE TABLE IF NOT EXISTS `trx_fee_rollup_hour` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `symbol` varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  `bucket_start` bigint NOT NULL DEFAULT '0' COMMENT 'unix timestamp of the hour start',
+  `trx_count` bigint unsigned NOT NULL DEFAULT '0',
+  `sum_fee_usdt` decimal(65,18) NOT NULL DEFAULT '0',
+  `min_fee_usdt` decimal(36,18) NOT NULL DEFAULT '0',
+  `max_fee_usdt` decimal(36,18) NOT NULL DEFAULT '0',
+  `sum_fee_eth` decimal(65,18) NOT NULL DEFAULT '0',
+  `min_fee_eth` decimal(36,18) NOT NULL DEFAULT '0',
+  `max_fee_eth` decimal(36,18) NOT NULL DEFAULT '0',
+  `sum_gas_price` decimal(65,0) unsigned NOT NULL DEFAULT '0' COMMENT 'in wei',
+  `sum_gas_used` decimal(65,0) unsigned NOT NULL DEFAULT '0',
+  `min_block` bigint unsigned NOT NULL DEFAULT '0',
+  `max_block` bigint unsigned NOT NULL DEFAULT '0',
+  `sketch_usdt` text NOT NULL COMMENT 'json percentile sketch of the fees in USDT',
+  `sketch_eth` text NOT NULL COMMENT 'json percentile sketch of the fees in ETH',
+  `gmt_modified` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `trx_fee_rollup_hour_symbol_start_IDX` (`symbol`,`bucket_start`) USING BTREE,
+  KEY `trx_fee_rollup_hour_symbol_block_IDX` (`symbol`,`max_block`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `trx_fee_rollup_day` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `symbol` varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  `bucket_start` bigint NOT NULL DEFAULT '0' COMMENT 'unix timestamp of the day start',
+  `trx_count` bigint unsigned NOT NULL DEFAULT '0',
+  `sum_fee_usdt` decimal(65,18) NOT NULL DEFAULT '0',
+  `min_fee_usdt` decimal(36,18) NOT NULL DEFAULT '0',
+  `max_fee_usdt` decimal(36,18) NOT NULL DEFAULT '0',
+  `sum_fee_eth` decimal(65,18) NOT NULL DEFAULT '0',
+  `min_fee_eth` decimal(36,18) NOT NULL DEFAULT '0',
+  `max_fee_eth` decimal(36,18) NOT NULL DEFAULT '0',
+  `sum_gas_price` decimal(65,0) unsigned NOT NULL DEFAULT '0' COMMENT 'in wei',
+  `sum_gas_used` decimal(65,0) unsigned NOT NULL DEFAULT '0',
+  `min_block` bigint unsigned NOT NULL DEFAULT '0',
+  `max_block` bigint unsigned NOT NULL DEFAULT '0',
+  `sketch_usdt` text NOT NULL COMMENT 'json percentile sketch of the fees in USDT',
+  `sketch_eth` text NOT NULL COMMENT 'json percentile sketch of the fees in ETH',
+  `gmt_modified` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `trx_fee_rollup_day_symbol_start_IDX` (`symbol`,`bucket_start`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- last uni_trx_fee id processed by a job
+CREATE TABLE IF NOT EXISTS `job_watermark` (
+  `job` varchar(50) NOT NULL,
+  `last_id` bigint unsigned NOT NULL DEFAULT '0',
+  PRIMARY KEY (`job`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
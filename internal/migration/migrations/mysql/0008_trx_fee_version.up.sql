@@ -0,0 +1,7 @@
+-- version counts the writes of a fee, gmt_modified is the time of the last one
+
+ALTER TABLE `uni_trx_fee`
+  ADD COLUMN `version` int unsigned NOT NULL DEFAULT '1' COMMENT 'incremented every time the fee is overwritten' AFTER `price_source`,
+  ADD COLUMN `gmt_modified` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER `gmt_created`;
+
+UPDATE `uni_trx_fee` SET `gmt_modified` = `gmt_created`;
@@ -0,0 +1,5 @@
+ALTER TABLE `job_watermark`
+  DROP COLUMN `last_modified`,
+  ADD COLUMN `last_id` bigint unsigned NOT NULL DEFAULT '0';
+
+DROP INDEX `uni_trx_fee_gmt_modified_IDX` ON `uni_trx_fee`;
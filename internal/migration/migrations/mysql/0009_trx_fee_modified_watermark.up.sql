@@ -0,0 +1,9 @@
+-- the rollup job reads fees in the order they were last written instead of by
+-- id, which is not assigned in commit order. Its watermark restarts from 0 so
+-- that every fee is rolled up again once.
+
+CREATE INDEX `uni_trx_fee_gmt_modified_IDX` ON `uni_trx_fee` (`gmt_modified`, `id`) USING BTREE;
+
+ALTER TABLE `job_watermark`
+  DROP COLUMN `last_id`,
+  ADD COLUMN `last_modified` bigint NOT NULL DEFAULT '0' COMMENT 'unix time of the last fee write processed';
@@ -0,0 +1 @@
+DROP INDEX `uni_trx_fee_gmt_created_IDX` ON `uni_trx_fee`;
@@ -0,0 +1,3 @@
+-- the fee stream reads fees in the order they were inserted
+
+CREATE INDEX `uni_trx_fee_gmt_created_IDX` ON `uni_trx_fee` (`gmt_created`, `id`) USING BTREE;
@@ -0,0 +1,29 @@
+-- the schema of databases created before migrations existed, tables that
+-- already exist are skipped so later changes go to their own migrations
+
+CREATE TABLE IF NOT EXISTS uni_trx_fee (
+  id bigserial PRIMARY KEY,
+  symbol varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  trx_hash varchar(100) NOT NULL DEFAULT '',
+  trx_time bigint NOT NULL DEFAULT 0,
+  block_num bigint NOT NULL DEFAULT 0,
+  gas_used bigint NOT NULL DEFAULT 0,
+  gas_price numeric(65,0) NOT NULL DEFAULT 0,
+  eth_usdt_price numeric(36,18) NOT NULL DEFAULT 0,
+  trx_fee_wei numeric(65,0) NOT NULL DEFAULT 0,
+  trx_fee_eth numeric(36,18) NOT NULL DEFAULT 0,
+  trx_fee_usdt numeric(36,18) NOT NULL DEFAULT 0,
+  gmt_created timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT trx_hash_unique UNIQUE (trx_hash)
+);
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_trx_time_idx ON uni_trx_fee (trx_time);
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_block_num_idx ON uni_trx_fee (block_num);
+
+CREATE TABLE IF NOT EXISTS block_num_record (
+  id bigserial PRIMARY KEY,
+  max_block bigint NOT NULL DEFAULT 0,
+  symbol varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  CONSTRAINT block_num_record_symbol_unique UNIQUE (symbol)
+);
@@ -0,0 +1,18 @@
+-- failed ingestion batches kept for retry
+
+CREATE TABLE IF NOT EXISTS dead_letter_batch (
+  id bigserial PRIMARY KEY,
+  symbol varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  source varchar(20) NOT NULL DEFAULT '',
+  start_block bigint NOT NULL DEFAULT 0,
+  end_block bigint NOT NULL DEFAULT 0,
+  payload text NOT NULL,
+  error text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  status varchar(20) NOT NULL DEFAULT 'pending',
+  next_retry_at bigint NOT NULL DEFAULT 0,
+  gmt_created timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  gmt_modified timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS dead_letter_batch_status_retry_idx ON dead_letter_batch (status, next_retry_at);
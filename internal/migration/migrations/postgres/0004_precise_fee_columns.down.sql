@@ -0,0 +1,2 @@
+-- the postgres schema is created with precise fee columns in 0001_init,
+-- this version is kept so that migration versions match across drivers
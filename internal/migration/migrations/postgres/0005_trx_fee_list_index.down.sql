@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uni_trx_fee_symbol_trx_time_idx;
@@ -0,0 +1,3 @@
+-- fee listing pages through (trx_time, id) of a symbol
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_symbol_trx_time_idx ON uni_trx_fee (symbol, trx_time, id);
@@ -0,0 +1,3 @@
+ALTER TABLE uni_trx_fee DROP COLUMN price_source;
+
+ALTER TABLE uni_trx_fee DROP COLUMN trx_status;
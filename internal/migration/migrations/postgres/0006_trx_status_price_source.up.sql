@@ -0,0 +1,5 @@
+-- status of the transaction and origin of the ETH price used for the fee
+
+ALTER TABLE uni_trx_fee ADD COLUMN trx_status varchar(10) NOT NULL DEFAULT 'unknown';
+
+ALTER TABLE uni_trx_fee ADD COLUMN price_source varchar(20) NOT NULL DEFAULT 'unknown';
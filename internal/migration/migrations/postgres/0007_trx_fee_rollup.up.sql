@@ -0,0 +1,51 @@
+-- hourly and daily aggregates of uni_trx_fee per symbol, maintained by the rollup worker
+
+CREATE TABLE IF NOT EXISTS trx_fee_rollup_hour (
+  id bigserial PRIMARY KEY,
+  symbol varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  bucket_start bigint NOT NULL DEFAULT 0,
+  trx_count bigint NOT NULL DEFAULT 0,
+  sum_fee_usdt numeric(65,18) NOT NULL DEFAULT 0,
+  min_fee_usdt numeric(36,18) NOT NULL DEFAULT 0,
+  max_fee_usdt numeric(36,18) NOT NULL DEFAULT 0,
+  sum_fee_eth numeric(65,18) NOT NULL DEFAULT 0,
+  min_fee_eth numeric(36,18) NOT NULL DEFAULT 0,
+  max_fee_eth numeric(36,18) NOT NULL DEFAULT 0,
+  sum_gas_price numeric(65,0) NOT NULL DEFAULT 0,
+  sum_gas_used numeric(65,0) NOT NULL DEFAULT 0,
+  min_block bigint NOT NULL DEFAULT 0,
+  max_block bigint NOT NULL DEFAULT 0,
+  sketch_usdt text NOT NULL DEFAULT '',
+  sketch_eth text NOT NULL DEFAULT '',
+  gmt_modified timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT trx_fee_rollup_hour_symbol_start_unique UNIQUE (symbol, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS trx_fee_rollup_day (
+  id bigserial PRIMARY KEY,
+  symbol varchar(100) NOT NULL DEFAULT 'WETH/USDC',
+  bucket_start bigint NOT NULL DEFAULT 0,
+  trx_count bigint NOT NULL DEFAULT 0,
+  sum_fee_usdt numeric(65,18) NOT NULL DEFAULT 0,
+  min_fee_usdt numeric(36,18) NOT NULL DEFAULT 0,
+  max_fee_usdt numeric(36,18) NOT NULL DEFAULT 0,
+  sum_fee_eth numeric(65,18) NOT NULL DEFAULT 0,
+  min_fee_eth numeric(36,18) NOT NULL DEFAULT 0,
+  max_fee_eth numeric(36,18) NOT NULL DEFAULT 0,
+  sum_gas_price numeric(65,0) NOT NULL DEFAULT 0,
+  sum_gas_used numeric(65,0) NOT NULL DEFAULT 0,
+  min_block bigint NOT NULL DEFAULT 0,
+  max_block bigint NOT NULL DEFAULT 0,
+  sketch_usdt text NOT NULL DEFAULT '',
+  sketch_eth text NOT NULL DEFAULT '',
+  gmt_modified timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT trx_fee_rollup_day_symbol_start_unique UNIQUE (symbol, bucket_start)
+);
+
+CREATE INDEX IF NOT EXISTS trx_fee_rollup_hour_symbol_block_idx ON trx_fee_rollup_hour (symbol, max_block);
+
+-- last uni_trx_fee id processed by a job
+CREATE TABLE IF NOT EXISTS job_watermark (
+  job varchar(50) PRIMARY KEY,
+  last_id bigint NOT NULL DEFAULT 0
+);
@@ -0,0 +1,3 @@
+ALTER TABLE uni_trx_fee DROP COLUMN gmt_modified;
+
+ALTER TABLE uni_trx_fee DROP COLUMN version;
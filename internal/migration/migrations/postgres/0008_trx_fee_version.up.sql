@@ -0,0 +1,7 @@
+-- version counts the writes of a fee, gmt_modified is the time of the last one
+
+ALTER TABLE uni_trx_fee ADD COLUMN version integer NOT NULL DEFAULT 1;
+
+ALTER TABLE uni_trx_fee ADD COLUMN gmt_modified timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE uni_trx_fee SET gmt_modified = gmt_created;
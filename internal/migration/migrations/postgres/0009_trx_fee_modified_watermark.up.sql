@@ -0,0 +1,9 @@
+-- the rollup job reads fees in the order they were last written instead of by
+-- id, which is not assigned in commit order. Its watermark restarts from 0 so
+-- that every fee is rolled up again once.
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_gmt_modified_idx ON uni_trx_fee (gmt_modified, id);
+
+ALTER TABLE job_watermark DROP COLUMN last_id;
+
+ALTER TABLE job_watermark ADD COLUMN last_modified bigint NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uni_trx_fee_gmt_created_idx;
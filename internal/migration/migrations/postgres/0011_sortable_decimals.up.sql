@@ -0,0 +1,2 @@
+-- decimals are only padded in sqlite, which compares them as text,
+-- this version is kept so that migration versions match across drivers
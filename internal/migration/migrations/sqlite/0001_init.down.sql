@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS block_num_record;
+DROP TABLE IF EXISTS uni_trx_fee;
@@ -0,0 +1,30 @@
+-- the schema of databases created before migrations existed, tables that
+-- already exist are skipped so later changes go to their own migrations
+
+-- decimals are stored as text to keep their precision
+CREATE TABLE IF NOT EXISTS uni_trx_fee (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  symbol text NOT NULL DEFAULT 'WETH/USDC',
+  trx_hash text NOT NULL DEFAULT '',
+  trx_time integer NOT NULL DEFAULT 0,
+  block_num integer NOT NULL DEFAULT 0,
+  gas_used integer NOT NULL DEFAULT 0,
+  gas_price text NOT NULL DEFAULT '0',
+  eth_usdt_price text NOT NULL DEFAULT '0',
+  trx_fee_wei text NOT NULL DEFAULT '0',
+  trx_fee_eth text NOT NULL DEFAULT '0',
+  trx_fee_usdt text NOT NULL DEFAULT '0',
+  gmt_created timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT trx_hash_unique UNIQUE (trx_hash)
+);
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_trx_time_idx ON uni_trx_fee (trx_time);
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_block_num_idx ON uni_trx_fee (block_num);
+
+CREATE TABLE IF NOT EXISTS block_num_record (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  max_block integer NOT NULL DEFAULT 0,
+  symbol text NOT NULL DEFAULT 'WETH/USDC',
+  CONSTRAINT block_num_record_symbol_unique UNIQUE (symbol)
+);
@@ -0,0 +1,18 @@
+-- failed ingestion batches kept for retry
+
+CREATE TABLE IF NOT EXISTS dead_letter_batch (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  symbol text NOT NULL DEFAULT 'WETH/USDC',
+  source text NOT NULL DEFAULT '',
+  start_block integer NOT NULL DEFAULT 0,
+  end_block integer NOT NULL DEFAULT 0,
+  payload text NOT NULL,
+  error text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  status text NOT NULL DEFAULT 'pending',
+  next_retry_at integer NOT NULL DEFAULT 0,
+  gmt_created timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  gmt_modified timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS dead_letter_batch_status_retry_idx ON dead_letter_batch (status, next_retry_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS uni_trx_fee_from_address_idx;
+
+ALTER TABLE uni_trx_fee DROP COLUMN from_address;
@@ -0,0 +1,5 @@
+-- sender of the transaction, to filter fees by address
+
+ALTER TABLE uni_trx_fee ADD COLUMN from_address text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_from_address_idx ON uni_trx_fee (from_address);
@@ -0,0 +1,5 @@
+-- status of the transaction and origin of the ETH price used for the fee
+
+ALTER TABLE uni_trx_fee ADD COLUMN trx_status text NOT NULL DEFAULT 'unknown';
+
+ALTER TABLE uni_trx_fee ADD COLUMN price_source text NOT NULL DEFAULT 'unknown';
@@ -0,0 +1,52 @@
+-- hourly and daily aggregates of uni_trx_fee per symbol, maintained by the rollup worker
+-- decimals are stored as text to keep their precision
+
+CREATE TABLE IF NOT EXISTS trx_fee_rollup_hour (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  symbol text NOT NULL DEFAULT 'WETH/USDC',
+  bucket_start integer NOT NULL DEFAULT 0,
+  trx_count integer NOT NULL DEFAULT 0,
+  sum_fee_usdt text NOT NULL DEFAULT '0',
+  min_fee_usdt text NOT NULL DEFAULT '0',
+  max_fee_usdt text NOT NULL DEFAULT '0',
+  sum_fee_eth text NOT NULL DEFAULT '0',
+  min_fee_eth text NOT NULL DEFAULT '0',
+  max_fee_eth text NOT NULL DEFAULT '0',
+  sum_gas_price text NOT NULL DEFAULT '0',
+  sum_gas_used text NOT NULL DEFAULT '0',
+  min_block integer NOT NULL DEFAULT 0,
+  max_block integer NOT NULL DEFAULT 0,
+  sketch_usdt text NOT NULL DEFAULT '',
+  sketch_eth text NOT NULL DEFAULT '',
+  gmt_modified timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT trx_fee_rollup_hour_symbol_start_unique UNIQUE (symbol, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS trx_fee_rollup_day (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  symbol text NOT NULL DEFAULT 'WETH/USDC',
+  bucket_start integer NOT NULL DEFAULT 0,
+  trx_count integer NOT NULL DEFAULT 0,
+  sum_fee_usdt text NOT NULL DEFAULT '0',
+  min_fee_usdt text NOT NULL DEFAULT '0',
+  max_fee_usdt text NOT NULL DEFAULT '0',
+  sum_fee_eth text NOT NULL DEFAULT '0',
+  min_fee_eth text NOT NULL DEFAULT '0',
+  max_fee_eth text NOT NULL DEFAULT '0',
+  sum_gas_price text NOT NULL DEFAULT '0',
+  sum_gas_used text NOT NULL DEFAULT '0',
+  min_block integer NOT NULL DEFAULT 0,
+  max_block integer NOT NULL DEFAULT 0,
+  sketch_usdt text NOT NULL DEFAULT '',
+  sketch_eth text NOT NULL DEFAULT '',
+  gmt_modified timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT trx_fee_rollup_day_symbol_start_unique UNIQUE (symbol, bucket_start)
+);
+
+CREATE INDEX IF NOT EXISTS trx_fee_rollup_hour_symbol_block_idx ON trx_fee_rollup_hour (symbol, max_block);
+
+-- last uni_trx_fee id processed by a job
+CREATE TABLE IF NOT EXISTS job_watermark (
+  job text PRIMARY KEY,
+  last_id integer NOT NULL DEFAULT 0
+);
@@ -0,0 +1,8 @@
+-- version counts the writes of a fee, gmt_modified is the time of the last one.
+-- sqlite can't add a column defaulting to CURRENT_TIMESTAMP, inserts set it.
+
+ALTER TABLE uni_trx_fee ADD COLUMN version integer NOT NULL DEFAULT 1;
+
+ALTER TABLE uni_trx_fee ADD COLUMN gmt_modified timestamp NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE uni_trx_fee SET gmt_modified = gmt_created;
@@ -0,0 +1,5 @@
+ALTER TABLE job_watermark DROP COLUMN last_modified;
+
+ALTER TABLE job_watermark ADD COLUMN last_id integer NOT NULL DEFAULT 0;
+
+DROP INDEX IF EXISTS uni_trx_fee_gmt_modified_idx;
@@ -0,0 +1,3 @@
+-- the fee stream reads fees in the order they were inserted
+
+CREATE INDEX IF NOT EXISTS uni_trx_fee_gmt_created_idx ON uni_trx_fee (gmt_created, id);
@@ -0,0 +1,6 @@
+UPDATE uni_trx_fee SET
+  gas_price = CASE WHEN ltrim(gas_price, '0') = '' OR ltrim(gas_price, '0') LIKE '.%' THEN '0' || ltrim(gas_price, '0') ELSE ltrim(gas_price, '0') END,
+  eth_usdt_price = CASE WHEN ltrim(eth_usdt_price, '0') = '' OR ltrim(eth_usdt_price, '0') LIKE '.%' THEN '0' || ltrim(eth_usdt_price, '0') ELSE ltrim(eth_usdt_price, '0') END,
+  trx_fee_wei = CASE WHEN ltrim(trx_fee_wei, '0') = '' OR ltrim(trx_fee_wei, '0') LIKE '.%' THEN '0' || ltrim(trx_fee_wei, '0') ELSE ltrim(trx_fee_wei, '0') END,
+  trx_fee_eth = CASE WHEN ltrim(trx_fee_eth, '0') = '' OR ltrim(trx_fee_eth, '0') LIKE '.%' THEN '0' || ltrim(trx_fee_eth, '0') ELSE ltrim(trx_fee_eth, '0') END,
+  trx_fee_usdt = CASE WHEN ltrim(trx_fee_usdt, '0') = '' OR ltrim(trx_fee_usdt, '0') LIKE '.%' THEN '0' || ltrim(trx_fee_usdt, '0') ELSE ltrim(trx_fee_usdt, '0') END;
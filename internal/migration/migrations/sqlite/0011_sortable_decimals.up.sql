@@ -0,0 +1,9 @@
+-- sqlite compares decimals stored as text byte by byte: pad their integer
+-- part with zeros to 65 digits so that text order is the order of the values
+
+UPDATE uni_trx_fee SET
+  gas_price = substr('00000000000000000000000000000000000000000000000000000000000000000', 1, 65 - CASE WHEN instr(gas_price, '.') > 0 THEN instr(gas_price, '.') - 1 ELSE length(gas_price) END) || gas_price,
+  eth_usdt_price = substr('00000000000000000000000000000000000000000000000000000000000000000', 1, 65 - CASE WHEN instr(eth_usdt_price, '.') > 0 THEN instr(eth_usdt_price, '.') - 1 ELSE length(eth_usdt_price) END) || eth_usdt_price,
+  trx_fee_wei = substr('00000000000000000000000000000000000000000000000000000000000000000', 1, 65 - CASE WHEN instr(trx_fee_wei, '.') > 0 THEN instr(trx_fee_wei, '.') - 1 ELSE length(trx_fee_wei) END) || trx_fee_wei,
+  trx_fee_eth = substr('00000000000000000000000000000000000000000000000000000000000000000', 1, 65 - CASE WHEN instr(trx_fee_eth, '.') > 0 THEN instr(trx_fee_eth, '.') - 1 ELSE length(trx_fee_eth) END) || trx_fee_eth,
+  trx_fee_usdt = substr('00000000000000000000000000000000000000000000000000000000000000000', 1, 65 - CASE WHEN instr(trx_fee_usdt, '.') > 0 THEN instr(trx_fee_usdt, '.') - 1 ELSE length(trx_fee_usdt) END) || trx_fee_usdt;
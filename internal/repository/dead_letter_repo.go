@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+const (
+	DeadLetterSourceLive       = "live"
+	DeadLetterSourceHistorical = "historical"
+)
+
+const (
+	DeadLetterStatusPending   = "pending"
+	DeadLetterStatusExhausted = "exhausted"
+	DeadLetterStatusResolved  = "resolved"
+	DeadLetterStatusDiscarded = "discarded"
+)
+
+// DeadLetterBatch is a batch of fetched transactions that could not be priced
+// or persisted by the trackers
+type DeadLetterBatch struct {
+	ID          uint64
+	Symbol      string
+	Source      string
+	StartBlock  uint64
+	EndBlock    uint64
+	Payload     DeadLetterPayload
+	Error       string
+	Attempts    int
+	Status      string
+	NextRetryAt int64
+	CreatedAt   int64
+	UpdatedAt   int64
+}
+
+// DeadLetterPayload holds everything needed to replay a failed batch
+type DeadLetterPayload struct {
+	Fees []UniTrxFee `json:"fees"`
+	// set when the batch failed before its fees were priced
+	PriceQuery *PriceQuery `json:"price_query,omitempty"`
+}
+
+type PriceQuery struct {
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	Interval string `json:"interval"`
+}
+
+// Source identifies the price obtained by the query, the average of binance
+// klines of its interval
+func (q *PriceQuery) Source() string {
+	return "binance_" + q.Interval
+}
+
+func (r *repository) deadLetterColumns() string {
+	return "id, symbol, source, start_block, end_block, payload, error, attempts, status, next_retry_at, " +
+		r.dialect.unixTimestamp("gmt_created") + ", " + r.dialect.unixTimestamp("gmt_modified")
+}
+
+func (r *repository) InsertDeadLetter(batch *DeadLetterBatch) error {
+	payload, err := json.Marshal(batch.Payload)
+	if err != nil {
+		return err
+	}
+
+	stmt := "INSERT INTO dead_letter_batch (symbol, source, start_block, end_block, payload, error, attempts, status, next_retry_at) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	args := []interface{}{batch.Symbol, batch.Source, batch.StartBlock, batch.EndBlock, string(payload), batch.Error,
+		batch.Attempts, batch.Status, batch.NextRetryAt}
+
+	if r.dialect.returningID() {
+		return r.db.QueryRow(r.dialect.rebind(stmt+" RETURNING id"), args...).Scan(&batch.ID)
+	}
+	res, err := r.db.Exec(r.dialect.rebind(stmt), args...)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	batch.ID = uint64(id)
+	return nil
+}
+
+func (r *repository) GetDeadLetter(id uint64) (*DeadLetterBatch, error) {
+	row := r.db.QueryRow(r.dialect.rebind("SELECT "+r.deadLetterColumns()+" FROM dead_letter_batch WHERE id = ?"), id)
+	batch, err := scanDeadLetter(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// ListDeadLetters lists dead-lettered batches with the given status, or all of them if status is empty
+func (r *repository) ListDeadLetters(status string, page int, limit int) ([]DeadLetterBatch, error) {
+	if limit == 0 || limit > 50 {
+		limit = 20
+	}
+	query := "SELECT " + r.deadLetterColumns() + " FROM dead_letter_batch"
+	var args []interface{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id LIMIT ? OFFSET ?"
+	args = append(args, limit, page*limit)
+
+	return r.queryDeadLetters(query, args...)
+}
+
+// ListDueDeadLetters lists pending batches whose next retry time has passed
+func (r *repository) ListDueDeadLetters(now int64, limit int) ([]DeadLetterBatch, error) {
+	query := "SELECT " + r.deadLetterColumns() + " FROM dead_letter_batch WHERE status = ? AND next_retry_at <= ? ORDER BY next_retry_at LIMIT ?"
+	return r.queryDeadLetters(query, DeadLetterStatusPending, now, limit)
+}
+
+func (r *repository) UpdateDeadLetter(batch *DeadLetterBatch) error {
+	payload, err := json.Marshal(batch.Payload)
+	if err != nil {
+		return err
+	}
+
+	// gmt_modified is set explicitly, only mysql updates it on its own
+	_, err = r.db.Exec(r.dialect.rebind("UPDATE dead_letter_batch SET payload = ?, error = ?, attempts = ?, status = ?, next_retry_at = ?, gmt_modified = CURRENT_TIMESTAMP WHERE id = ?"),
+		string(payload), batch.Error, batch.Attempts, batch.Status, batch.NextRetryAt, batch.ID)
+	return err
+}
+
+func (r *repository) queryDeadLetters(query string, args ...interface{}) ([]DeadLetterBatch, error) {
+	rows, err := r.db.Query(r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []DeadLetterBatch
+	for rows.Next() {
+		batch, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *batch)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+func scanDeadLetter(row rowScanner) (*DeadLetterBatch, error) {
+	var batch DeadLetterBatch
+	var payload []byte
+	err := row.Scan(&batch.ID, &batch.Symbol, &batch.Source, &batch.StartBlock, &batch.EndBlock, &payload,
+		&batch.Error, &batch.Attempts, &batch.Status, &batch.NextRetryAt, &batch.CreatedAt, &batch.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(payload, &batch.Payload); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// supported values of the database.driver setting
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// dialect hides the SQL differences between the supported databases. Queries
+// are written with ? placeholders and MySQL-compatible syntax otherwise.
+type dialect struct {
+	driver string
+}
+
+func newDialect(driver string) (dialect, error) {
+	switch driver {
+	case DriverMySQL, DriverPostgres, DriverSQLite:
+		return dialect{driver: driver}, nil
+	default:
+		return dialect{}, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// sqlDriverName is the name the database/sql driver is registered with
+func (d dialect) sqlDriverName() string {
+	if d.driver == DriverPostgres {
+		return "pgx"
+	}
+	return d.driver
+}
+
+// rebind replaces ? placeholders with $1, $2... for postgres
+func (d dialect) rebind(query string) string {
+	if d.driver != DriverPostgres {
+		return query
+	}
+	return util.RebindDollar(query)
+}
+
+// insertIgnore builds an insert of `table (columns) VALUES ...` that skips
+// rows conflicting on the unique key column. Unlike INSERT IGNORE, it doesn't
+// turn other errors of mysql into warnings.
+func (d dialect) insertIgnore(into string, key string) string {
+	if d.driver == DriverMySQL {
+		return "INSERT INTO " + into + " ON DUPLICATE KEY UPDATE " + key + " = " + key
+	}
+	return "INSERT INTO " + into + " ON CONFLICT (" + key + ") DO NOTHING"
+}
+
+// upsert builds an insert of `table (columns) VALUES ...` that overwrites
+// updateColumns of the row conflicting on the unique key column
+func (d dialect) upsert(into string, key string, updateColumns ...string) string {
+	sets := make([]string, len(updateColumns))
+	if d.driver == DriverMySQL {
+		for i, col := range updateColumns {
+			sets[i] = col + "=VALUES(" + col + ")"
+		}
+		return "INSERT INTO " + into + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	for i, col := range updateColumns {
+		sets[i] = col + "=excluded." + col
+	}
+	return "INSERT INTO " + into + " ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// unixTimestamp converts a timestamp column to unix seconds
+func (d dialect) unixTimestamp(col string) string {
+	switch d.driver {
+	case DriverPostgres:
+		return "CAST(EXTRACT(EPOCH FROM " + col + ") AS BIGINT)"
+	case DriverSQLite:
+		return "CAST(strftime('%s', " + col + ") AS INTEGER)"
+	default:
+		return "UNIX_TIMESTAMP(" + col + ")"
+	}
+}
+
+// fromUnixTime converts unix seconds to a value comparable with timestamp
+// columns, so that their indexes can be used
+func (d dialect) fromUnixTime(expr string) string {
+	switch d.driver {
+	case DriverPostgres:
+		return "to_timestamp(" + expr + ")"
+	case DriverSQLite:
+		return "datetime(" + expr + ", 'unixepoch')"
+	default:
+		return "FROM_UNIXTIME(" + expr + ")"
+	}
+}
+
+// sqliteDecimalDigits is the width the integer part of decimals is padded to
+// in sqlite, the largest precision of the decimal columns of mysql
+const sqliteDecimalDigits = 65
+
+// decimal returns the argument storing or comparing v in a decimal column.
+// sqlite has no decimal type and compares text byte by byte: decimals are
+// stored there with the integer part zero-padded to sqliteDecimalDigits and
+// the fraction without trailing zeros, so that comparing, sorting, MIN and MAX
+// of the text are exact and compare by value.
+func (d dialect) decimal(v decimal.Decimal) interface{} {
+	if d.driver != DriverSQLite {
+		return v.String()
+	}
+	return sortableDecimal(v)
+}
+
+func sortableDecimal(v decimal.Decimal) string {
+	if v.IsNegative() {
+		// stored decimals are not negative, this only bounds a range below all of them
+		return "-"
+	}
+	s := v.String()
+	intDigits := len(s)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intDigits = i
+	}
+	if intDigits > sqliteDecimalDigits {
+		// this only bounds a range above every decimal that can be stored
+		return strings.Repeat("9", sqliteDecimalDigits+1)
+	}
+	return strings.Repeat("0", sqliteDecimalDigits-intDigits) + s
+}
+
+// sumsDecimals reports whether SUM of decimal columns is exact. sqlite sums
+// text as floating point numbers, its sums are computed from the rows instead.
+func (d dialect) sumsDecimals() bool {
+	return d.driver != DriverSQLite
+}
+
+// forUpdate is the suffix of a select that locks the rows it reads, sqlite
+// has a single writer and no row locks
+func (d dialect) forUpdate() string {
+	if d.driver == DriverSQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// returningID reports whether inserted ids are read with RETURNING instead of
+// LastInsertId, which the postgres driver does not support
+func (d dialect) returningID() bool {
+	return d.driver == DriverPostgres
+}
@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryRepository struct {
+	mu *sync.RWMutex
+	// set on the repository given to WithTx callbacks, which run with mu held
+	inTx bool
+	*memoryState
+}
+
+type memoryState struct {
+	fees        []UniTrxFee
+	feeByHash   map[string]int
+	maxBlocks   map[string]uint64
+	deadLetters []DeadLetterBatch
+	rollups     map[rollupKey]TrxFeeRollup
+	watermarks  map[string]int64
+}
+
+// NewMemoryRepository returns a thread-safe Repository that keeps everything in
+// memory, with the same semantics as the sql ones. It is meant for tests and
+// the demo mode.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		mu: &sync.RWMutex{},
+		memoryState: &memoryState{
+			feeByHash:  make(map[string]int),
+			maxBlocks:  make(map[string]uint64),
+			rollups:    make(map[rollupKey]TrxFeeRollup),
+			watermarks: make(map[string]int64),
+		},
+	}
+}
+
+func (r *memoryRepository) Close() {}
+
+// WithTx holds the lock for the whole transaction, so transactions and other
+// calls are serialized. The state is restored from a copy if fn fails.
+func (r *memoryRepository) WithTx(ctx context.Context, fn func(tx TxRepository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := r.memoryState.clone()
+	if err := fn(&memoryRepository{mu: r.mu, inTx: true, memoryState: r.memoryState}); err != nil {
+		*r.memoryState = saved
+		return err
+	}
+	return nil
+}
+
+// clone copies the collections of s, the fees, payloads and sketches they hold
+// are never modified in place so they may be shared
+func (s *memoryState) clone() memoryState {
+	c := memoryState{
+		fees:        append([]UniTrxFee(nil), s.fees...),
+		feeByHash:   make(map[string]int, len(s.feeByHash)),
+		maxBlocks:   make(map[string]uint64, len(s.maxBlocks)),
+		deadLetters: append([]DeadLetterBatch(nil), s.deadLetters...),
+		rollups:     make(map[rollupKey]TrxFeeRollup, len(s.rollups)),
+		watermarks:  make(map[string]int64, len(s.watermarks)),
+	}
+	for k, v := range s.feeByHash {
+		c.feeByHash[k] = v
+	}
+	for k, v := range s.maxBlocks {
+		c.maxBlocks[k] = v
+	}
+	for k, v := range s.rollups {
+		c.rollups[k] = v
+	}
+	for k, v := range s.watermarks {
+		c.watermarks[k] = v
+	}
+	return c
+}
+
+// the lock methods are no-ops within a transaction, where mu is already held
+func (r *memoryRepository) lock() {
+	if !r.inTx {
+		r.mu.Lock()
+	}
+}
+
+func (r *memoryRepository) unlock() {
+	if !r.inTx {
+		r.mu.Unlock()
+	}
+}
+
+func (r *memoryRepository) rlock() {
+	if !r.inTx {
+		r.mu.RLock()
+	}
+}
+
+func (r *memoryRepository) runlock() {
+	if !r.inTx {
+		r.mu.RUnlock()
+	}
+}
+
+func (r *memoryRepository) BatchInsertUniTrxFee(fees []UniTrxFee, policy ConflictPolicy) (InsertReport, error) {
+	r.lock()
+	defer r.unlock()
+
+	report := planInsert(fees, policy, func(hash string) (string, bool) {
+		i, ok := r.feeByHash[hash]
+		if !ok {
+			return "", false
+		}
+		return r.fees[i].PriceSource, true
+	})
+	now := time.Now().Unix()
+	for i, fee := range fees {
+		fee.Status = orDefault(fee.Status, TrxStatusUnknown)
+		fee.PriceSource = orDefault(fee.PriceSource, PriceSourceUnknown)
+		fee.UpdatedAt = now
+		switch report.Outcomes[i] {
+		case OutcomeInserted:
+			// ids are assigned in insertion order
+			fee.ID = uint64(len(r.fees) + 1)
+			fee.Version = 1
+			fee.CreatedAt = now
+			r.feeByHash[fee.TrxHash] = len(r.fees)
+			r.fees = append(r.fees, fee)
+		case OutcomeUpdated:
+			stored := &r.fees[r.feeByHash[fee.TrxHash]]
+			fee.ID = stored.ID
+			fee.Version = stored.Version + 1
+			fee.CreatedAt = stored.CreatedAt
+			*stored = fee
+		}
+	}
+	return report, nil
+}
+
+func (r *memoryRepository) RecordMaxBlockNum(symbol string, maxBlock uint64) error {
+	r.lock()
+	defer r.unlock()
+	r.maxBlocks[symbol] = maxBlock
+	return nil
+}
+
+func (r *memoryRepository) GetMaxBlockNum(symbol string) (uint64, error) {
+	r.rlock()
+	defer r.runlock()
+	return r.maxBlocks[symbol], nil
+}
+
+func (r *memoryRepository) GetTrxFee(txHash string) (*UniTrxFee, error) {
+	r.rlock()
+	defer r.runlock()
+	i, ok := r.feeByHash[txHash]
+	if !ok {
+		return nil, nil
+	}
+	fee := r.fees[i]
+	return &fee, nil
+}
+
+func (r *memoryRepository) GetTrxFeeByID(id uint64) (*UniTrxFee, error) {
+	r.rlock()
+	defer r.runlock()
+	// ids are assigned in insertion order
+	if id == 0 || id > uint64(len(r.fees)) {
+		return nil, nil
+	}
+	fee := r.fees[id-1]
+	return &fee, nil
+}
+
+func (r *memoryRepository) ListTrxFeeByHash(txHashes []string) ([]UniTrxFee, error) {
+	r.rlock()
+	defer r.runlock()
+	var fees []UniTrxFee
+	seen := make(map[string]bool, len(txHashes))
+	for _, hash := range txHashes {
+		if i, ok := r.feeByHash[hash]; ok && !seen[hash] {
+			seen[hash] = true
+			fees = append(fees, r.fees[i])
+		}
+	}
+	return fees, nil
+}
+
+func (r *memoryRepository) ListTrxFee(q TrxFeeListQuery) ([]UniTrxFee, error) {
+	fees := r.filterUniTrxFees(0, -1, q.Filter.match)
+	sortField := q.sortField()
+	less := func(a, b *UniTrxFee) bool {
+		if c := sortField.value(a).Cmp(sortField.value(b)); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	}
+	if q.Desc {
+		asc := less
+		less = func(a, b *UniTrxFee) bool { return asc(b, a) }
+	}
+	sort.Slice(fees, func(i, j int) bool { return less(&fees[i], &fees[j]) })
+
+	start := 0
+	if q.After != nil {
+		start = sort.Search(len(fees), func(i int) bool {
+			c := sortField.value(&fees[i]).Cmp(q.After.Value)
+			if q.Desc {
+				return c < 0 || (c == 0 && fees[i].ID < q.After.ID)
+			}
+			return c > 0 || (c == 0 && fees[i].ID > q.After.ID)
+		})
+	}
+	end := min(start+q.Limit, len(fees))
+	if start >= end {
+		return nil, nil
+	}
+	return fees[start:end], nil
+}
+
+func (r *memoryRepository) CountTrxFee(filter TrxFeeFilter) (int64, error) {
+	return int64(len(r.filterUniTrxFees(0, -1, filter.match))), nil
+}
+
+func (r *memoryRepository) ListTrxFeeModifiedAfter(after TrxFeeTimeCursor, filter StreamFilter, limit int) ([]UniTrxFee, error) {
+	return r.listTrxFeeWrittenAfter(func(fee *UniTrxFee) int64 { return fee.UpdatedAt }, after, filter, limit), nil
+}
+
+func (r *memoryRepository) ListTrxFeeCreatedAfter(after TrxFeeTimeCursor, filter StreamFilter, limit int) ([]UniTrxFee, error) {
+	return r.listTrxFeeWrittenAfter(func(fee *UniTrxFee) int64 { return fee.CreatedAt }, after, filter, limit), nil
+}
+
+func (r *memoryRepository) listTrxFeeWrittenAfter(writeTime func(fee *UniTrxFee) int64, after TrxFeeTimeCursor, filter StreamFilter, limit int) []UniTrxFee {
+	fees := r.filterUniTrxFees(0, -1, func(fee *UniTrxFee) bool {
+		t := writeTime(fee)
+		return (t > after.Time || (t == after.Time && fee.ID > after.ID)) && filter.match(fee)
+	})
+	sort.Slice(fees, func(i, j int) bool {
+		if ti, tj := writeTime(&fees[i]), writeTime(&fees[j]); ti != tj {
+			return ti < tj
+		}
+		return fees[i].ID < fees[j].ID
+	})
+	return fees[:min(limit, len(fees))]
+}
+
+// filterUniTrxFees returns up to limit matching fees in id order, after
+// skipping offset of them; a negative limit returns all of them
+func (r *memoryRepository) filterUniTrxFees(offset int, limit int, match func(fee *UniTrxFee) bool) []UniTrxFee {
+	r.rlock()
+	defer r.runlock()
+
+	var res []UniTrxFee
+	for i := range r.fees {
+		if len(res) == limit {
+			break
+		}
+		if !match(&r.fees[i]) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		res = append(res, r.fees[i])
+	}
+	return res
+}
+
+func (r *memoryRepository) InsertDeadLetter(batch *DeadLetterBatch) error {
+	r.lock()
+	defer r.unlock()
+
+	now := time.Now().Unix()
+	batch.ID = uint64(len(r.deadLetters) + 1)
+	batch.CreatedAt = now
+	batch.UpdatedAt = now
+	r.deadLetters = append(r.deadLetters, copyDeadLetter(batch))
+	return nil
+}
+
+func (r *memoryRepository) GetDeadLetter(id uint64) (*DeadLetterBatch, error) {
+	r.rlock()
+	defer r.runlock()
+	if id == 0 || id > uint64(len(r.deadLetters)) {
+		return nil, nil
+	}
+	batch := copyDeadLetter(&r.deadLetters[id-1])
+	return &batch, nil
+}
+
+func (r *memoryRepository) ListDeadLetters(status string, page int, limit int) ([]DeadLetterBatch, error) {
+	if limit == 0 || limit > 50 {
+		limit = 20
+	}
+	r.rlock()
+	defer r.runlock()
+
+	offset := page * limit
+	var res []DeadLetterBatch
+	for i := range r.deadLetters {
+		if len(res) == limit {
+			break
+		}
+		if status != "" && r.deadLetters[i].Status != status {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		res = append(res, copyDeadLetter(&r.deadLetters[i]))
+	}
+	return res, nil
+}
+
+func (r *memoryRepository) ListDueDeadLetters(now int64, limit int) ([]DeadLetterBatch, error) {
+	r.rlock()
+	defer r.runlock()
+
+	var res []DeadLetterBatch
+	for i := range r.deadLetters {
+		if r.deadLetters[i].Status == DeadLetterStatusPending && r.deadLetters[i].NextRetryAt <= now {
+			res = append(res, copyDeadLetter(&r.deadLetters[i]))
+		}
+	}
+	// same order as the sql repositories, by next retry time
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].NextRetryAt < res[j].NextRetryAt
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *memoryRepository) UpdateDeadLetter(batch *DeadLetterBatch) error {
+	r.lock()
+	defer r.unlock()
+	if batch.ID == 0 || batch.ID > uint64(len(r.deadLetters)) {
+		return nil
+	}
+
+	stored := &r.deadLetters[batch.ID-1]
+	stored.Payload = copyDeadLetter(batch).Payload
+	stored.Error = batch.Error
+	stored.Attempts = batch.Attempts
+	stored.Status = batch.Status
+	stored.NextRetryAt = batch.NextRetryAt
+	stored.UpdatedAt = time.Now().Unix()
+	return nil
+}
+
+// copyDeadLetter copies the payload too, so that stored batches are not shared with callers
+func copyDeadLetter(batch *DeadLetterBatch) DeadLetterBatch {
+	c := *batch
+	c.Payload.Fees = append([]UniTrxFee(nil), batch.Payload.Fees...)
+	if batch.Payload.PriceQuery != nil {
+		q := *batch.Payload.PriceQuery
+		c.Payload.PriceQuery = &q
+	}
+	return c
+}
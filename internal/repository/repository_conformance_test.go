@@ -0,0 +1,819 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/migration"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The conformance suite runs against every Repository backend. SQLite always
+// runs on a temporary file, MySQL and PostgreSQL run when a DSN is given.
+// The in-memory repository runs it in TestMemoryRepositoryConformance.
+func TestRepositoryConformance(t *testing.T) {
+	backends := map[string]string{
+		repository.DriverSQLite:   "file:" + filepath.Join(t.TempDir(), "trx_fee.db") + "?_pragma=busy_timeout(5000)",
+		repository.DriverMySQL:    os.Getenv("FEEDEX_TEST_MYSQL_DSN"),
+		repository.DriverPostgres: os.Getenv("FEEDEX_TEST_POSTGRES_DSN"),
+	}
+
+	for driver, dsn := range backends {
+		t.Run(driver, func(t *testing.T) {
+			if dsn == "" {
+				t.Skipf("set the %s test DSN to run the conformance suite", driver)
+			}
+			m, err := migration.NewMigrator(driver, dsn)
+			require.NoError(t, err)
+			require.NoError(t, m.Up(false, io.Discard))
+			m.Close()
+
+			repo, err := repository.NewRepository(driver, dsn)
+			require.NoError(t, err)
+			defer repo.Close()
+
+			runConformanceSuite(t, repo)
+		})
+	}
+}
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	runConformanceSuite(t, repository.NewMemoryRepository())
+}
+
+func TestMySQLBulkLoad(t *testing.T) {
+	dsn := os.Getenv("FEEDEX_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("set the mysql test DSN to run the bulk load test")
+	}
+	m, err := migration.NewMigrator(repository.DriverMySQL, dsn)
+	require.NoError(t, err)
+	require.NoError(t, m.Up(false, io.Discard))
+	m.Close()
+
+	repo, err := repository.NewRepository(repository.DriverMySQL, dsn, repository.WithBulkLoadThreshold(1))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	symbol := fmt.Sprintf("TEST/%d", time.Now().UnixNano())
+	fees := testFees(symbol, "load", 3)
+	assert.Equal(t, 3, insertFees(t, repo, fees).Inserted)
+	// already stored hashes are skipped like by the insert
+	next := append(fees[:1:1], testFees(symbol, "load-next", 1)...)
+	assert.Equal(t, []repository.InsertOutcome{repository.OutcomeSkipped, repository.OutcomeInserted}, insertFees(t, repo, next).Outcomes)
+
+	got, err := repo.GetTrxFee(fees[2].TrxHash)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, fees[2].GasPrice.String(), got.GasPrice.String())
+	assert.True(t, fees[2].TrxFeeUsdt.Equal(got.TrxFeeUsdt))
+	assert.Equal(t, repository.PriceSourceUnknown, got.PriceSource)
+}
+
+func TestPriceSourceRank(t *testing.T) {
+	ranked := []string{"binance_1s", "binance_1m", "binance_15m", "binance_12h", "binance_1d", "binance_1M"}
+	for i := 1; i < len(ranked); i++ {
+		assert.Greater(t, repository.PriceSourceRank(ranked[i-1]), repository.PriceSourceRank(ranked[i]), ranked[i])
+	}
+	for _, source := range []string{repository.PriceSourceUnknown, "binance_", "binance_xm", "coinbase_1m"} {
+		assert.Less(t, repository.PriceSourceRank(source), repository.PriceSourceRank("binance_1M"), source)
+	}
+}
+
+func TestNewRepositoryRejectsUnknownDriver(t *testing.T) {
+	_, err := repository.NewRepository("oracle", "")
+	assert.Error(t, err)
+}
+
+func runConformanceSuite(t *testing.T, repo repository.Repository) {
+	// shared databases may hold rows from previous runs, so every run uses its own symbol
+	symbol := fmt.Sprintf("TEST/%d", time.Now().UnixNano())
+
+	t.Run("BatchInsertUniTrxFee", func(t *testing.T) {
+		fees := testFees(symbol, "insert", 3)
+		report := insertFees(t, repo, fees)
+		assert.Equal(t, 3, report.Inserted)
+		assert.Equal(t, []repository.InsertOutcome{repository.OutcomeInserted, repository.OutcomeInserted, repository.OutcomeInserted},
+			report.Outcomes)
+		// duplicated hashes are skipped
+		report = insertFees(t, repo, fees[:1])
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, []repository.InsertOutcome{repository.OutcomeSkipped}, report.Outcomes)
+		assert.Empty(t, insertFees(t, repo, nil).Outcomes)
+
+		got, err := repo.GetTrxFee(fees[0].TrxHash)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.NotZero(t, got.ID)
+		assert.Equal(t, fees[0].Symbol, got.Symbol)
+		assert.Equal(t, fees[0].TrxTime, got.TrxTime)
+		assert.Equal(t, fees[0].GasUsed, got.GasUsed)
+		assert.Equal(t, 0, fees[0].GasPrice.Cmp(got.GasPrice))
+		assert.Equal(t, 0, fees[0].TrxFeeWei.Cmp(got.TrxFeeWei))
+		assert.True(t, fees[0].TrxFeeEth.Equal(got.TrxFeeEth), got.TrxFeeEth.String())
+		assert.True(t, fees[0].TrxFeeUsdt.Equal(got.TrxFeeUsdt), got.TrxFeeUsdt.String())
+		assert.True(t, fees[0].EthUsdtPrice.Equal(got.EthUsdtPrice), got.EthUsdtPrice.String())
+		assert.Equal(t, fees[0].FromAddress, got.FromAddress)
+		assert.Equal(t, uint64(1), got.Version)
+		assert.NotZero(t, got.UpdatedAt)
+
+		got, err = repo.GetTrxFee("0xunknown")
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+
+		byHash, err := repo.ListTrxFeeByHash([]string{fees[2].TrxHash, "0xunknown", fees[0].TrxHash, fees[2].TrxHash})
+		require.NoError(t, err)
+		var hashes []string
+		for _, fee := range byHash {
+			hashes = append(hashes, fee.TrxHash)
+		}
+		assert.ElementsMatch(t, []string{fees[0].TrxHash, fees[2].TrxHash}, hashes)
+
+		list, err := repo.ListTrxFee(repository.TrxFeeListQuery{Filter: repository.TrxFeeFilter{Symbol: symbol, EndTime: 1 << 40}, Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, list, 3)
+	})
+
+	t.Run("BatchInsertUniTrxFeeChunks", func(t *testing.T) {
+		chunkSymbol := symbol + "/chunks"
+		fees := testFees(chunkSymbol, "chunk", 2500)
+		// repeated within the batch, and already stored
+		fees = append(fees, fees[1200])
+		insertFees(t, repo, fees[2400:2410])
+
+		report := insertFees(t, repo, fees)
+		assert.Equal(t, []int{2490, 0, 11}, []int{report.Inserted, report.Updated, report.Skipped})
+		assert.Equal(t, repository.OutcomeInserted, report.Outcomes[1200])
+		assert.Equal(t, repository.OutcomeSkipped, report.Outcomes[2405])
+		assert.Equal(t, repository.OutcomeSkipped, report.Outcomes[2500])
+		count, err := repo.CountTrxFee(repository.TrxFeeFilter{Symbol: chunkSymbol, EndTime: 1 << 40})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2500), count)
+	})
+
+	t.Run("ConflictPolicy", func(t *testing.T) {
+		conflictSymbol := symbol + "/conflict"
+		stored := testFees(conflictSymbol, "conflict", 3)
+		for i := range stored {
+			stored[i].SetPrice(decimal.NewFromInt(1000), "binance_12h")
+		}
+		insertFees(t, repo, stored)
+		before, err := repo.GetTrxFee(stored[0].TrxHash)
+		require.NoError(t, err)
+		require.NotNil(t, before)
+
+		// the same trxs priced from a finer, a coarser and an unknown source
+		repriced := testFees(conflictSymbol, "conflict", 3)
+		repriced[0].SetPrice(decimal.NewFromInt(2000), "binance_1m")
+		repriced[1].SetPrice(decimal.NewFromInt(2000), "binance_1d")
+		repriced[2].SetPrice(decimal.NewFromInt(2000), repository.PriceSourceUnknown)
+		report, err := repo.BatchInsertUniTrxFee(repriced, repository.ConflictOverwriteIfBetterPrice)
+		require.NoError(t, err)
+		assert.Equal(t, []repository.InsertOutcome{repository.OutcomeUpdated, repository.OutcomeSkipped, repository.OutcomeSkipped},
+			report.Outcomes)
+
+		got, err := repo.GetTrxFee(stored[0].TrxHash)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, before.ID, got.ID)
+		assert.Equal(t, uint64(2), got.Version)
+		assert.Equal(t, "binance_1m", got.PriceSource)
+		assert.True(t, repriced[0].TrxFeeUsdt.Equal(got.TrxFeeUsdt), got.TrxFeeUsdt.String())
+		got, err = repo.GetTrxFee(stored[1].TrxHash)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), got.Version)
+		assert.Equal(t, "binance_12h", got.PriceSource)
+
+		report, err = repo.BatchInsertUniTrxFee(repriced[1:], repository.ConflictOverwrite)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Updated)
+		got, err = repo.GetTrxFee(stored[2].TrxHash)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), got.Version)
+		assert.Equal(t, repository.PriceSourceUnknown, got.PriceSource)
+
+		report, err = repo.BatchInsertUniTrxFee(stored[:1], repository.ConflictKeepExisting)
+		require.NoError(t, err)
+		assert.Equal(t, []repository.InsertOutcome{repository.OutcomeSkipped}, report.Outcomes)
+		got, err = repo.GetTrxFee(stored[0].TrxHash)
+		require.NoError(t, err)
+		assert.Equal(t, "binance_1m", got.PriceSource)
+	})
+
+	t.Run("ConcurrentInsert", func(t *testing.T) {
+		concurrentSymbol := symbol + "/concurrent"
+		fees := testFees(concurrentSymbol, "concurrent", 2)
+
+		// the batch reads the stored fees while fees[0] is inserted by a
+		// transaction that commits later: the databases that let it run
+		// concurrently find the conflict when inserting fees[0], the others run
+		// it after the commit and plan fees[0] as stored
+		stored, commit := make(chan struct{}), make(chan struct{})
+		txErr := make(chan error, 1)
+		go func() {
+			txErr <- repo.WithTx(context.Background(), func(tx repository.TxRepository) error {
+				_, err := tx.BatchInsertUniTrxFee(fees[:1], repository.ConflictKeepExisting)
+				close(stored)
+				<-commit
+				return err
+			})
+		}()
+		<-stored
+		time.AfterFunc(100*time.Millisecond, func() { close(commit) })
+		report, err := repo.BatchInsertUniTrxFee(fees, repository.ConflictKeepExisting)
+		require.NoError(t, err)
+		require.NoError(t, <-txErr)
+		assert.Equal(t, []repository.InsertOutcome{repository.OutcomeSkipped, repository.OutcomeInserted}, report.Outcomes)
+		assert.Equal(t, 1, report.Inserted)
+		assert.Equal(t, 1, report.Skipped)
+
+		count, err := repo.CountTrxFee(repository.TrxFeeFilter{Symbol: concurrentSymbol, EndTime: 1 << 40})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("WithTx", func(t *testing.T) {
+		maxBlock, err := repo.GetMaxBlockNum(symbol)
+		require.NoError(t, err)
+		assert.Zero(t, maxBlock)
+
+		record := func(fees []repository.UniTrxFee, maxBlock uint64) error {
+			return repo.WithTx(context.Background(), func(tx repository.TxRepository) error {
+				if _, err := tx.BatchInsertUniTrxFee(fees, repository.ConflictKeepExisting); err != nil {
+					return err
+				}
+				return tx.RecordMaxBlockNum(symbol, maxBlock)
+			})
+		}
+		require.NoError(t, record(testFees(symbol, "historical", 2), 100))
+		require.NoError(t, record(testFees(symbol, "historical-next", 1), 200))
+
+		// nothing written by a failed transaction is kept
+		errAbort := errors.New("abort")
+		batch := &repository.DeadLetterBatch{Symbol: symbol, Source: repository.DeadLetterSourceLive, Status: repository.DeadLetterStatusPending}
+		err = repo.WithTx(context.Background(), func(tx repository.TxRepository) error {
+			insertFees(t, tx, testFees(symbol, "rolled-back", 1))
+			require.NoError(t, tx.RecordMaxBlockNum(symbol, 300))
+			require.NoError(t, tx.SetJobWatermark(symbol, 42))
+			require.NoError(t, tx.InsertDeadLetter(batch))
+
+			// the transaction reads its own writes
+			fee, err := tx.GetTrxFee("0x" + symbol + "-rolled-back-0")
+			require.NoError(t, err)
+			require.NotNil(t, fee)
+			return errAbort
+		})
+		assert.ErrorIs(t, err, errAbort)
+
+		fee, err := repo.GetTrxFee("0x" + symbol + "-rolled-back-0")
+		require.NoError(t, err)
+		assert.Nil(t, fee)
+		watermark, err := repo.GetJobWatermark(symbol)
+		require.NoError(t, err)
+		assert.Zero(t, watermark)
+		got, err := repo.GetDeadLetter(batch.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+
+		maxBlock, err = repo.GetMaxBlockNum(symbol)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(200), maxBlock)
+
+		count, err := repo.CountTrxFee(repository.TrxFeeFilter{Symbol: symbol, EndTime: 1 << 40})
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), count)
+	})
+
+	t.Run("ListTrxFee", func(t *testing.T) {
+		listSymbol := symbol + "/list"
+		fees := testFees(listSymbol, "list", 5)
+		// two fees share a trx time, they are ordered by id
+		for i, trxTime := range []uint64{30, 10, 20, 20, 40} {
+			fees[i].TrxTime = trxTime
+		}
+		insertFees(t, repo, fees)
+
+		all, err := repo.ListTrxFee(repository.TrxFeeListQuery{Filter: repository.TrxFeeFilter{Symbol: listSymbol, EndTime: 100}, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{fees[1].TrxHash, fees[2].TrxHash, fees[3].TrxHash, fees[0].TrxHash, fees[4].TrxHash}, trxHashes(all))
+
+		list, err := repo.ListTrxFee(repository.TrxFeeListQuery{Filter: repository.TrxFeeFilter{Symbol: listSymbol, StartTime: 15, EndTime: 35}, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{fees[2].TrxHash, fees[3].TrxHash, fees[0].TrxHash}, trxHashes(list))
+
+		query := repository.TrxFeeListQuery{Filter: repository.TrxFeeFilter{Symbol: listSymbol, EndTime: 100}, Limit: 2}
+		after := query.NewTrxFeeCursor(&all[1])
+		query.After = &after
+		list, err = repo.ListTrxFee(query)
+		require.NoError(t, err)
+		assert.Equal(t, trxHashes(all[2:4]), trxHashes(list))
+
+		query.Desc, query.Limit = true, 10
+		list, err = repo.ListTrxFee(query)
+		require.NoError(t, err)
+		assert.Equal(t, trxHashes(all[:1]), trxHashes(list))
+
+		count, err := repo.CountTrxFee(repository.TrxFeeFilter{Symbol: listSymbol, StartTime: 15, EndTime: 35})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("ListTrxFeeFilters", func(t *testing.T) {
+		filterSymbol := symbol + "/filter"
+		// fees are 0.021, 0.042, 0.063, 0.084 and 0.105 USDT, blocks 100 to 104
+		fees := testFees(filterSymbol, "filter", 5)
+		fees[1].FromAddress = "0xother"
+		fees[2].Status = repository.TrxStatusFailed
+		fees[3].GasPrice = util.WeiFromGwei(decimal.NewFromInt(10))
+		fees[3].SetPrice(decimal.NewFromInt(1000), "binance_12h")
+		insertFees(t, repo, fees)
+
+		u64 := func(v uint64) *uint64 { return &v }
+		dec := func(s string) *decimal.Decimal { d := decimal.RequireFromString(s); return &d }
+		wei := func(gwei int64) *util.Wei { w := util.WeiFromGwei(decimal.NewFromInt(gwei)); return &w }
+		for name, tc := range map[string]struct {
+			filter   repository.TrxFeeFilter
+			expected []int
+		}{
+			"block range":     {repository.TrxFeeFilter{MinBlock: u64(101), MaxBlock: u64(102)}, []int{1, 2}},
+			"fee usdt range":  {repository.TrxFeeFilter{MinFeeUsdt: dec("0.05"), MaxFeeUsdt: dec("0.1")}, []int{2}},
+			"fee eth minimum": {repository.TrxFeeFilter{MinFeeEth: dec("0.0001")}, []int{3, 4}},
+			"gas price range": {repository.TrxFeeFilter{MinGasPrice: wei(2), MaxGasPrice: wei(10)}, []int{3}},
+			"gas used range":  {repository.TrxFeeFilter{MinGasUsed: u64(42000), MaxGasUsed: u64(63000)}, []int{1, 2}},
+			"address":         {repository.TrxFeeFilter{FromAddress: "0xOther"}, []int{1}},
+			"status":          {repository.TrxFeeFilter{Status: repository.TrxStatusFailed}, []int{2}},
+			"unknown status":  {repository.TrxFeeFilter{Status: repository.TrxStatusUnknown}, []int{0, 1, 3, 4}},
+			"price source":    {repository.TrxFeeFilter{PriceSource: "binance_12h"}, []int{3}},
+			"default source":  {repository.TrxFeeFilter{PriceSource: repository.PriceSourceUnknown}, []int{0, 1, 2, 4}},
+		} {
+			tc.filter.Symbol, tc.filter.EndTime = filterSymbol, 1<<40
+			list, err := repo.ListTrxFee(repository.TrxFeeListQuery{Filter: tc.filter, Limit: 10})
+			require.NoError(t, err, name)
+			var expected []string
+			for _, i := range tc.expected {
+				expected = append(expected, fees[i].TrxHash)
+			}
+			assert.Equal(t, expected, trxHashes(list), name)
+
+			count, err := repo.CountTrxFee(tc.filter)
+			require.NoError(t, err, name)
+			assert.Equal(t, int64(len(expected)), count, name)
+		}
+	})
+
+	t.Run("ListTrxFeeSort", func(t *testing.T) {
+		sortSymbol := symbol + "/sort"
+		fees := testFees(sortSymbol, "sort", 4)
+		// fees in USDT: 0.021, 0.042, 0.021, 0.0105; the equal ones are ordered by id
+		fees[2].GasUsed = 21000
+		fees[3].GasUsed = 10500
+		for i := range fees {
+			fees[i].SetPrice(decimal.NewFromInt(1000), "binance_1m")
+		}
+		insertFees(t, repo, fees)
+
+		filter := repository.TrxFeeFilter{Symbol: sortSymbol, EndTime: 1 << 40}
+		for _, sort := range []repository.TrxFeeSortField{repository.SortByFeeUsdt, repository.SortByGasUsed} {
+			// walk the pages one fee at a time to exercise the cursor
+			for _, desc := range []bool{false, true} {
+				query := repository.TrxFeeListQuery{Filter: filter, Sort: sort, Desc: desc, Limit: 1}
+				var hashes []string
+				for len(hashes) <= len(fees) {
+					list, err := repo.ListTrxFee(query)
+					require.NoError(t, err)
+					if len(list) == 0 {
+						break
+					}
+					hashes = append(hashes, list[0].TrxHash)
+					after := query.NewTrxFeeCursor(&list[0])
+					query.After = &after
+				}
+
+				expected := []string{fees[3].TrxHash, fees[0].TrxHash, fees[2].TrxHash, fees[1].TrxHash}
+				if desc {
+					expected = []string{fees[1].TrxHash, fees[2].TrxHash, fees[0].TrxHash, fees[3].TrxHash}
+				}
+				assert.Equal(t, expected, hashes, "%s desc=%v", sort, desc)
+			}
+		}
+	})
+
+	t.Run("DecimalPrecision", func(t *testing.T) {
+		preciseSymbol := symbol + "/precise"
+		// values only 1 apart in their last digit, equal once converted to doubles
+		fees := testFees(preciseSymbol, "precise", 3)
+		for i := range fees {
+			fees[i].GasPrice = util.WeiFromUint64(1<<53 + uint64(i))
+			fees[i].TrxFeeUsdt = decimal.RequireFromString("1000000.00000000000000000" + strconv.Itoa(i))
+		}
+		insertFees(t, repo, fees)
+
+		filter := repository.TrxFeeFilter{Symbol: preciseSymbol, EndTime: 1 << 40}
+		fee := decimal.RequireFromString("1000000.000000000000000001")
+		gasPrice := util.WeiFromUint64(1<<53 + 1)
+		for name, f := range map[string]repository.TrxFeeFilter{
+			"fee usdt":  {MinFeeUsdt: &fee, MaxFeeUsdt: &fee},
+			"gas price": {MinGasPrice: &gasPrice, MaxGasPrice: &gasPrice},
+		} {
+			f.Symbol, f.EndTime = filter.Symbol, filter.EndTime
+			list, err := repo.ListTrxFee(repository.TrxFeeListQuery{Filter: f, Limit: 10})
+			require.NoError(t, err, name)
+			assert.Equal(t, []string{fees[1].TrxHash}, trxHashes(list), name)
+		}
+
+		for _, sort := range []repository.TrxFeeSortField{repository.SortByFeeUsdt, repository.SortByGasPrice} {
+			query := repository.TrxFeeListQuery{Filter: filter, Sort: sort, Desc: true, Limit: 1}
+			var hashes []string
+			for len(hashes) <= len(fees) {
+				list, err := repo.ListTrxFee(query)
+				require.NoError(t, err)
+				if len(list) == 0 {
+					break
+				}
+				hashes = append(hashes, list[0].TrxHash)
+				after := query.NewTrxFeeCursor(&list[0])
+				query.After = &after
+			}
+			assert.Equal(t, []string{fees[2].TrxHash, fees[1].TrxHash, fees[0].TrxHash}, hashes, sort)
+		}
+
+		stats, err := repo.GetTrxFeeStats(filter)
+		require.NoError(t, err)
+		assert.Equal(t, "3000000.000000000000000003", stats.SumFeeUsdt.String())
+		assert.Equal(t, "1000000.000000000000000002", stats.MaxFeeUsdt.String())
+		assert.Equal(t, "27021597764222979", stats.SumGasPrice.String())
+		assert.Equal(t, "1000000.000000000000000001", stats.FeeUsdt.Median.String())
+
+		buckets, err := repo.GetTrxFeeSeries(filter, 3600)
+		require.NoError(t, err)
+		require.Len(t, buckets, 1)
+		assert.Equal(t, "3000000.000000000000000003", buckets[0].SumFeeUsdt.String())
+	})
+
+	t.Run("GetGasPricePercentile", func(t *testing.T) {
+		gasSymbol := symbol + "/gas"
+		// 1 to 10 gwei, inserted in reverse, so that 9 and 10 gwei of different
+		// widths are compared by value
+		fees := testFees(gasSymbol, "gas", 10)
+		for i := range fees {
+			fees[i].GasPrice = util.WeiFromGwei(decimal.NewFromInt(int64(10 - i)))
+		}
+		insertFees(t, repo, fees)
+
+		filter := repository.TrxFeeFilter{Symbol: gasSymbol, EndTime: 1 << 40}
+		for p, gwei := range map[int]int64{1: 1, 50: 5, 90: 9, 91: 10, 100: 10} {
+			res, err := repo.GetGasPricePercentile(filter, p)
+			require.NoError(t, err)
+			assert.Equal(t, int64(10), res.Count)
+			assert.Equal(t, util.WeiFromGwei(decimal.NewFromInt(gwei)).String(), res.GasPrice.String(), p)
+		}
+
+		empty, err := repo.GetGasPricePercentile(repository.TrxFeeFilter{Symbol: gasSymbol, StartTime: 1 << 40, EndTime: 1 << 41}, 50)
+		require.NoError(t, err)
+		assert.Zero(t, empty.Count)
+		assert.Equal(t, "0", empty.GasPrice.String())
+	})
+
+	t.Run("GetTrxFeeStats", func(t *testing.T) {
+		statsSymbol := symbol + "/stats"
+		// fees are 0.021 to 0.21 USDT, inserted in reverse so that the database has to sort them
+		fees := testFees(statsSymbol, "stats", 10)
+		for i, j := 0, len(fees)-1; i < j; i, j = i+1, j-1 {
+			fees[i], fees[j] = fees[j], fees[i]
+		}
+		insertFees(t, repo, fees)
+
+		stats, err := repo.GetTrxFeeStats(repository.TrxFeeFilter{Symbol: statsSymbol, EndTime: 1 << 40})
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), stats.Count)
+		assertDecimal(t, "1.155", stats.SumFeeUsdt)
+		assertDecimal(t, "0.021", stats.MinFeeUsdt)
+		assertDecimal(t, "0.21", stats.MaxFeeUsdt)
+		assertDecimal(t, "0.001155", stats.SumFeeEth)
+		assertDecimal(t, "0.000021", stats.MinFeeEth)
+		assertDecimal(t, "0.00021", stats.MaxFeeEth)
+		assertDecimal(t, "10000000000", stats.SumGasPrice)
+		assertDecimal(t, "1155000", stats.SumGasUsed)
+		assertDecimal(t, "0.105", stats.FeeUsdt.Median)
+		assertDecimal(t, "0.189", stats.FeeUsdt.P90)
+		assertDecimal(t, "0.21", stats.FeeUsdt.P99)
+		assertDecimal(t, "0.000105", stats.FeeEth.Median)
+		assertDecimal(t, "0.000189", stats.FeeEth.P90)
+		assertDecimal(t, "0.00021", stats.FeeEth.P99)
+
+		empty, err := repo.GetTrxFeeStats(repository.TrxFeeFilter{Symbol: statsSymbol, StartTime: 1 << 40, EndTime: 1 << 41})
+		require.NoError(t, err)
+		assert.Equal(t, repository.TrxFeeStats{}, *empty)
+	})
+
+	t.Run("GetTrxFeeSeries", func(t *testing.T) {
+		seriesSymbol := symbol + "/series"
+		// fees are 0.021, 0.042, 0.063 and 0.084 USDT
+		fees := testFees(seriesSymbol, "series", 4)
+		for i, trxTime := range []uint64{3659, 3600, 3700, 7300} {
+			fees[i].TrxTime = trxTime
+		}
+		insertFees(t, repo, fees)
+
+		buckets, err := repo.GetTrxFeeSeries(repository.TrxFeeFilter{Symbol: seriesSymbol, EndTime: 1 << 40}, 60)
+		require.NoError(t, err)
+		require.Len(t, buckets, 3)
+		assert.Equal(t, []int64{3600, 3660, 7260}, []int64{buckets[0].Start, buckets[1].Start, buckets[2].Start})
+		assert.Equal(t, []int64{2, 1, 1}, []int64{buckets[0].Count, buckets[1].Count, buckets[2].Count})
+		assertDecimal(t, "0.063", buckets[0].SumFeeUsdt)
+		assertDecimal(t, "0.021", buckets[0].MinFeeUsdt)
+		assertDecimal(t, "0.042", buckets[0].MaxFeeUsdt)
+		assertDecimal(t, "0.000063", buckets[0].SumFeeEth)
+		assertDecimal(t, "63000", buckets[0].SumGasUsed)
+		assertDecimal(t, "0.084", buckets[2].MaxFeeUsdt)
+
+		buckets, err = repo.GetTrxFeeSeries(repository.TrxFeeFilter{Symbol: seriesSymbol, StartTime: 3650, EndTime: 1 << 40}, 3600)
+		require.NoError(t, err)
+		require.Len(t, buckets, 2)
+		assert.Equal(t, []int64{3600, 7200}, []int64{buckets[0].Start, buckets[1].Start})
+		assert.Equal(t, int64(2), buckets[0].Count)
+	})
+
+	t.Run("TrxFeeRollup", func(t *testing.T) {
+		rollupSymbol := symbol + "/rollup"
+		// blocks 100 to 102 in the first hour, 103 in the second
+		fees := testFees(rollupSymbol, "rollup", 4)
+		hours := []repository.TrxFeeRollup{
+			{Symbol: rollupSymbol, Period: repository.RollupHour, Start: 3600},
+			{Symbol: rollupSymbol, Period: repository.RollupHour, Start: 7200},
+		}
+		for i := range fees[:3] {
+			hours[0].Add(&fees[i])
+		}
+		hours[1].Add(&fees[3])
+		day := repository.TrxFeeRollup{Symbol: rollupSymbol, Period: repository.RollupDay, Start: 0}
+		day.Merge(&hours[0])
+		day.Merge(&hours[1])
+		for _, rollup := range []*repository.TrxFeeRollup{&hours[0], &hours[1], &day} {
+			require.NoError(t, repo.SaveTrxFeeRollup(rollup))
+		}
+		// saving again replaces the rollup
+		require.NoError(t, repo.SaveTrxFeeRollup(&hours[0]))
+
+		list, err := repo.ListTrxFeeRollups(repository.RollupHour, rollupSymbol, 0, 86400)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, int64(3600), list[0].Start)
+		assert.Equal(t, int64(3), list[0].Count)
+		assertDecimal(t, "0.126", list[0].SumFeeUsdt)
+		assertDecimal(t, "0.021", list[0].MinFeeUsdt)
+		assertDecimal(t, "0.063", list[0].MaxFeeUsdt)
+		assertDecimal(t, "0.000126", list[0].SumFeeEth)
+		assertDecimal(t, "3000000000", list[0].SumGasPrice)
+		assertDecimal(t, "126000", list[0].SumGasUsed)
+		assert.Equal(t, []uint64{100, 102}, []uint64{list[0].MinBlock, list[0].MaxBlock})
+		assert.Equal(t, hours[0].SketchUsdt, list[0].SketchUsdt)
+		assert.Equal(t, hours[0].SketchEth, list[0].SketchEth)
+
+		list, err = repo.ListTrxFeeRollups(repository.RollupHour, rollupSymbol, 7200, 86400)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, int64(1), list[0].Count)
+
+		list, err = repo.ListTrxFeeRollups(repository.RollupDay, rollupSymbol, 0, 86400)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, int64(4), list[0].Count)
+		assert.Equal(t, []uint64{100, 103}, []uint64{list[0].MinBlock, list[0].MaxBlock})
+
+		starts, err := repo.ListRollupHoursByBlock(rollupSymbol, 102, 110)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3600, 7200}, starts)
+		starts, err = repo.ListRollupHoursByBlock(rollupSymbol, 103, 103)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{7200}, starts)
+
+		// a rollup of no fees is deleted
+		require.NoError(t, repo.SaveTrxFeeRollup(&repository.TrxFeeRollup{Symbol: rollupSymbol, Period: repository.RollupHour, Start: 7200}))
+		list, err = repo.ListTrxFeeRollups(repository.RollupHour, rollupSymbol, 0, 86400)
+		require.NoError(t, err)
+		assert.Len(t, list, 1)
+
+		job := symbol + "/job"
+		watermark, err := repo.GetJobWatermark(job)
+		require.NoError(t, err)
+		assert.Zero(t, watermark)
+		require.NoError(t, repo.SetJobWatermark(job, 10))
+		require.NoError(t, repo.SetJobWatermark(job, 1700000000))
+		watermark, err = repo.GetJobWatermark(job)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1700000000), watermark)
+	})
+
+	t.Run("ListTrxFeeHoursModifiedAfter", func(t *testing.T) {
+		hoursSymbol := symbol + "/hours"
+		fees := testFees(hoursSymbol, "hours", 3)
+		for i, trxTime := range []uint64{3659, 3600, 7300} {
+			fees[i].TrxTime = trxTime
+		}
+		insertFees(t, repo, fees)
+		written, err := repo.ListTrxFeeModifiedAfter(repository.TrxFeeTimeCursor{}, repository.StreamFilter{Symbol: hoursSymbol}, 10)
+		require.NoError(t, err)
+		require.Len(t, written, 3)
+		first, last := written[0].UpdatedAt, written[2].UpdatedAt
+
+		starts, err := repo.ListTrxFeeHoursModifiedAfter(hoursSymbol, first-1, 0, 86400)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3600, 7200}, starts)
+		starts, err = repo.ListTrxFeeHoursModifiedAfter(hoursSymbol, first-1, 3600, 7200)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3600}, starts)
+		starts, err = repo.ListTrxFeeHoursModifiedAfter(hoursSymbol, last, 0, 86400)
+		require.NoError(t, err)
+		assert.Empty(t, starts)
+	})
+
+	t.Run("ListTrxFeeModifiedAfter", func(t *testing.T) {
+		modifiedSymbol := symbol + "/modified"
+		filter := repository.StreamFilter{Symbol: modifiedSymbol}
+		fees := testFees(modifiedSymbol, "modified", 3)
+		insertFees(t, repo, fees)
+
+		all, err := repo.ListTrxFeeModifiedAfter(repository.TrxFeeTimeCursor{}, filter, 10)
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+		for i := 1; i < len(all); i++ {
+			assert.True(t, all[i-1].UpdatedAt < all[i].UpdatedAt || (all[i-1].UpdatedAt == all[i].UpdatedAt && all[i-1].ID < all[i].ID))
+		}
+
+		// paging through the cursor of the last fee lists the same fees
+		var paged []repository.UniTrxFee
+		var after repository.TrxFeeTimeCursor
+		for {
+			page, err := repo.ListTrxFeeModifiedAfter(after, filter, 1)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			paged = append(paged, page...)
+			after = repository.TrxFeeTimeCursor{Time: page[0].UpdatedAt, ID: page[0].ID}
+		}
+		assert.Equal(t, trxHashes(all), trxHashes(paged))
+
+		// an overwritten fee is listed again from the time before its write
+		before := all[len(all)-1].UpdatedAt
+		_, err = repo.BatchInsertUniTrxFee(fees[:1], repository.ConflictOverwrite)
+		require.NoError(t, err)
+		list, err := repo.ListTrxFeeModifiedAfter(repository.TrxFeeTimeCursor{Time: before - 1}, filter, 10)
+		require.NoError(t, err)
+		assert.Contains(t, trxHashes(list), fees[0].TrxHash)
+	})
+
+	t.Run("ListTrxFeeCreatedAfter", func(t *testing.T) {
+		streamSymbol := symbol + "/stream"
+		fees := testFees(streamSymbol, "stream", 3)
+		fees[2].FromAddress = "0xother"
+		insertFees(t, repo, fees)
+
+		list, err := repo.ListTrxFeeCreatedAfter(repository.TrxFeeTimeCursor{}, repository.StreamFilter{Symbol: streamSymbol}, 10)
+		require.NoError(t, err)
+		require.Len(t, list, 3)
+		assert.Less(t, list[0].ID, list[1].ID)
+		assert.NotZero(t, list[0].CreatedAt)
+
+		// fees are 0.021, 0.042 and 0.063 USDT, compared by value and not as text
+		list, err = repo.ListTrxFeeCreatedAfter(repository.TrxFeeTimeCursor{}, repository.StreamFilter{Symbol: streamSymbol, MinFeeUsdt: decimal.RequireFromString("0.04")}, 10)
+		require.NoError(t, err)
+		assert.Len(t, list, 2)
+
+		list, err = repo.ListTrxFeeCreatedAfter(repository.TrxFeeTimeCursor{}, repository.StreamFilter{Symbol: streamSymbol, FromAddress: "0xOTHER"}, 10)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, fees[2].TrxHash, list[0].TrxHash)
+
+		first, err := repo.ListTrxFeeCreatedAfter(repository.TrxFeeTimeCursor{}, repository.StreamFilter{Symbol: streamSymbol}, 1)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		list, err = repo.ListTrxFeeCreatedAfter(repository.TrxFeeTimeCursor{Time: first[0].CreatedAt, ID: first[0].ID}, repository.StreamFilter{Symbol: streamSymbol}, 10)
+		require.NoError(t, err)
+		assert.Len(t, list, 2)
+
+		// overwriting a fee keeps its insert time
+		_, err = repo.BatchInsertUniTrxFee(fees[:1], repository.ConflictOverwrite)
+		require.NoError(t, err)
+		fee, err := repo.GetTrxFeeByID(first[0].ID)
+		require.NoError(t, err)
+		require.NotNil(t, fee)
+		assert.Equal(t, fees[0].TrxHash, fee.TrxHash)
+		assert.Equal(t, first[0].CreatedAt, fee.CreatedAt)
+		assert.Equal(t, uint64(2), fee.Version)
+
+		fee, err = repo.GetTrxFeeByID(1 << 60)
+		require.NoError(t, err)
+		assert.Nil(t, fee)
+	})
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		batch := &repository.DeadLetterBatch{
+			Symbol:     symbol,
+			Source:     repository.DeadLetterSourceLive,
+			StartBlock: 10,
+			EndBlock:   20,
+			Payload: repository.DeadLetterPayload{
+				Fees:       testFees(symbol, "dead-letter", 1),
+				PriceQuery: &repository.PriceQuery{Start: 1, End: 2, Interval: "1s"},
+			},
+			Error:       "price unavailable",
+			Status:      repository.DeadLetterStatusPending,
+			NextRetryAt: 100,
+		}
+		require.NoError(t, repo.InsertDeadLetter(batch))
+		assert.NotZero(t, batch.ID)
+
+		got, err := repo.GetDeadLetter(batch.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, batch.Source, got.Source)
+		assert.Equal(t, batch.Payload.PriceQuery, got.Payload.PriceQuery)
+		require.Len(t, got.Payload.Fees, 1)
+		assert.Equal(t, batch.Payload.Fees[0].TrxHash, got.Payload.Fees[0].TrxHash)
+		assert.NotZero(t, got.CreatedAt)
+
+		due, err := repo.ListDueDeadLetters(100, 1000)
+		require.NoError(t, err)
+		assert.True(t, containsDeadLetter(due, batch.ID))
+
+		got.Attempts = 1
+		got.Status = repository.DeadLetterStatusResolved
+		got.Payload.PriceQuery = nil
+		require.NoError(t, repo.UpdateDeadLetter(got))
+
+		got, err = repo.GetDeadLetter(batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.Attempts)
+		assert.Equal(t, repository.DeadLetterStatusResolved, got.Status)
+		assert.Nil(t, got.Payload.PriceQuery)
+
+		due, err = repo.ListDueDeadLetters(100, 1000)
+		require.NoError(t, err)
+		assert.False(t, containsDeadLetter(due, batch.ID))
+
+		missing, err := repo.GetDeadLetter(batch.ID + 1000000)
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+}
+
+// testFees returns n priced fees with increasing gas used, unique to the symbol and prefix
+// insertFees inserts fees and fails the test on error
+func insertFees(t *testing.T, repo repository.TxRepository, fees []repository.UniTrxFee) repository.InsertReport {
+	t.Helper()
+	report, err := repo.BatchInsertUniTrxFee(fees, repository.ConflictKeepExisting)
+	require.NoError(t, err)
+	return report
+}
+
+func testFees(symbol string, prefix string, n int) []repository.UniTrxFee {
+	fees := make([]repository.UniTrxFee, n)
+	for i := range fees {
+		fees[i] = repository.UniTrxFee{
+			Symbol:      symbol,
+			TrxHash:     fmt.Sprintf("0x%s-%s-%d", symbol, prefix, i),
+			TrxTime:     uint64(1700000000 + i),
+			GasUsed:     uint64(21000 * (i + 1)),
+			GasPrice:    util.WeiFromGwei(decimal.NewFromInt(1)),
+			BlockNumber: uint64(100 + i),
+			FromAddress: "0xsender",
+		}
+		fees[i].SetPrice(decimal.NewFromInt(1000), repository.PriceSourceUnknown)
+	}
+	return fees
+}
+
+func assertDecimal(t *testing.T, expected string, actual decimal.Decimal) {
+	t.Helper()
+	assert.Equal(t, expected, actual.Round(12).String())
+}
+
+func trxHashes(fees []repository.UniTrxFee) []string {
+	hashes := make([]string, len(fees))
+	for i := range fees {
+		hashes[i] = fees[i].TrxHash
+	}
+	return hashes
+}
+
+func containsDeadLetter(batches []repository.DeadLetterBatch, id uint64) bool {
+	for _, b := range batches {
+		if b.ID == id {
+			return true
+		}
+	}
+	return false
+}
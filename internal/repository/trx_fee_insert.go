@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// maximum number of fees per insert statement, their 13 placeholders each
+// stay well below the limits of mysql and postgres (65535) and sqlite (32766)
+const insertChunkSize = 1000
+
+const uniTrxFeeInsertColumns = "symbol, trx_hash, trx_time, gas_used, gas_price, eth_usdt_price, trx_fee_wei, trx_fee_eth, trx_fee_usdt, block_num, from_address, trx_status, price_source"
+
+// ConflictPolicy decides what a batch insert does with a fee whose trx hash is
+// already stored
+type ConflictPolicy int
+
+const (
+	// ConflictKeepExisting skips the fee
+	ConflictKeepExisting ConflictPolicy = iota
+	// ConflictOverwrite replaces the stored fee
+	ConflictOverwrite
+	// ConflictOverwriteIfBetterPrice replaces the stored fee if the price
+	// source of the fee ranks higher, see PriceSourceRank
+	ConflictOverwriteIfBetterPrice
+)
+
+// overwrites reports whether fee replaces a stored fee priced from storedSource
+func (p ConflictPolicy) overwrites(storedSource string, fee *UniTrxFee) bool {
+	switch p {
+	case ConflictOverwrite:
+		return true
+	case ConflictOverwriteIfBetterPrice:
+		return PriceSourceRank(orDefault(fee.PriceSource, PriceSourceUnknown)) > PriceSourceRank(storedSource)
+	default:
+		return false
+	}
+}
+
+// seconds of the units of binance kline intervals
+var klineUnits = map[byte]int64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 7 * 86400, 'M': 30 * 86400}
+
+// PriceSourceRank orders price sources by accuracy: the average of binance
+// klines is better the shorter their interval, and any of them is better than
+// an unknown source
+func PriceSourceRank(source string) int64 {
+	interval, ok := strings.CutPrefix(source, "binance_")
+	if !ok || len(interval) < 2 {
+		return math.MinInt64
+	}
+	n, err := strconv.ParseInt(interval[:len(interval)-1], 10, 64)
+	unit, ok := klineUnits[interval[len(interval)-1]]
+	if err != nil || !ok || n <= 0 {
+		return math.MinInt64
+	}
+	return -n * unit
+}
+
+// InsertOutcome is what a batch insert did with a fee
+type InsertOutcome string
+
+const (
+	OutcomeInserted InsertOutcome = "inserted"
+	OutcomeUpdated  InsertOutcome = "updated"
+	// the stored fee was kept, or the trx hash was repeated in the batch
+	OutcomeSkipped InsertOutcome = "skipped"
+)
+
+// InsertReport counts the fees of a batch insert by outcome
+type InsertReport struct {
+	Inserted int
+	Updated  int
+	Skipped  int
+	// outcome of every fee, in the order of the batch
+	Outcomes []InsertOutcome
+}
+
+// Fees returns the fees of the batch that had outcome
+func (r *InsertReport) Fees(fees []UniTrxFee, outcome InsertOutcome) []UniTrxFee {
+	var res []UniTrxFee
+	for i := range fees {
+		if r.Outcomes[i] == outcome {
+			res = append(res, fees[i])
+		}
+	}
+	return res
+}
+
+// planInsert decides the outcome of every fee, stored returns the price
+// source of the stored fee of a trx hash if there is one
+func planInsert(fees []UniTrxFee, policy ConflictPolicy, stored func(hash string) (string, bool)) InsertReport {
+	report := InsertReport{Outcomes: make([]InsertOutcome, len(fees))}
+	seen := make(map[string]struct{}, len(fees))
+	for i := range fees {
+		outcome := OutcomeSkipped
+		if _, ok := seen[fees[i].TrxHash]; !ok {
+			seen[fees[i].TrxHash] = struct{}{}
+			source, ok := stored(fees[i].TrxHash)
+			if !ok {
+				outcome = OutcomeInserted
+			} else if policy.overwrites(source, &fees[i]) {
+				outcome = OutcomeUpdated
+			}
+		}
+
+		report.Outcomes[i] = outcome
+		switch outcome {
+		case OutcomeInserted:
+			report.Inserted++
+		case OutcomeUpdated:
+			report.Updated++
+		default:
+			report.Skipped++
+		}
+	}
+	return report
+}
+
+// BatchInsertUniTrxFee stores fees, resolving the ones already stored with
+// policy. New fees are inserted in chunks of insertChunkSize, the batch is
+// stored all or none.
+func (r *repository) BatchInsertUniTrxFee(fees []UniTrxFee, policy ConflictPolicy) (InsertReport, error) {
+	if len(fees) == 0 {
+		return InsertReport{}, nil
+	}
+	if r.pool != nil {
+		var report InsertReport
+		err := r.WithTx(context.Background(), func(tx TxRepository) (err error) {
+			report, err = tx.BatchInsertUniTrxFee(fees, policy)
+			return err
+		})
+		return report, err
+	}
+
+	sources, err := r.storedPriceSources(fees)
+	if err != nil {
+		return InsertReport{}, err
+	}
+	report := planInsert(fees, policy, func(hash string) (string, bool) {
+		source, ok := sources[hash]
+		return source, ok
+	})
+
+	// the savepoint undoes the insert if fees were stored by another writer
+	// since the stored ones were read
+	if _, err = r.db.Exec("SAVEPOINT insert_fees"); err != nil {
+		return InsertReport{}, err
+	}
+	inserts := report.Fees(fees, OutcomeInserted)
+	var inserted int
+	if r.bulkLoadThreshold > 0 && len(inserts) >= r.bulkLoadThreshold {
+		inserted, err = r.loadUniTrxFee(inserts)
+	} else {
+		inserted, err = r.insertUniTrxFees(inserts)
+	}
+	if err != nil {
+		return InsertReport{}, err
+	}
+	if inserted != len(inserts) {
+		if _, err = r.db.Exec("ROLLBACK TO SAVEPOINT insert_fees"); err != nil {
+			return InsertReport{}, err
+		}
+		if err = r.insertReplanningConflicts(fees, policy, &report); err != nil {
+			return InsertReport{}, err
+		}
+	}
+	if err = r.updateUniTrxFees(report.Fees(fees, OutcomeUpdated)); err != nil {
+		return InsertReport{}, err
+	}
+	return report, nil
+}
+
+// insertReplanningConflicts inserts the fees planned as inserted one at a
+// time. The ones stored by another writer in the meantime are planned again
+// with policy, against the stored fee read with a locking read, which sees it
+// whatever the isolation level.
+func (r *repository) insertReplanningConflicts(fees []UniTrxFee, policy ConflictPolicy, report *InsertReport) error {
+	for i := range fees {
+		if report.Outcomes[i] != OutcomeInserted {
+			continue
+		}
+		inserted, err := r.insertUniTrxFees(fees[i : i+1])
+		if err != nil {
+			return err
+		}
+		if inserted == 1 {
+			continue
+		}
+
+		var source string
+		err = r.db.QueryRow(r.dialect.rebind("SELECT price_source FROM uni_trx_fee where trx_hash = ?"+r.dialect.forUpdate()),
+			fees[i].TrxHash).Scan(&source)
+		if err != nil {
+			return err
+		}
+		report.Inserted--
+		if policy.overwrites(source, &fees[i]) {
+			report.Outcomes[i] = OutcomeUpdated
+			report.Updated++
+		} else {
+			report.Outcomes[i] = OutcomeSkipped
+			report.Skipped++
+		}
+	}
+	return nil
+}
+
+// storedPriceSources returns the price sources of the stored fees of the trx hashes of fees
+func (r *repository) storedPriceSources(fees []UniTrxFee) (map[string]string, error) {
+	hashes := make([]string, len(fees))
+	for i := range fees {
+		hashes[i] = fees[i].TrxHash
+	}
+	stored, err := r.ListTrxFeeByHash(hashes)
+	if err != nil {
+		return nil, err
+	}
+	sources := make(map[string]string, len(stored))
+	for _, fee := range stored {
+		sources[fee.TrxHash] = fee.PriceSource
+	}
+	return sources, nil
+}
+
+// insertUniTrxFees inserts fees in chunks and returns how many were inserted,
+// conflicting ones are not
+func (r *repository) insertUniTrxFees(fees []UniTrxFee) (int, error) {
+	inserted := 0
+	// full chunks reuse the same prepared statement
+	var stmt *sql.Stmt
+	defer func() {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}()
+	for start := 0; start < len(fees); start += insertChunkSize {
+		chunk := fees[start:min(start+insertChunkSize, len(fees))]
+		args := make([]interface{}, 0, 13*len(chunk))
+		for i := range chunk {
+			args = append(args, r.uniTrxFeeArgs(&chunk[i])...)
+		}
+
+		var res sql.Result
+		var err error
+		if len(chunk) == insertChunkSize {
+			if stmt == nil {
+				if stmt, err = r.db.Prepare(r.insertUniTrxFeeStmt(insertChunkSize)); err != nil {
+					return inserted, err
+				}
+			}
+			res, err = stmt.Exec(args...)
+		} else {
+			res, err = r.db.Exec(r.insertUniTrxFeeStmt(len(chunk)), args...)
+		}
+		if err != nil {
+			return inserted, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return inserted, err
+		}
+		inserted += int(n)
+	}
+	return inserted, nil
+}
+
+// uniTrxFeeArgs returns the values of uniTrxFeeInsertColumns
+func (r *repository) uniTrxFeeArgs(fee *UniTrxFee) []interface{} {
+	return []interface{}{fee.Symbol, fee.TrxHash, fee.TrxTime, fee.GasUsed, r.dialect.decimal(fee.GasPrice.Decimal()),
+		r.dialect.decimal(fee.EthUsdtPrice), r.dialect.decimal(fee.TrxFeeWei.Decimal()), r.dialect.decimal(fee.TrxFeeEth),
+		r.dialect.decimal(fee.TrxFeeUsdt), fee.BlockNumber, fee.FromAddress,
+		orDefault(fee.Status, TrxStatusUnknown), orDefault(fee.PriceSource, PriceSourceUnknown)}
+}
+
+// insertUniTrxFeeStmt builds the insert of n fees
+func (r *repository) insertUniTrxFeeStmt(n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		// gmt_modified is set explicitly, sqlite can't default it to the current time
+		placeholders[i] = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)"
+	}
+	// conflicts are only possible with concurrent writers, which the caller
+	// detects from the number of inserted fees
+	return r.dialect.rebind(r.dialect.insertIgnore("uni_trx_fee ("+uniTrxFeeInsertColumns+", gmt_modified) VALUES "+
+		strings.Join(placeholders, ", "), "trx_hash"))
+}
+
+// updateUniTrxFees overwrites the stored fees of the same trx hashes
+func (r *repository) updateUniTrxFees(fees []UniTrxFee) error {
+	if len(fees) == 0 {
+		return nil
+	}
+	cols := strings.Split(uniTrxFeeInsertColumns, ", ")
+	sets := make([]string, 0, len(cols))
+	for _, col := range cols {
+		if col != "trx_hash" {
+			sets = append(sets, col+" = ?")
+		}
+	}
+	stmt, err := r.db.Prepare(r.dialect.rebind("UPDATE uni_trx_fee SET " + strings.Join(sets, ", ") +
+		", version = version + 1, gmt_modified = CURRENT_TIMESTAMP WHERE trx_hash = ?"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := range fees {
+		args := r.uniTrxFeeArgs(&fees[i])
+		// trx_hash moves from the second column to the where clause
+		args = append(append(args[:1:1], args[2:]...), fees[i].TrxHash)
+		if _, err = stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mysqlErrDupEntry is the code of duplicate key errors and warnings
+const mysqlErrDupEntry = 1062
+
+// loadSeq names the readers of concurrent bulk loads apart
+var loadSeq atomic.Uint64
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`)
+
+// loadUniTrxFee inserts fees with a single LOAD DATA LOCAL INFILE streaming
+// them as tab separated values, mysql only. It returns how many were inserted.
+// A local load turns errors into warnings, any of them but a duplicate key
+// fails the load.
+func (r *repository) loadUniTrxFee(fees []UniTrxFee) (int, error) {
+	var b bytes.Buffer
+	for i := range fees {
+		for j, v := range r.uniTrxFeeArgs(&fees[i]) {
+			if j > 0 {
+				b.WriteByte('\t')
+			}
+			tsvEscaper.WriteString(&b, fmt.Sprint(v))
+		}
+		b.WriteByte('\n')
+	}
+
+	name := fmt.Sprintf("uni_trx_fee_%d", loadSeq.Add(1))
+	mysql.RegisterReaderHandler(name, func() io.Reader { return &b })
+	defer mysql.DeregisterReaderHandler(name)
+
+	res, err := r.db.Exec("LOAD DATA LOCAL INFILE 'Reader::" + name + "' INTO TABLE uni_trx_fee " +
+		`FIELDS TERMINATED BY '\t' LINES TERMINATED BY '\n' (` + uniTrxFeeInsertColumns + ")")
+	if err != nil {
+		return 0, err
+	}
+
+	// warnings are per connection, the load runs in a transaction so this is
+	// the same one. Duplicate keys are fees stored concurrently, which the
+	// caller detects from the number of inserted fees.
+	if err = r.checkLoadWarnings(); err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// checkLoadWarnings fails with the first warning of the last statement that
+// isn't a duplicate key
+func (r *repository) checkLoadWarnings() error {
+	rows, err := r.db.Query("SHOW WARNINGS")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var level, message string
+		var code int
+		if err = rows.Scan(&level, &code, &message); err != nil {
+			return err
+		}
+		if code != mysqlErrDupEntry {
+			return fmt.Errorf("load fees: %s %d: %s", level, code, message)
+		}
+	}
+	return rows.Err()
+}
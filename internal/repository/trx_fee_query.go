@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+)
+
+// TrxFeeFilter selects fees of a symbol in a time range, the other criteria
+// are ignored when nil or empty. Ranges are inclusive.
+type TrxFeeFilter struct {
+	Symbol    string
+	StartTime int64
+	EndTime   int64
+
+	MinBlock    *uint64
+	MaxBlock    *uint64
+	MinFeeUsdt  *decimal.Decimal
+	MaxFeeUsdt  *decimal.Decimal
+	MinFeeEth   *decimal.Decimal
+	MaxFeeEth   *decimal.Decimal
+	MinGasPrice *util.Wei
+	MaxGasPrice *util.Wei
+	MinGasUsed  *uint64
+	MaxGasUsed  *uint64
+	FromAddress string
+	Status      string
+	PriceSource string
+}
+
+type TrxFeeSortField string
+
+const (
+	SortByTrxTime  TrxFeeSortField = "trx_time"
+	SortByBlockNum TrxFeeSortField = "block_num"
+	SortByGasUsed  TrxFeeSortField = "gas_used"
+	SortByGasPrice TrxFeeSortField = "gas_price"
+	SortByFeeEth   TrxFeeSortField = "trx_fee_eth"
+	SortByFeeUsdt  TrxFeeSortField = "trx_fee_usdt"
+)
+
+// trxFeeSortColumns whitelists the columns fees can be sorted by, it is the
+// only way a sort field reaches a query
+var trxFeeSortColumns = map[TrxFeeSortField]sortColumn{
+	SortByTrxTime:  {column: "trx_time"},
+	SortByBlockNum: {column: "block_num"},
+	SortByGasUsed:  {column: "gas_used"},
+	SortByGasPrice: {column: "gas_price", decimal: true},
+	SortByFeeEth:   {column: "trx_fee_eth", decimal: true},
+	SortByFeeUsdt:  {column: "trx_fee_usdt", decimal: true},
+}
+
+type sortColumn struct {
+	column string
+	// decimal columns are compared with dialect.decimal arguments
+	decimal bool
+}
+
+// arg converts a cursor value to the type of the column
+func (c sortColumn) arg(d dialect, v decimal.Decimal) interface{} {
+	if c.decimal {
+		return d.decimal(v)
+	}
+	return v.BigInt().Uint64()
+}
+
+func (f TrxFeeSortField) Valid() bool {
+	_, ok := trxFeeSortColumns[f]
+	return ok
+}
+
+// value returns the sort key of fee
+func (f TrxFeeSortField) value(fee *UniTrxFee) decimal.Decimal {
+	switch f {
+	case SortByBlockNum:
+		return decimal.NewFromUint64(fee.BlockNumber)
+	case SortByGasUsed:
+		return decimal.NewFromUint64(fee.GasUsed)
+	case SortByGasPrice:
+		return fee.GasPrice.Decimal()
+	case SortByFeeEth:
+		return fee.TrxFeeEth
+	case SortByFeeUsdt:
+		return fee.TrxFeeUsdt
+	default:
+		return decimal.NewFromUint64(fee.TrxTime)
+	}
+}
+
+// TrxFeeListQuery lists filtered fees ordered by the sort field then id. Pages
+// are read with a keyset: the next page starts after the last fee of the
+// previous one.
+type TrxFeeListQuery struct {
+	Filter TrxFeeFilter
+	// SortByTrxTime if empty
+	Sort TrxFeeSortField
+	Desc bool
+	// only fees strictly after this position in the requested order, from the start if nil
+	After *TrxFeeCursor
+	Limit int
+}
+
+func (q *TrxFeeListQuery) sortField() TrxFeeSortField {
+	if q.Sort == "" {
+		return SortByTrxTime
+	}
+	return q.Sort
+}
+
+// TrxFeeCursor is the (sort value, id) position of a fee in the listing order
+type TrxFeeCursor struct {
+	Value decimal.Decimal
+	ID    uint64
+}
+
+// NewTrxFeeCursor returns the position of fee in the order of q
+func (q *TrxFeeListQuery) NewTrxFeeCursor(fee *UniTrxFee) TrxFeeCursor {
+	return TrxFeeCursor{Value: q.sortField().value(fee), ID: fee.ID}
+}
+
+// queryBuilder joins conditions with their arguments. Values only ever go
+// through placeholders, column names and operators are constants of this package.
+type queryBuilder struct {
+	conds []string
+	args  []interface{}
+}
+
+func (b *queryBuilder) where(cond string, args ...interface{}) {
+	b.conds = append(b.conds, cond)
+	b.args = append(b.args, args...)
+}
+
+func (b *queryBuilder) String() string {
+	if len(b.conds) == 0 {
+		return "1 = 1"
+	}
+	return strings.Join(b.conds, " and ")
+}
+
+func (r *repository) trxFeeFilterQuery(f TrxFeeFilter) *queryBuilder {
+	b := &queryBuilder{}
+	b.where("symbol = ?", f.Symbol)
+	b.where("trx_time >= ?", f.StartTime)
+	b.where("trx_time <= ?", f.EndTime)
+
+	uintRange := func(col string, lo, hi *uint64) {
+		if lo != nil {
+			b.where(col+" >= ?", *lo)
+		}
+		if hi != nil {
+			b.where(col+" <= ?", *hi)
+		}
+	}
+	decimalRange := func(col string, lo, hi *decimal.Decimal) {
+		if lo != nil {
+			b.where(col+" >= ?", r.dialect.decimal(*lo))
+		}
+		if hi != nil {
+			b.where(col+" <= ?", r.dialect.decimal(*hi))
+		}
+	}
+	uintRange("block_num", f.MinBlock, f.MaxBlock)
+	uintRange("gas_used", f.MinGasUsed, f.MaxGasUsed)
+	decimalRange("trx_fee_usdt", f.MinFeeUsdt, f.MaxFeeUsdt)
+	decimalRange("trx_fee_eth", f.MinFeeEth, f.MaxFeeEth)
+	decimalRange("gas_price", weiDecimal(f.MinGasPrice), weiDecimal(f.MaxGasPrice))
+
+	if f.FromAddress != "" {
+		b.where("from_address = ?", strings.ToLower(f.FromAddress))
+	}
+	if f.Status != "" {
+		b.where("trx_status = ?", f.Status)
+	}
+	if f.PriceSource != "" {
+		b.where("price_source = ?", f.PriceSource)
+	}
+	return b
+}
+
+// match is the in-memory equivalent of trxFeeFilterQuery
+func (f *TrxFeeFilter) match(fee *UniTrxFee) bool {
+	inUintRange := func(v uint64, lo, hi *uint64) bool {
+		return (lo == nil || v >= *lo) && (hi == nil || v <= *hi)
+	}
+	inDecimalRange := func(v decimal.Decimal, lo, hi *decimal.Decimal) bool {
+		return (lo == nil || v.GreaterThanOrEqual(*lo)) && (hi == nil || v.LessThanOrEqual(*hi))
+	}
+
+	return fee.Symbol == f.Symbol &&
+		int64(fee.TrxTime) >= f.StartTime && int64(fee.TrxTime) <= f.EndTime &&
+		inUintRange(fee.BlockNumber, f.MinBlock, f.MaxBlock) &&
+		inUintRange(fee.GasUsed, f.MinGasUsed, f.MaxGasUsed) &&
+		inDecimalRange(fee.TrxFeeUsdt, f.MinFeeUsdt, f.MaxFeeUsdt) &&
+		inDecimalRange(fee.TrxFeeEth, f.MinFeeEth, f.MaxFeeEth) &&
+		inDecimalRange(fee.GasPrice.Decimal(), weiDecimal(f.MinGasPrice), weiDecimal(f.MaxGasPrice)) &&
+		(f.FromAddress == "" || fee.FromAddress == strings.ToLower(f.FromAddress)) &&
+		(f.Status == "" || fee.Status == f.Status) &&
+		(f.PriceSource == "" || fee.PriceSource == f.PriceSource)
+}
+
+func weiDecimal(w *util.Wei) *decimal.Decimal {
+	if w == nil {
+		return nil
+	}
+	d := w.Decimal()
+	return &d
+}
@@ -1,101 +1,201 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/jaime1129/fedex/internal/util"
 	"github.com/shopspring/decimal"
 )
 
 type Repository interface {
-	BatchInsertUniTrxFee(fees []UniTrxFee) error
+	TxRepository
+
+	// WithTx runs fn in a transaction, committed if fn returns nil and rolled
+	// back otherwise. fn must only use tx, calls to the Repository may block
+	// until the transaction ends.
+	WithTx(ctx context.Context, fn func(tx TxRepository) error) error
+	Close()
+}
+
+// TxRepository holds the reads and writes of a Repository, the one given by
+// WithTx runs them in its transaction
+type TxRepository interface {
+	BatchInsertUniTrxFee(fees []UniTrxFee, policy ConflictPolicy) (InsertReport, error)
 	GetMaxBlockNum(symbol string) (uint64, error)
-	BatchRecordHistoricalTrx(fees []UniTrxFee, symbol string, maxBlock uint64) error
+	RecordMaxBlockNum(symbol string, maxBlock uint64) error
 	GetTrxFee(txHash string) (*UniTrxFee, error)
-	ListTrxFee(symbol string, startTime int64, endTime int64, page int, limit int) ([]UniTrxFee, error)
-	Close()
+	GetTrxFeeByID(id uint64) (*UniTrxFee, error)
+	// ListTrxFeeByHash returns the stored fees among the trx hashes, in no particular order
+	ListTrxFeeByHash(txHashes []string) ([]UniTrxFee, error)
+	ListTrxFee(query TrxFeeListQuery) ([]UniTrxFee, error)
+	CountTrxFee(filter TrxFeeFilter) (int64, error)
+	GetTrxFeeStats(filter TrxFeeFilter) (*TrxFeeStats, error)
+	// GetGasPricePercentile returns the nearest-rank percentile p, between 1 and
+	// 100, of the gas prices of the fees matching filter
+	GetGasPricePercentile(filter TrxFeeFilter, p int) (*GasPricePercentile, error)
+	GetTrxFeeSeries(filter TrxFeeFilter, step int64) ([]TrxFeeBucket, error)
+
+	SaveTrxFeeRollup(rollup *TrxFeeRollup) error
+	ListTrxFeeRollups(period RollupPeriod, symbol string, start int64, end int64) ([]TrxFeeRollup, error)
+	ListRollupHoursByBlock(symbol string, fromBlock uint64, toBlock uint64) ([]int64, error)
+	// ListTrxFeeHoursModifiedAfter returns the starts of the hours in [start, end)
+	// holding fees of symbol written after the unix time after, in order
+	ListTrxFeeHoursModifiedAfter(symbol string, after int64, start int64, end int64) ([]int64, error)
+	GetJobWatermark(job string) (int64, error)
+	SetJobWatermark(job string, lastModified int64) error
+	// ListTrxFeeModifiedAfter lists fees in the order they were last written,
+	// by their UpdatedAt then id
+	ListTrxFeeModifiedAfter(after TrxFeeTimeCursor, filter StreamFilter, limit int) ([]UniTrxFee, error)
+	// ListTrxFeeCreatedAfter lists fees in the order they were inserted, by
+	// their CreatedAt then id
+	ListTrxFeeCreatedAfter(after TrxFeeTimeCursor, filter StreamFilter, limit int) ([]UniTrxFee, error)
+
+	InsertDeadLetter(batch *DeadLetterBatch) error
+	GetDeadLetter(id uint64) (*DeadLetterBatch, error)
+	ListDeadLetters(status string, page int, limit int) ([]DeadLetterBatch, error)
+	ListDueDeadLetters(now int64, limit int) ([]DeadLetterBatch, error)
+	UpdateDeadLetter(batch *DeadLetterBatch) error
 }
 
 type repository struct {
-	db *sql.DB
+	// the pool, nil within a transaction
+	pool *sql.DB
+	// the pool or the transaction statements run on
+	db      querier
+	dialect dialect
+	// batches of at least this many fees are bulk loaded, 0 never
+	bulkLoadThreshold int
 }
 
-func NewRepository(dsn string) Repository {
-	// Update the DSN as per your user, password, host, and database details
-	db, err := sql.Open("mysql", dsn)
+// Option configures a Repository opened by NewRepository
+type Option func(r *repository)
+
+// WithBulkLoadThreshold makes batch inserts of at least rows fees use LOAD
+// DATA LOCAL INFILE, which needs local_infile enabled on the server. It only
+// applies to mysql, 0 disables it.
+func WithBulkLoadThreshold(rows int) Option {
+	return func(r *repository) {
+		if r.dialect.driver == DriverMySQL {
+			r.bulkLoadThreshold = rows
+		}
+	}
+}
+
+// NewRepository opens a Repository backed by one of DriverMySQL, DriverPostgres or DriverSQLite
+func NewRepository(driver string, dsn string, opts ...Option) (Repository, error) {
+	d, err := newDialect(driver)
 	if err != nil {
-		log.Fatal("Error connecting to the database: ", err)
+		return nil, err
+	}
+	db, err := sql.Open(d.sqlDriverName(), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s database: %w", driver, err)
+	}
+	if driver == DriverSQLite {
+		// sqlite allows a single writer, serializing connections avoids SQLITE_BUSY errors
+		db.SetMaxOpenConns(1)
 	}
-	return &repository{db: db}
+	r := &repository{pool: db, db: db, dialect: d}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
 }
 
 func (r *repository) Close() {
-	r.db.Close()
+	r.pool.Close()
+}
+
+func (r *repository) WithTx(ctx context.Context, fn func(tx TxRepository) error) error {
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// a no-op once committed, and a rollback if fn fails or panics
+	defer tx.Rollback()
+
+	txRepo := *r
+	txRepo.pool = nil
+	txRepo.db = tx
+	if err = fn(&txRepo); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
+// UniTrxFee is the stored fee. The api doesn't serialize it, it serves the
+// models of apiv1 and apiv2 mapped from it.
 type UniTrxFee struct {
+	ID           uint64
 	Symbol       string
 	TrxHash      string
 	TrxTime      uint64
 	GasUsed      uint64
-	GasPrice     uint64
+	GasPrice     util.Wei
 	BlockNumber  uint64
+	FromAddress  string
 	EthUsdtPrice decimal.Decimal
+	TrxFeeWei    util.Wei
+	TrxFeeEth    decimal.Decimal
 	TrxFeeUsdt   decimal.Decimal
+	// success, failed or unknown
+	Status string
+	// binance kline interval the ETH price was averaged from, like binance_1m
+	PriceSource string
+	// incremented every time the fee is overwritten, starting at 1
+	Version uint64
+	// unix time of the insert
+	CreatedAt int64
+	// unix time of the last write
+	UpdatedAt int64
 }
 
-func (r *repository) BatchInsertUniTrxFee(fees []UniTrxFee) error {
-	var placeholders []string
-	var args []interface{}
-
-	for _, fee := range fees {
-		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?)")
-		args = append(args, fee.Symbol, fee.TrxHash, fee.TrxTime, fee.GasUsed, fee.GasPrice, fee.EthUsdtPrice.String(), fee.TrxFeeUsdt.String(), fee.BlockNumber)
-	}
+const (
+	TrxStatusSuccess = "success"
+	TrxStatusFailed  = "failed"
+	TrxStatusUnknown = "unknown"
+)
 
-	// use ignore to avoid dup key conflict error
-	stmt := fmt.Sprintf("INSERT IGNORE INTO uni_trx_fee (symbol, trx_hash, trx_time, gas_used, gas_price, eth_usdt_price, trx_fee_usdt, block_num) VALUES %s",
-		strings.Join(placeholders, ", "))
+const PriceSourceUnknown = "unknown"
 
-	_, err := r.db.Exec(stmt, args...)
-	if err != nil {
-		return err
-	}
-	return nil
+// SetPrice derives the fee in Wei, ETH and USDT from gas used, gas price and the given ETH price
+func (f *UniTrxFee) SetPrice(ethUsdtPrice decimal.Decimal, source string) {
+	f.EthUsdtPrice = ethUsdtPrice
+	f.PriceSource = source
+	f.TrxFeeWei = util.CalculateFeeInWei(f.GasUsed, f.GasPrice)
+	f.TrxFeeEth = f.TrxFeeWei.Ether()
+	f.TrxFeeUsdt = f.TrxFeeEth.Mul(ethUsdtPrice)
 }
 
-// batch insert historical trxs and record the maximum block number
-func (r *repository) BatchRecordHistoricalTrx(fees []UniTrxFee, symbol string, maxBlock uint64) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	err = r.BatchInsertUniTrxFee(fees)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+func (r *repository) uniTrxFeeColumns() string {
+	return "id, symbol, trx_hash, trx_time, gas_used, gas_price, eth_usdt_price, trx_fee_wei, trx_fee_eth, trx_fee_usdt, block_num, from_address, trx_status, price_source, version, " +
+		r.dialect.unixTimestamp("gmt_created") + ", " + r.dialect.unixTimestamp("gmt_modified")
+}
 
-	_, err = r.db.Exec("INSERT INTO block_num_record (symbol, max_block) VALUES (?,?) ON DUPLICATE KEY UPDATE max_block=?", symbol, maxBlock, maxBlock)
+func scanUniTrxFee(row rowScanner) (*UniTrxFee, error) {
+	var fee UniTrxFee
+	err := row.Scan(&fee.ID, &fee.Symbol, &fee.TrxHash, &fee.TrxTime, &fee.GasUsed, &fee.GasPrice, &fee.EthUsdtPrice,
+		&fee.TrxFeeWei, &fee.TrxFeeEth, &fee.TrxFeeUsdt, &fee.BlockNumber, &fee.FromAddress, &fee.Status, &fee.PriceSource,
+		&fee.Version, &fee.CreatedAt, &fee.UpdatedAt)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, err
 	}
+	return &fee, nil
+}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-	return nil
+// RecordMaxBlockNum records the block the historical trxs of symbol are stored up to
+func (r *repository) RecordMaxBlockNum(symbol string, maxBlock uint64) error {
+	stmt := r.dialect.upsert("block_num_record (symbol, max_block) VALUES (?, ?)", "symbol", "max_block")
+	_, err := r.db.Exec(r.dialect.rebind(stmt), symbol, maxBlock)
+	return err
 }
 
 func (r *repository) GetMaxBlockNum(symbol string) (uint64, error) {
 	var blockNum uint64
-	err := r.db.QueryRow("SELECT max_block FROM block_num_record WHERE symbol = ?", symbol).Scan(&blockNum)
+	err := r.db.QueryRow(r.dialect.rebind("SELECT max_block FROM block_num_record WHERE symbol = ?"), symbol).Scan(&blockNum)
 	if err == sql.ErrNoRows {
 		return 0, nil
 	}
@@ -107,8 +207,8 @@ func (r *repository) GetMaxBlockNum(symbol string) (uint64, error) {
 }
 
 func (r *repository) GetTrxFee(txHash string) (*UniTrxFee, error) {
-	query := "SELECT symbol, trx_hash, trx_time, gas_used, gas_price, eth_usdt_price, trx_fee_usdt, block_num FROM uni_trx_fee where trx_hash=?"
-	rows, err := r.db.Query(query, txHash)
+	query := "SELECT " + r.uniTrxFeeColumns() + " FROM uni_trx_fee where trx_hash=?"
+	rows, err := r.db.Query(r.dialect.rebind(query), txHash)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -117,29 +217,125 @@ func (r *repository) GetTrxFee(txHash string) (*UniTrxFee, error) {
 	}
 	defer rows.Close()
 
-	var fee UniTrxFee
-	if rows.Next() {
-		err = rows.Scan(&fee.Symbol, &fee.TrxHash, &fee.TrxTime, &fee.GasUsed, &fee.GasPrice, &fee.EthUsdtPrice, &fee.TrxFeeUsdt, &fee.BlockNumber)
+	if !rows.Next() {
+		return nil, nil
+	}
+	return scanUniTrxFee(rows)
+}
+
+func (r *repository) GetTrxFeeByID(id uint64) (*UniTrxFee, error) {
+	fees, err := r.queryUniTrxFees("SELECT "+r.uniTrxFeeColumns()+" FROM uni_trx_fee where id = ?", id)
+	if err != nil || len(fees) == 0 {
+		return nil, err
+	}
+	return &fees[0], nil
+}
+
+func (r *repository) ListTrxFeeByHash(txHashes []string) ([]UniTrxFee, error) {
+	var fees []UniTrxFee
+	for start := 0; start < len(txHashes); start += insertChunkSize {
+		chunk := txHashes[start:min(start+insertChunkSize, len(txHashes))]
+		placeholders := make([]string, len(chunk))
+		args := make([]interface{}, len(chunk))
+		for i := range chunk {
+			placeholders[i] = "?"
+			args[i] = chunk[i]
+		}
+		query := "SELECT " + r.uniTrxFeeColumns() + " FROM uni_trx_fee where trx_hash in (" + strings.Join(placeholders, ", ") + ")"
+		chunkFees, err := r.queryUniTrxFees(query, args...)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		return nil, nil
+		fees = append(fees, chunkFees...)
 	}
+	return fees, nil
+}
 
-	return &fee, nil
+func (r *repository) ListTrxFee(q TrxFeeListQuery) ([]UniTrxFee, error) {
+	b := r.trxFeeFilterQuery(q.Filter)
+	sort := trxFeeSortColumns[q.sortField()]
+	col := sort.column
+	order, cmp := "ASC", ">"
+	if q.Desc {
+		order, cmp = "DESC", "<"
+	}
+	if q.After != nil {
+		// expanded instead of a row comparison, which mysql doesn't use indexes for
+		value := sort.arg(r.dialect, q.After.Value)
+		b.where("("+col+" "+cmp+" ? or ("+col+" = ? and id "+cmp+" ?))",
+			value, value, q.After.ID)
+	}
+	query := "SELECT " + r.uniTrxFeeColumns() + " FROM uni_trx_fee where " + b.String() +
+		" order by " + col + " " + order + ", id " + order + " limit ?"
+	return r.queryUniTrxFees(query, append(b.args, q.Limit)...)
+}
+
+// CountTrxFee counts every fee matching the filter
+func (r *repository) CountTrxFee(filter TrxFeeFilter) (int64, error) {
+	b := r.trxFeeFilterQuery(filter)
+	var count int64
+	err := r.db.QueryRow(r.dialect.rebind("SELECT COUNT(*) FROM uni_trx_fee where "+b.String()), b.args...).Scan(&count)
+	return count, err
+}
+
+// StreamFilter narrows the fees returned by ListTrxFeeModifiedAfter and
+// ListTrxFeeCreatedAfter, zero values match everything
+type StreamFilter struct {
+	Symbol      string
+	MinFeeUsdt  decimal.Decimal
+	FromAddress string
+}
+
+// match is the in-memory equivalent of the where clause of listTrxFeeWrittenAfter
+func (f *StreamFilter) match(fee *UniTrxFee) bool {
+	return (f.Symbol == "" || fee.Symbol == f.Symbol) &&
+		(!f.MinFeeUsdt.IsPositive() || fee.TrxFeeUsdt.GreaterThanOrEqual(f.MinFeeUsdt)) &&
+		(f.FromAddress == "" || fee.FromAddress == strings.ToLower(f.FromAddress))
+}
+
+// TrxFeeTimeCursor is the position of a fee among fees ordered by a write
+// time, then id
+type TrxFeeTimeCursor struct {
+	// unix time
+	Time int64
+	ID   uint64
+}
+
+func (r *repository) ListTrxFeeModifiedAfter(after TrxFeeTimeCursor, filter StreamFilter, limit int) ([]UniTrxFee, error) {
+	return r.listTrxFeeWrittenAfter("gmt_modified", after, filter, limit)
 }
 
-func (r *repository) ListTrxFee(symbol string, startTime int64, endTime int64, page int, limit int) ([]UniTrxFee, error) {
-	if limit == 0 || limit > 50 {
-		limit = 20
+func (r *repository) ListTrxFeeCreatedAfter(after TrxFeeTimeCursor, filter StreamFilter, limit int) ([]UniTrxFee, error) {
+	return r.listTrxFeeWrittenAfter("gmt_created", after, filter, limit)
+}
+
+// listTrxFeeWrittenAfter lists fees ordered by the timestamp column col, then id
+func (r *repository) listTrxFeeWrittenAfter(col string, after TrxFeeTimeCursor, filter StreamFilter, limit int) ([]UniTrxFee, error) {
+	// expanded instead of a row comparison, which mysql doesn't use indexes for
+	at := r.dialect.fromUnixTime("?")
+	query := "SELECT " + r.uniTrxFeeColumns() + " FROM uni_trx_fee where (" + col + " > " + at +
+		" or (" + col + " = " + at + " and id > ?))"
+	args := []interface{}{after.Time, after.Time, after.ID}
+	if filter.Symbol != "" {
+		query += " and symbol = ?"
+		args = append(args, filter.Symbol)
 	}
-	query := "SELECT symbol, trx_hash, trx_time, gas_used, gas_price, eth_usdt_price, trx_fee_usdt, block_num FROM uni_trx_fee where " +
-		"symbol=? and trx_time >= ? and trx_time <= ? limit ? offset ?"
-	rows, err := r.db.Query(query, symbol, startTime, endTime, limit, page*limit)
-	if err == sql.ErrNoRows {
-		return []UniTrxFee{}, nil
+	if filter.MinFeeUsdt.IsPositive() {
+		query += " and trx_fee_usdt >= ?"
+		args = append(args, r.dialect.decimal(filter.MinFeeUsdt))
 	}
+	if filter.FromAddress != "" {
+		query += " and from_address = ?"
+		args = append(args, strings.ToLower(filter.FromAddress))
+	}
+	query += " order by " + col + ", id limit ?"
+	args = append(args, limit)
+
+	return r.queryUniTrxFees(query, args...)
+}
+
+func (r *repository) queryUniTrxFees(query string, args ...interface{}) ([]UniTrxFee, error) {
+	rows, err := r.db.Query(r.dialect.rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -147,15 +343,21 @@ func (r *repository) ListTrxFee(symbol string, startTime int64, endTime int64, p
 
 	var fees []UniTrxFee
 	for rows.Next() {
-		var fee UniTrxFee
-		err := rows.Scan(&fee.Symbol, &fee.TrxHash, &fee.TrxTime, &fee.GasUsed, &fee.GasPrice, &fee.EthUsdtPrice, &fee.TrxFeeUsdt, &fee.BlockNumber)
+		fee, err := scanUniTrxFee(rows)
 		if err != nil {
 			return nil, err
 		}
-		fees = append(fees, fee)
+		fees = append(fees, *fee)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 	return fees, nil
 }
+
+func orDefault(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
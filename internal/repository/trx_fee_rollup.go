@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jaime1129/fedex/internal/util"
+)
+
+// RollupPeriod is the length in seconds of the buckets of a rollup table,
+// buckets are aligned on unix time
+type RollupPeriod int64
+
+const (
+	RollupHour RollupPeriod = 3600
+	RollupDay  RollupPeriod = 86400
+)
+
+func (p RollupPeriod) table() string {
+	if p == RollupDay {
+		return "trx_fee_rollup_day"
+	}
+	return "trx_fee_rollup_hour"
+}
+
+// Truncate returns the start of the bucket containing t
+func (p RollupPeriod) Truncate(t int64) int64 {
+	return t - t%int64(p)
+}
+
+// TrxFeeRollup aggregates the fees of a symbol in [Start, Start+Period)
+type TrxFeeRollup struct {
+	Symbol string
+	Period RollupPeriod
+	Start  int64
+	TrxFeeAggregate
+	MinBlock   uint64
+	MaxBlock   uint64
+	SketchUsdt util.Sketch
+	SketchEth  util.Sketch
+}
+
+// Add accumulates fee into r
+func (r *TrxFeeRollup) Add(fee *UniTrxFee) {
+	if r.Count == 0 || fee.BlockNumber < r.MinBlock {
+		r.MinBlock = fee.BlockNumber
+	}
+	if r.Count == 0 || fee.BlockNumber > r.MaxBlock {
+		r.MaxBlock = fee.BlockNumber
+	}
+	r.TrxFeeAggregate.add(fee)
+	r.SketchUsdt.Add(fee.TrxFeeUsdt)
+	r.SketchEth.Add(fee.TrxFeeEth)
+}
+
+// Merge accumulates the rollup of a disjoint set of fees into r
+func (r *TrxFeeRollup) Merge(o *TrxFeeRollup) {
+	if o.Count == 0 {
+		return
+	}
+	if r.Count == 0 || o.MinBlock < r.MinBlock {
+		r.MinBlock = o.MinBlock
+	}
+	if r.Count == 0 || o.MaxBlock > r.MaxBlock {
+		r.MaxBlock = o.MaxBlock
+	}
+	r.TrxFeeAggregate.Merge(o.TrxFeeAggregate)
+	r.SketchUsdt.Merge(&o.SketchUsdt)
+	r.SketchEth.Merge(&o.SketchEth)
+}
+
+const trxFeeRollupColumns = "symbol, bucket_start, trx_count, sum_fee_usdt, min_fee_usdt, max_fee_usdt, sum_fee_eth, min_fee_eth, max_fee_eth, sum_gas_price, sum_gas_used, min_block, max_block, sketch_usdt, sketch_eth"
+
+func scanTrxFeeRollup(row rowScanner, period RollupPeriod) (*TrxFeeRollup, error) {
+	r := TrxFeeRollup{Period: period}
+	var sketchUsdt, sketchEth string
+	err := row.Scan(&r.Symbol, &r.Start, &r.Count, &r.SumFeeUsdt, &r.MinFeeUsdt, &r.MaxFeeUsdt, &r.SumFeeEth, &r.MinFeeEth, &r.MaxFeeEth,
+		&r.SumGasPrice, &r.SumGasUsed, &r.MinBlock, &r.MaxBlock, &sketchUsdt, &sketchEth)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(sketchUsdt), &r.SketchUsdt); err != nil {
+		return nil, fmt.Errorf("decode usdt sketch of %s rollup at %d: %w", r.Symbol, r.Start, err)
+	}
+	if err = json.Unmarshal([]byte(sketchEth), &r.SketchEth); err != nil {
+		return nil, fmt.Errorf("decode eth sketch of %s rollup at %d: %w", r.Symbol, r.Start, err)
+	}
+	return &r, nil
+}
+
+// SaveTrxFeeRollup replaces the stored rollup of the same symbol, period and
+// start. A rollup of no fees is deleted.
+func (r *repository) SaveTrxFeeRollup(rollup *TrxFeeRollup) error {
+	if rollup.Count == 0 {
+		_, err := r.db.Exec(r.dialect.rebind("DELETE FROM "+rollup.Period.table()+" WHERE symbol = ? and bucket_start = ?"),
+			rollup.Symbol, rollup.Start)
+		return err
+	}
+
+	sketchUsdt, err := json.Marshal(&rollup.SketchUsdt)
+	if err != nil {
+		return err
+	}
+	sketchEth, err := json.Marshal(&rollup.SketchEth)
+	if err != nil {
+		return err
+	}
+	columns := strings.Split(trxFeeRollupColumns, ", ")
+	stmt := r.dialect.upsert(rollup.Period.table()+" ("+trxFeeRollupColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		"symbol, bucket_start", columns[2:]...)
+	_, err = r.db.Exec(r.dialect.rebind(stmt), rollup.Symbol, rollup.Start, rollup.Count,
+		rollup.SumFeeUsdt.String(), rollup.MinFeeUsdt.String(), rollup.MaxFeeUsdt.String(),
+		rollup.SumFeeEth.String(), rollup.MinFeeEth.String(), rollup.MaxFeeEth.String(),
+		rollup.SumGasPrice.String(), rollup.SumGasUsed.String(), rollup.MinBlock, rollup.MaxBlock,
+		string(sketchUsdt), string(sketchEth))
+	return err
+}
+
+// ListTrxFeeRollups lists the rollups of symbol starting in [start, end), ordered by start
+func (r *repository) ListTrxFeeRollups(period RollupPeriod, symbol string, start int64, end int64) ([]TrxFeeRollup, error) {
+	query := "SELECT " + trxFeeRollupColumns + " FROM " + period.table() +
+		" where symbol = ? and bucket_start >= ? and bucket_start < ? order by bucket_start"
+	rows, err := r.db.Query(r.dialect.rebind(query), symbol, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []TrxFeeRollup
+	for rows.Next() {
+		rollup, err := scanTrxFeeRollup(rows, period)
+		if err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, *rollup)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return rollups, nil
+}
+
+// ListRollupHoursByBlock returns the starts of the hourly rollups of symbol
+// holding fees of blocks in [fromBlock, toBlock]
+func (r *repository) ListRollupHoursByBlock(symbol string, fromBlock uint64, toBlock uint64) ([]int64, error) {
+	query := "SELECT bucket_start FROM " + RollupHour.table() +
+		" where symbol = ? and max_block >= ? and min_block <= ? order by bucket_start"
+	rows, err := r.db.Query(r.dialect.rebind(query), symbol, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var starts []int64
+	for rows.Next() {
+		var start int64
+		if err = rows.Scan(&start); err != nil {
+			return nil, err
+		}
+		starts = append(starts, start)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return starts, nil
+}
+
+// ListTrxFeeHoursModifiedAfter returns the starts of the hours in [start, end)
+// holding fees of symbol last written after the unix time after
+func (r *repository) ListTrxFeeHoursModifiedAfter(symbol string, after int64, start int64, end int64) ([]int64, error) {
+	hour := fmt.Sprintf("trx_time - trx_time %% %d", int64(RollupHour))
+	query := "SELECT DISTINCT " + hour + " AS hour_start FROM uni_trx_fee where gmt_modified > " + r.dialect.fromUnixTime("?") +
+		" and symbol = ? and trx_time >= ? and trx_time < ? order by hour_start"
+	rows, err := r.db.Query(r.dialect.rebind(query), after, symbol, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var starts []int64
+	for rows.Next() {
+		var start int64
+		if err = rows.Scan(&start); err != nil {
+			return nil, err
+		}
+		starts = append(starts, start)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return starts, nil
+}
+
+// GetJobWatermark returns the unix time of the last fee write processed by
+// job, 0 if it never ran
+func (r *repository) GetJobWatermark(job string) (int64, error) {
+	var lastModified int64
+	err := r.db.QueryRow(r.dialect.rebind("SELECT last_modified FROM job_watermark WHERE job = ?"), job).Scan(&lastModified)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return lastModified, err
+}
+
+func (r *repository) SetJobWatermark(job string, lastModified int64) error {
+	stmt := r.dialect.upsert("job_watermark (job, last_modified) VALUES (?, ?)", "job", "last_modified")
+	_, err := r.db.Exec(r.dialect.rebind(stmt), job, lastModified)
+	return err
+}
+
+type rollupKey struct {
+	period RollupPeriod
+	symbol string
+	start  int64
+}
+
+func (r *memoryRepository) SaveTrxFeeRollup(rollup *TrxFeeRollup) error {
+	r.lock()
+	defer r.unlock()
+	key := rollupKey{period: rollup.Period, symbol: rollup.Symbol, start: rollup.Start}
+	if rollup.Count == 0 {
+		delete(r.rollups, key)
+		return nil
+	}
+	// sketches are copied by merging them into empty ones
+	stored := TrxFeeRollup{Symbol: rollup.Symbol, Period: rollup.Period, Start: rollup.Start}
+	stored.Merge(rollup)
+	r.rollups[key] = stored
+	return nil
+}
+
+func (r *memoryRepository) ListTrxFeeRollups(period RollupPeriod, symbol string, start int64, end int64) ([]TrxFeeRollup, error) {
+	return r.filterRollups(period, symbol, func(rollup *TrxFeeRollup) bool {
+		return rollup.Start >= start && rollup.Start < end
+	}), nil
+}
+
+func (r *memoryRepository) ListRollupHoursByBlock(symbol string, fromBlock uint64, toBlock uint64) ([]int64, error) {
+	var starts []int64
+	for _, rollup := range r.filterRollups(RollupHour, symbol, func(rollup *TrxFeeRollup) bool {
+		return rollup.MaxBlock >= fromBlock && rollup.MinBlock <= toBlock
+	}) {
+		starts = append(starts, rollup.Start)
+	}
+	return starts, nil
+}
+
+func (r *memoryRepository) ListTrxFeeHoursModifiedAfter(symbol string, after int64, start int64, end int64) ([]int64, error) {
+	seen := make(map[int64]struct{})
+	var starts []int64
+	for _, fee := range r.filterUniTrxFees(0, -1, func(fee *UniTrxFee) bool {
+		return fee.Symbol == symbol && fee.UpdatedAt > after && int64(fee.TrxTime) >= start && int64(fee.TrxTime) < end
+	}) {
+		h := RollupHour.Truncate(int64(fee.TrxTime))
+		if _, ok := seen[h]; !ok {
+			seen[h] = struct{}{}
+			starts = append(starts, h)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	return starts, nil
+}
+
+// filterRollups returns copies of the matching rollups ordered by start
+func (r *memoryRepository) filterRollups(period RollupPeriod, symbol string, match func(rollup *TrxFeeRollup) bool) []TrxFeeRollup {
+	r.rlock()
+	defer r.runlock()
+
+	var res []TrxFeeRollup
+	for key, rollup := range r.rollups {
+		if key.period != period || key.symbol != symbol || !match(&rollup) {
+			continue
+		}
+		c := TrxFeeRollup{Symbol: rollup.Symbol, Period: rollup.Period, Start: rollup.Start}
+		c.Merge(&rollup)
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Start < res[j].Start })
+	return res
+}
+
+func (r *memoryRepository) GetJobWatermark(job string) (int64, error) {
+	r.rlock()
+	defer r.runlock()
+	return r.watermarks[job], nil
+}
+
+func (r *memoryRepository) SetJobWatermark(job string, lastModified int64) error {
+	r.lock()
+	defer r.unlock()
+	r.watermarks[job] = lastModified
+	return nil
+}
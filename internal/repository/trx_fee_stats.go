@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+)
+
+// TrxFeeAggregate sums up a set of fees, it is all zero for an empty set
+type TrxFeeAggregate struct {
+	Count      int64
+	SumFeeUsdt decimal.Decimal
+	MinFeeUsdt decimal.Decimal
+	MaxFeeUsdt decimal.Decimal
+	SumFeeEth  decimal.Decimal
+	MinFeeEth  decimal.Decimal
+	MaxFeeEth  decimal.Decimal
+	// in wei
+	SumGasPrice decimal.Decimal
+	SumGasUsed  decimal.Decimal
+}
+
+// add accumulates fee into a
+func (a *TrxFeeAggregate) add(fee *UniTrxFee) {
+	if a.Count == 0 || fee.TrxFeeUsdt.LessThan(a.MinFeeUsdt) {
+		a.MinFeeUsdt = fee.TrxFeeUsdt
+	}
+	if a.Count == 0 || fee.TrxFeeUsdt.GreaterThan(a.MaxFeeUsdt) {
+		a.MaxFeeUsdt = fee.TrxFeeUsdt
+	}
+	if a.Count == 0 || fee.TrxFeeEth.LessThan(a.MinFeeEth) {
+		a.MinFeeEth = fee.TrxFeeEth
+	}
+	if a.Count == 0 || fee.TrxFeeEth.GreaterThan(a.MaxFeeEth) {
+		a.MaxFeeEth = fee.TrxFeeEth
+	}
+	a.Count++
+	a.SumFeeUsdt = a.SumFeeUsdt.Add(fee.TrxFeeUsdt)
+	a.SumFeeEth = a.SumFeeEth.Add(fee.TrxFeeEth)
+	a.SumGasPrice = a.SumGasPrice.Add(fee.GasPrice.Decimal())
+	a.SumGasUsed = a.SumGasUsed.Add(decimal.NewFromUint64(fee.GasUsed))
+}
+
+// Merge accumulates the aggregate of a disjoint set of fees into a
+func (a *TrxFeeAggregate) Merge(b TrxFeeAggregate) {
+	if b.Count == 0 {
+		return
+	}
+	if a.Count == 0 || b.MinFeeUsdt.LessThan(a.MinFeeUsdt) {
+		a.MinFeeUsdt = b.MinFeeUsdt
+	}
+	if a.Count == 0 || b.MaxFeeUsdt.GreaterThan(a.MaxFeeUsdt) {
+		a.MaxFeeUsdt = b.MaxFeeUsdt
+	}
+	if a.Count == 0 || b.MinFeeEth.LessThan(a.MinFeeEth) {
+		a.MinFeeEth = b.MinFeeEth
+	}
+	if a.Count == 0 || b.MaxFeeEth.GreaterThan(a.MaxFeeEth) {
+		a.MaxFeeEth = b.MaxFeeEth
+	}
+	a.Count += b.Count
+	a.SumFeeUsdt = a.SumFeeUsdt.Add(b.SumFeeUsdt)
+	a.SumFeeEth = a.SumFeeEth.Add(b.SumFeeEth)
+	a.SumGasPrice = a.SumGasPrice.Add(b.SumGasPrice)
+	a.SumGasUsed = a.SumGasUsed.Add(b.SumGasUsed)
+}
+
+// TrxFeeBucket aggregates the fees with a trx time in [Start, Start+step)
+type TrxFeeBucket struct {
+	Start int64
+	TrxFeeAggregate
+}
+
+// TrxFeePercentiles are nearest-rank percentiles: the smallest fee that is
+// greater than or equal to the given percentage of the fees
+type TrxFeePercentiles struct {
+	Median decimal.Decimal
+	P90    decimal.Decimal
+	P99    decimal.Decimal
+}
+
+// TrxFeeStats describes the distribution of the fees matching a filter
+type TrxFeeStats struct {
+	TrxFeeAggregate
+	FeeUsdt TrxFeePercentiles
+	FeeEth  TrxFeePercentiles
+}
+
+// percentileIndex returns the index of the nearest-rank percentile p in n sorted values
+func percentileIndex(p int64, n int64) int64 {
+	return (p*n+99)/100 - 1
+}
+
+// percentiles picks the percentiles of sorted values
+func percentiles(sorted []decimal.Decimal) TrxFeePercentiles {
+	n := int64(len(sorted))
+	if n == 0 {
+		return TrxFeePercentiles{}
+	}
+	return TrxFeePercentiles{
+		Median: sorted[percentileIndex(50, n)],
+		P90:    sorted[percentileIndex(90, n)],
+		P99:    sorted[percentileIndex(99, n)],
+	}
+}
+
+// trxFeeStats computes the stats of fees in Go, for the memory repository and
+// the databases that can't sum decimals
+func trxFeeStats(fees []UniTrxFee) *TrxFeeStats {
+	var stats TrxFeeStats
+	usdt := make([]decimal.Decimal, len(fees))
+	eth := make([]decimal.Decimal, len(fees))
+	for i := range fees {
+		stats.add(&fees[i])
+		usdt[i], eth[i] = fees[i].TrxFeeUsdt, fees[i].TrxFeeEth
+	}
+	for _, values := range [][]decimal.Decimal{usdt, eth} {
+		sort.Slice(values, func(i, j int) bool { return values[i].LessThan(values[j]) })
+	}
+	stats.FeeUsdt, stats.FeeEth = percentiles(usdt), percentiles(eth)
+	return &stats
+}
+
+// GetTrxFeeStats reads the fees once, in a single statement so that the
+// aggregates and percentiles are those of the same snapshot
+func (r *repository) GetTrxFeeStats(filter TrxFeeFilter) (*TrxFeeStats, error) {
+	b := r.trxFeeFilterQuery(filter)
+	if !r.dialect.sumsDecimals() {
+		fees, err := r.queryUniTrxFees("SELECT "+r.uniTrxFeeColumns()+" FROM uni_trx_fee where "+b.String(), b.args...)
+		if err != nil {
+			return nil, err
+		}
+		return trxFeeStats(fees), nil
+	}
+
+	// the fees are ranked in both fee orders, the nearest-rank percentile p of
+	// n fees is the smallest fee with a rank r such that r * 100 >= p * n
+	var percentileColumns []string
+	for _, fee := range []string{"usdt", "eth"} {
+		for _, p := range []int{50, 90, 99} {
+			percentileColumns = append(percentileColumns,
+				fmt.Sprintf("MIN(CASE WHEN %s_rank * 100 >= %d * n THEN trx_fee_%s END)", fee, p, fee))
+		}
+	}
+	query := "SELECT " + strings.Join(percentileColumns, ", ") + ", " + r.aggregateColumns() +
+		" FROM (SELECT trx_fee_usdt, trx_fee_eth, gas_price, gas_used, " +
+		"ROW_NUMBER() OVER (ORDER BY trx_fee_usdt) AS usdt_rank, ROW_NUMBER() OVER (ORDER BY trx_fee_eth) AS eth_rank, " +
+		"COUNT(*) OVER () AS n FROM uni_trx_fee where " + b.String() + ") ranked"
+
+	var stats TrxFeeStats
+	// percentiles of no rows are NULL
+	var usdt, eth [3]decimal.NullDecimal
+	err := scanTrxFeeAggregate(r.db.QueryRow(r.dialect.rebind(query), b.args...), &stats.TrxFeeAggregate,
+		&usdt[0], &usdt[1], &usdt[2], &eth[0], &eth[1], &eth[2])
+	if err != nil {
+		return nil, err
+	}
+	stats.FeeUsdt = TrxFeePercentiles{Median: usdt[0].Decimal, P90: usdt[1].Decimal, P99: usdt[2].Decimal}
+	stats.FeeEth = TrxFeePercentiles{Median: eth[0].Decimal, P90: eth[1].Decimal, P99: eth[2].Decimal}
+	return &stats, nil
+}
+
+// GasPricePercentile is a nearest-rank percentile of the gas prices of a set
+// of fees
+type GasPricePercentile struct {
+	// in wei, zero for no fees
+	GasPrice util.Wei
+	Count    int64
+}
+
+// GetGasPricePercentile ranks the gas prices of the fees matching filter in
+// the database like GetTrxFeeStats ranks their fees, which sqlite can do too
+// as its decimals are stored in value order
+func (r *repository) GetGasPricePercentile(filter TrxFeeFilter, p int) (*GasPricePercentile, error) {
+	b := r.trxFeeFilterQuery(filter)
+	query := fmt.Sprintf("SELECT MIN(CASE WHEN price_rank * 100 >= %d * n THEN gas_price END), MAX(n)", p) +
+		" FROM (SELECT gas_price, ROW_NUMBER() OVER (ORDER BY gas_price) AS price_rank, COUNT(*) OVER () AS n" +
+		" FROM uni_trx_fee where " + b.String() + ") ranked"
+
+	var res GasPricePercentile
+	// both are NULL for no rows
+	var count sql.NullInt64
+	if err := r.db.QueryRow(r.dialect.rebind(query), b.args...).Scan(&res.GasPrice, &count); err != nil {
+		return nil, err
+	}
+	res.Count = count.Int64
+	return &res, nil
+}
+
+func (r *memoryRepository) GetGasPricePercentile(filter TrxFeeFilter, p int) (*GasPricePercentile, error) {
+	fees := r.filterUniTrxFees(0, -1, filter.match)
+	if len(fees) == 0 {
+		return &GasPricePercentile{}, nil
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i].GasPrice.Cmp(fees[j].GasPrice) < 0 })
+	n := int64(len(fees))
+	return &GasPricePercentile{GasPrice: fees[percentileIndex(int64(p), n)].GasPrice, Count: n}, nil
+}
+
+// GetTrxFeeSeries aggregates the fees matching filter in buckets of step
+// seconds aligned on unix time, ordered by start. Empty buckets are omitted.
+func (r *repository) GetTrxFeeSeries(filter TrxFeeFilter, step int64) ([]TrxFeeBucket, error) {
+	b := r.trxFeeFilterQuery(filter)
+	if !r.dialect.sumsDecimals() {
+		fees, err := r.queryUniTrxFees("SELECT "+r.uniTrxFeeColumns()+" FROM uni_trx_fee where "+b.String(), b.args...)
+		if err != nil {
+			return nil, err
+		}
+		return bucketTrxFees(fees, step), nil
+	}
+	// step is a number, formatting it keeps the bucket expression free of placeholders so it can be grouped by
+	bucket := "trx_time - trx_time % " + strconv.FormatInt(step, 10)
+	query := "SELECT " + bucket + " AS bucket, " + r.aggregateColumns() + " FROM uni_trx_fee where " + b.String() +
+		" group by bucket order by bucket"
+	rows, err := r.db.Query(r.dialect.rebind(query), b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []TrxFeeBucket
+	for rows.Next() {
+		var bucket TrxFeeBucket
+		if err = scanTrxFeeAggregate(rows, &bucket.TrxFeeAggregate, &bucket.Start); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// aggregateColumns selects the fields of TrxFeeAggregate, in the order scanTrxFeeAggregate reads them
+func (r *repository) aggregateColumns() string {
+	return "COUNT(*), SUM(trx_fee_usdt), MIN(trx_fee_usdt), MAX(trx_fee_usdt), " +
+		"SUM(trx_fee_eth), MIN(trx_fee_eth), MAX(trx_fee_eth), SUM(gas_price), SUM(gas_used)"
+}
+
+// scanTrxFeeAggregate reads the aggregateColumns into a, after the columns
+// selected before them into leading
+func scanTrxFeeAggregate(row rowScanner, a *TrxFeeAggregate, leading ...interface{}) error {
+	// aggregates of no rows are NULL
+	var sumUsdt, minUsdt, maxUsdt, sumEth, minEth, maxEth, sumGasPrice, sumGasUsed decimal.NullDecimal
+	err := row.Scan(append(leading, &a.Count, &sumUsdt, &minUsdt, &maxUsdt, &sumEth, &minEth, &maxEth, &sumGasPrice, &sumGasUsed)...)
+	if err != nil {
+		return err
+	}
+	a.SumFeeUsdt, a.MinFeeUsdt, a.MaxFeeUsdt = sumUsdt.Decimal, minUsdt.Decimal, maxUsdt.Decimal
+	a.SumFeeEth, a.MinFeeEth, a.MaxFeeEth = sumEth.Decimal, minEth.Decimal, maxEth.Decimal
+	a.SumGasPrice, a.SumGasUsed = sumGasPrice.Decimal, sumGasUsed.Decimal
+	return nil
+}
+
+func (r *memoryRepository) GetTrxFeeStats(filter TrxFeeFilter) (*TrxFeeStats, error) {
+	return trxFeeStats(r.filterUniTrxFees(0, -1, filter.match)), nil
+}
+
+func (r *memoryRepository) GetTrxFeeSeries(filter TrxFeeFilter, step int64) ([]TrxFeeBucket, error) {
+	return bucketTrxFees(r.filterUniTrxFees(0, -1, filter.match), step), nil
+}
+
+// bucketTrxFees aggregates fees like GetTrxFeeSeries
+func bucketTrxFees(fees []UniTrxFee, step int64) []TrxFeeBucket {
+	var buckets []TrxFeeBucket
+	index := make(map[int64]int)
+	for _, fee := range fees {
+		start := int64(fee.TrxTime) - int64(fee.TrxTime)%step
+		i, ok := index[start]
+		if !ok {
+			i = len(buckets)
+			index[start] = i
+			buckets = append(buckets, TrxFeeBucket{Start: start})
+		}
+		buckets[i].add(&fee)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start < buckets[j].Start })
+	return buckets
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/eventbus"
+	"github.com/jaime1129/fedex/internal/repository"
+)
+
+// automatic retries back off exponentially from deadLetterBaseDelay up to
+// deadLetterMaxDelay, and stop after deadLetterMaxAttempts
+const (
+	deadLetterBaseDelay   = 30 * time.Second
+	deadLetterMaxDelay    = time.Hour
+	deadLetterMaxAttempts = 10
+	deadLetterRetryBatch  = 20
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter batch not found")
+	ErrDeadLetterClosed   = errors.New("dead letter batch already resolved or discarded")
+)
+
+type DeadLetterService interface {
+	ListDeadLetters(ctx context.Context, req *ListDeadLettersRequest) (*ListDeadLettersResponse, error)
+	RetryDeadLetter(ctx context.Context, id uint64) (*DeadLetterBatch, error)
+	DiscardDeadLetter(ctx context.Context, id uint64) (*DeadLetterBatch, error)
+	// RetryDueDeadLetters replays every pending batch whose backoff has elapsed
+	// and returns how many of them were resolved
+	RetryDueDeadLetters(ctx context.Context) (int, error)
+}
+
+type deadLetterService struct {
+	bnPriceCli components.BnPriceCli
+	repo       repository.Repository
+	bus        eventbus.Publisher
+}
+
+func NewDeadLetterService(
+	bnPriceCli components.BnPriceCli,
+	repo repository.Repository,
+	bus eventbus.Publisher,
+) DeadLetterService {
+	return &deadLetterService{
+		bnPriceCli: bnPriceCli,
+		repo:       repo,
+		bus:        bus,
+	}
+}
+
+type DeadLetterBatch struct {
+	ID          uint64 `json:"id"`
+	Symbol      string `json:"symbol"`
+	Source      string `json:"source"`
+	StartBlock  uint64 `json:"start_block"`
+	EndBlock    uint64 `json:"end_block"`
+	TrxCount    int    `json:"trx_count"`
+	NeedsPrice  bool   `json:"needs_price"`
+	Error       string `json:"error"`
+	Attempts    int    `json:"attempts"`
+	Status      string `json:"status"`
+	NextRetryAt int64  `json:"next_retry_at"`
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+func newDeadLetterBatch(b *repository.DeadLetterBatch) *DeadLetterBatch {
+	return &DeadLetterBatch{
+		ID:          b.ID,
+		Symbol:      b.Symbol,
+		Source:      b.Source,
+		StartBlock:  b.StartBlock,
+		EndBlock:    b.EndBlock,
+		TrxCount:    len(b.Payload.Fees),
+		NeedsPrice:  b.Payload.PriceQuery != nil,
+		Error:       b.Error,
+		Attempts:    b.Attempts,
+		Status:      b.Status,
+		NextRetryAt: b.NextRetryAt,
+		CreatedAt:   b.CreatedAt,
+		UpdatedAt:   b.UpdatedAt,
+	}
+}
+
+type ListDeadLettersRequest struct {
+	Status string
+	Page   int
+	Limit  int
+}
+
+type ListDeadLettersResponse struct {
+	Result []DeadLetterBatch `json:"result"`
+}
+
+func (s *deadLetterService) ListDeadLetters(ctx context.Context, req *ListDeadLettersRequest) (*ListDeadLettersResponse, error) {
+	if req == nil {
+		return nil, errors.New("nil req")
+	}
+	batches, err := s.repo.ListDeadLetters(req.Status, req.Page, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]DeadLetterBatch, len(batches))
+	for i := range batches {
+		res[i] = *newDeadLetterBatch(&batches[i])
+	}
+	return &ListDeadLettersResponse{Result: res}, nil
+}
+
+func (s *deadLetterService) RetryDeadLetter(ctx context.Context, id uint64) (*DeadLetterBatch, error) {
+	batch, err := s.getOpenDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// a manual retry is allowed even after automatic retries are exhausted
+	if err = s.retry(ctx, batch); err != nil {
+		return nil, err
+	}
+	return newDeadLetterBatch(batch), nil
+}
+
+func (s *deadLetterService) DiscardDeadLetter(ctx context.Context, id uint64) (*DeadLetterBatch, error) {
+	batch, err := s.getOpenDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+
+	batch.Status = repository.DeadLetterStatusDiscarded
+	if err = s.repo.UpdateDeadLetter(batch); err != nil {
+		return nil, err
+	}
+	return newDeadLetterBatch(batch), nil
+}
+
+func (s *deadLetterService) RetryDueDeadLetters(ctx context.Context) (int, error) {
+	batches, err := s.repo.ListDueDeadLetters(time.Now().Unix(), deadLetterRetryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	resolved := 0
+	for i := range batches {
+		if ctx.Err() != nil {
+			break
+		}
+		if err = s.retry(ctx, &batches[i]); err != nil {
+			log.Printf("fail to record retry of dead letter batch %d: %s\n", batches[i].ID, err.Error())
+			continue
+		}
+		if batches[i].Status == repository.DeadLetterStatusResolved {
+			resolved++
+		}
+	}
+	return resolved, nil
+}
+
+func (s *deadLetterService) getOpenDeadLetter(id uint64) (*repository.DeadLetterBatch, error) {
+	batch, err := s.repo.GetDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	if batch.Status == repository.DeadLetterStatusResolved || batch.Status == repository.DeadLetterStatusDiscarded {
+		return nil, ErrDeadLetterClosed
+	}
+	return batch, nil
+}
+
+// retry replays the batch and records the outcome; the returned error is only
+// about recording it, a failed replay is reflected in the batch status
+func (s *deadLetterService) retry(ctx context.Context, batch *repository.DeadLetterBatch) error {
+	batch.Attempts++
+	if err := s.replay(ctx, batch); err != nil {
+		log.Printf("dead letter batch %d retry %d failed: %s\n", batch.ID, batch.Attempts, err.Error())
+		batch.Error = err.Error()
+		batch.Status = repository.DeadLetterStatusPending
+		if batch.Attempts >= deadLetterMaxAttempts {
+			batch.Status = repository.DeadLetterStatusExhausted
+		}
+		batch.NextRetryAt = time.Now().Add(deadLetterRetryDelay(batch.Attempts)).Unix()
+		return s.repo.UpdateDeadLetter(batch)
+	}
+	return nil
+}
+
+// replay prices the fees of the batch if needed, then stores them and the
+// resolved batch in the same transaction, so that they are never stored twice
+func (s *deadLetterService) replay(ctx context.Context, batch *repository.DeadLetterBatch) error {
+	fees := batch.Payload.Fees
+	if q := batch.Payload.PriceQuery; q != nil && len(fees) > 0 {
+		price, err := s.bnPriceCli.QueryETHPrice(q.Start, q.End, q.Interval)
+		if err != nil {
+			return err
+		}
+		for i := range fees {
+			fees[i].SetPrice(price, q.Source())
+		}
+		// keep the priced fees so that later retries don't query the price again
+		batch.Payload.PriceQuery = nil
+	}
+
+	batch.Error = ""
+	batch.Status = repository.DeadLetterStatusResolved
+	var report repository.InsertReport
+	err := s.repo.WithTx(ctx, func(tx repository.TxRepository) (err error) {
+		// the fees may have been stored meanwhile by a tracker, with a coarser price
+		if report, err = tx.BatchInsertUniTrxFee(fees, repository.ConflictOverwriteIfBetterPrice); err != nil {
+			return err
+		}
+		return tx.UpdateDeadLetter(batch)
+	})
+	if err != nil {
+		return err
+	}
+	if report.Inserted > 0 {
+		s.bus.Publish(eventbus.FeeIngested{Source: eventbus.SourceDeadLetter, Symbol: batch.Symbol, Fees: report.Fees(fees, repository.OutcomeInserted)})
+	}
+	if report.Updated > 0 {
+		s.bus.Publish(eventbus.Repriced{Symbol: batch.Symbol, Fees: report.Fees(fees, repository.OutcomeUpdated)})
+	}
+	return nil
+}
+
+func deadLetterRetryDelay(attempts int) time.Duration {
+	delay := deadLetterBaseDelay
+	for i := 1; i < attempts && delay < deadLetterMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, deadLetterMaxDelay)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jaime1129/fedex/internal/eventbus"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	mock_components "github.com/jaime1129/fedex/mock/components"
+	mock_repository "github.com/jaime1129/fedex/mock/repository"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDeadLetterRepricesAndResolves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	service := NewDeadLetterService(mockBnPriceCli, mockRepo, eventbus.New())
+
+	batch := &repository.DeadLetterBatch{
+		ID:     1,
+		Status: repository.DeadLetterStatusPending,
+		Payload: repository.DeadLetterPayload{
+			Fees:       []repository.UniTrxFee{{TrxHash: "hash123", GasUsed: 21000, GasPrice: util.WeiFromUint64(1e9)}},
+			PriceQuery: &repository.PriceQuery{Start: 100, End: 160, Interval: "1m"},
+		},
+		Attempts: 1,
+	}
+	mockRepo.EXPECT().GetDeadLetter(uint64(1)).Return(batch, nil)
+	expectWithTx(mockRepo)
+	mockBnPriceCli.EXPECT().QueryETHPrice(int64(100), int64(160), "1m").Return(decimal.NewFromInt(2000), nil)
+	mockRepo.EXPECT().BatchInsertUniTrxFee(gomock.Any(), repository.ConflictOverwriteIfBetterPrice).DoAndReturn(func(fees []repository.UniTrxFee, policy repository.ConflictPolicy) (repository.InsertReport, error) {
+		assert.Equal(t, "0.042", fees[0].TrxFeeUsdt.String())
+		return repository.InsertReport{Inserted: 1, Outcomes: []repository.InsertOutcome{repository.OutcomeInserted}}, nil
+	})
+	mockRepo.EXPECT().UpdateDeadLetter(batch).Return(nil)
+
+	resp, err := service.RetryDeadLetter(context.TODO(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, repository.DeadLetterStatusResolved, resp.Status)
+	assert.Equal(t, 2, resp.Attempts)
+	assert.False(t, resp.NeedsPrice)
+}
+
+func TestRetryDeadLetterFailureSchedulesNextRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	service := NewDeadLetterService(nil, mockRepo, eventbus.New())
+
+	batch := &repository.DeadLetterBatch{
+		ID:       1,
+		Status:   repository.DeadLetterStatusPending,
+		Payload:  repository.DeadLetterPayload{Fees: []repository.UniTrxFee{{TrxHash: "hash123"}}},
+		Attempts: deadLetterMaxAttempts - 1,
+	}
+	mockRepo.EXPECT().GetDeadLetter(uint64(1)).Return(batch, nil)
+	expectWithTx(mockRepo)
+	mockRepo.EXPECT().BatchInsertUniTrxFee(gomock.Any(), repository.ConflictOverwriteIfBetterPrice).Return(repository.InsertReport{}, errors.New("db down"))
+	mockRepo.EXPECT().UpdateDeadLetter(batch).Return(nil)
+
+	resp, err := service.RetryDeadLetter(context.TODO(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, repository.DeadLetterStatusExhausted, resp.Status)
+	assert.Equal(t, "db down", resp.Error)
+	assert.NotZero(t, resp.NextRetryAt)
+}
+
+// expectWithTx runs the transaction on the mock itself
+func expectWithTx(mockRepo *mock_repository.MockRepository) {
+	mockRepo.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, fn func(tx repository.TxRepository) error) error {
+			return fn(mockRepo)
+		})
+}
+
+func TestDiscardResolvedDeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	service := NewDeadLetterService(nil, mockRepo, eventbus.New())
+
+	mockRepo.EXPECT().GetDeadLetter(uint64(1)).Return(&repository.DeadLetterBatch{ID: 1, Status: repository.DeadLetterStatusResolved}, nil)
+
+	_, err := service.DiscardDeadLetter(context.TODO(), 1)
+	assert.ErrorIs(t, err, ErrDeadLetterClosed)
+}
+
+func TestDeadLetterRetryDelay(t *testing.T) {
+	assert.Equal(t, deadLetterBaseDelay, deadLetterRetryDelay(1))
+	assert.Equal(t, 2*deadLetterBaseDelay, deadLetterRetryDelay(2))
+	assert.Equal(t, deadLetterMaxDelay, deadLetterRetryDelay(20))
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/repository"
+)
+
+const (
+	// watermark name of the last fee write rolled up
+	rollupJob = "trx_fee_rollup"
+	// maximum number of fees read from db per query
+	rollupPageSize = 1000
+	// fees are read in the order of their write time, which is taken before
+	// their transaction commits. Readers read this many seconds of writes
+	// again behind their position, so that the writes of transactions that
+	// take up to that long to commit are not skipped.
+	trxFeeReplayWindow = 5 * 60
+)
+
+// RollupService maintains the hourly and daily rollups of the stored fees.
+// Hours are recomputed from the fees whenever they change, then the days
+// they belong to are recomputed from the hours.
+type RollupService interface {
+	// Update rolls up the fees written since the last update and the invalidated hours
+	Update(ctx context.Context) error
+	// InvalidateFees schedules the hours of fees to be rolled up again, like after they were repriced
+	InvalidateFees(symbol string, fees []repository.UniTrxFee)
+	// InvalidateBlocks schedules the hours holding fees of blocks in
+	// [fromBlock, toBlock] to be rolled up again, like after they were reorged out
+	InvalidateBlocks(symbol string, fromBlock uint64, toBlock uint64) error
+	// CoveredUntil returns the unix time the last successful update started at,
+	// rollups hold the fees stored before it. It is 0 before the first update.
+	CoveredUntil() int64
+	// StaleHours returns the starts of the hours in [start, end) holding fees of
+	// symbol that were written too recently to be sure the last update rolled
+	// them up, like fees backfilled for older hours since then
+	StaleHours(symbol string, start int64, end int64) ([]int64, error)
+}
+
+type rollupHour struct {
+	symbol string
+	start  int64
+}
+
+type rollupService struct {
+	repo         repository.Repository
+	mu           sync.Mutex
+	dirty        map[rollupHour]struct{}
+	coveredUntil atomic.Int64
+	// fees written after this unix time may be missing from the rollups
+	freshUntil atomic.Int64
+}
+
+func NewRollupService(repo repository.Repository) RollupService {
+	return &rollupService{
+		repo:  repo,
+		dirty: make(map[rollupHour]struct{}),
+	}
+}
+
+func (s *rollupService) CoveredUntil() int64 {
+	return s.coveredUntil.Load()
+}
+
+// StaleHours lists the hours of the fees written after the watermark of the
+// last update, and those of the replay window behind it, whose fees committed
+// late are only rolled up by the next update
+func (s *rollupService) StaleHours(symbol string, start int64, end int64) ([]int64, error) {
+	return s.repo.ListTrxFeeHoursModifiedAfter(symbol, s.freshUntil.Load(), start, end)
+}
+
+func (s *rollupService) InvalidateFees(symbol string, fees []repository.UniTrxFee) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range fees {
+		s.dirty[rollupHour{symbol: symbol, start: repository.RollupHour.Truncate(int64(fees[i].TrxTime))}] = struct{}{}
+	}
+}
+
+func (s *rollupService) InvalidateBlocks(symbol string, fromBlock uint64, toBlock uint64) error {
+	starts, err := s.repo.ListRollupHoursByBlock(symbol, fromBlock, toBlock)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, start := range starts {
+		s.dirty[rollupHour{symbol: symbol, start: start}] = struct{}{}
+	}
+	return nil
+}
+
+// Update reads the fees written since the watermark, including the replay
+// window behind it, so that fees committed late or repriced without an event
+// are rolled up too
+func (s *rollupService) Update(ctx context.Context) error {
+	started := time.Now().Unix()
+	watermark, err := s.repo.GetJobWatermark(rollupJob)
+	if err != nil {
+		return err
+	}
+
+	after := repository.TrxFeeTimeCursor{Time: watermark - trxFeeReplayWindow}
+	for {
+		fees, err := s.repo.ListTrxFeeModifiedAfter(after, repository.StreamFilter{}, rollupPageSize)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		for i := range fees {
+			s.dirty[rollupHour{symbol: fees[i].Symbol, start: repository.RollupHour.Truncate(int64(fees[i].TrxTime))}] = struct{}{}
+		}
+		s.mu.Unlock()
+
+		// the watermark only moves along with the hours of the page
+		if len(fees) > 0 {
+			last := &fees[len(fees)-1]
+			after = repository.TrxFeeTimeCursor{Time: last.UpdatedAt, ID: last.ID}
+			watermark = max(watermark, last.UpdatedAt)
+		}
+		if err = s.flush(ctx, watermark); err != nil {
+			return err
+		}
+		if len(fees) < rollupPageSize {
+			break
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	s.freshUntil.Store(watermark - trxFeeReplayWindow)
+	s.coveredUntil.Store(started)
+	return nil
+}
+
+// flush rolls up the dirty hours and their days and, unless watermark is 0,
+// saves it, all in one transaction. The hours are dirty again if it fails.
+func (s *rollupService) flush(ctx context.Context, watermark int64) (err error) {
+	s.mu.Lock()
+	hours := make([]rollupHour, 0, len(s.dirty))
+	for h := range s.dirty {
+		hours = append(hours, h)
+	}
+	s.dirty = make(map[rollupHour]struct{})
+	s.mu.Unlock()
+	if len(hours) == 0 && watermark == 0 {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			s.mu.Lock()
+			for _, h := range hours {
+				s.dirty[h] = struct{}{}
+			}
+			s.mu.Unlock()
+		}
+	}()
+
+	sort.Slice(hours, func(i, j int) bool {
+		if hours[i].symbol != hours[j].symbol {
+			return hours[i].symbol < hours[j].symbol
+		}
+		return hours[i].start < hours[j].start
+	})
+	return s.repo.WithTx(ctx, func(tx repository.TxRepository) error {
+		var days []rollupHour
+		for _, h := range hours {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.rollupHour(tx, h); err != nil {
+				return err
+			}
+			day := rollupHour{symbol: h.symbol, start: repository.RollupDay.Truncate(h.start)}
+			if len(days) == 0 || days[len(days)-1] != day {
+				days = append(days, day)
+			}
+		}
+		for _, d := range days {
+			if err := s.rollupDay(tx, d); err != nil {
+				return err
+			}
+		}
+		if watermark == 0 {
+			return nil
+		}
+		return tx.SetJobWatermark(rollupJob, watermark)
+	})
+}
+
+func (s *rollupService) rollupHour(tx repository.TxRepository, h rollupHour) error {
+	rollup := repository.TrxFeeRollup{Symbol: h.symbol, Period: repository.RollupHour, Start: h.start}
+	err := forEachTrxFee(tx, repository.TrxFeeFilter{
+		Symbol:    h.symbol,
+		StartTime: h.start,
+		EndTime:   h.start + int64(repository.RollupHour) - 1,
+	}, rollup.Add)
+	if err != nil {
+		return err
+	}
+	return tx.SaveTrxFeeRollup(&rollup)
+}
+
+func (s *rollupService) rollupDay(tx repository.TxRepository, d rollupHour) error {
+	hours, err := tx.ListTrxFeeRollups(repository.RollupHour, d.symbol, d.start, d.start+int64(repository.RollupDay))
+	if err != nil {
+		return err
+	}
+	rollup := repository.TrxFeeRollup{Symbol: d.symbol, Period: repository.RollupDay, Start: d.start}
+	for i := range hours {
+		rollup.Merge(&hours[i])
+	}
+	return tx.SaveTrxFeeRollup(&rollup)
+}
+
+// forEachTrxFee calls fn with every fee matching filter, reading them in pages
+func forEachTrxFee(repo repository.TxRepository, filter repository.TrxFeeFilter, fn func(fee *repository.UniTrxFee)) error {
+	query := repository.TrxFeeListQuery{Filter: filter, Limit: rollupPageSize}
+	for {
+		fees, err := repo.ListTrxFee(query)
+		if err != nil {
+			return err
+		}
+		for i := range fees {
+			fn(&fees[i])
+		}
+		if len(fees) < query.Limit {
+			return nil
+		}
+		after := query.NewTrxFeeCursor(&fees[len(fees)-1])
+		query.After = &after
+	}
+}
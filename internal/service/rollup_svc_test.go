@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rollupEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// rollupFees returns n fees 20 minutes apart from rollupEpoch, the i-th one is
+// 0.021*(i%7+1) USDT in block 1000+i
+func rollupFees(prefix string, from int, n int) []repository.UniTrxFee {
+	fees := make([]repository.UniTrxFee, n)
+	for i := range fees {
+		k := from + i
+		fees[i] = repository.UniTrxFee{
+			Symbol:      "WETH/USDC",
+			TrxHash:     fmt.Sprintf("0x%s%d", prefix, k),
+			TrxTime:     uint64(rollupEpoch.Add(time.Duration(k) * 20 * time.Minute).Unix()),
+			GasUsed:     uint64(21000 * (k%7 + 1)),
+			GasPrice:    util.WeiFromGwei(decimal.NewFromInt(1)),
+			BlockNumber: uint64(1000 + k),
+		}
+		fees[i].SetPrice(decimal.NewFromInt(1000), "binance_1m")
+	}
+	return fees
+}
+
+func TestRollupServiceUpdate(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	svc := NewRollupService(repo)
+	assert.Zero(t, svc.CoveredUntil())
+
+	// 3 fees an hour over two days
+	insertFees(t, repo, rollupFees("a", 0, 100))
+	require.NoError(t, svc.Update(context.TODO()))
+	assert.NotZero(t, svc.CoveredUntil())
+
+	day := rollupEpoch.Unix()
+	hours, err := repo.ListTrxFeeRollups(repository.RollupHour, "WETH/USDC", day, day+2*86400)
+	require.NoError(t, err)
+	require.Len(t, hours, 34)
+	assert.Equal(t, int64(3), hours[0].Count)
+	assert.Equal(t, []uint64{1000, 1002}, []uint64{hours[0].MinBlock, hours[0].MaxBlock})
+	assert.Equal(t, int64(1), hours[33].Count)
+
+	days, err := repo.ListTrxFeeRollups(repository.RollupDay, "WETH/USDC", day, day+2*86400)
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, []int64{72, 28}, []int64{days[0].Count, days[1].Count})
+
+	// only the hours of new fees are rolled up again
+	insertFees(t, repo, rollupFees("b", 99, 2))
+	require.NoError(t, svc.Update(context.TODO()))
+	days, err = repo.ListTrxFeeRollups(repository.RollupDay, "WETH/USDC", day, day+2*86400)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{72, 30}, []int64{days[0].Count, days[1].Count})
+	last, err := repo.GetTrxFee("0xb100")
+	require.NoError(t, err)
+	watermark, err := repo.GetJobWatermark(rollupJob)
+	require.NoError(t, err)
+	assert.Equal(t, last.UpdatedAt, watermark)
+}
+
+func TestRollupServiceUpdateRepairsMissedReprices(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	svc := NewRollupService(repo)
+	insertFees(t, repo, rollupFees("a", 0, 3))
+	require.NoError(t, svc.Update(context.TODO()))
+
+	// repriced without InvalidateFees, like when the event was dropped
+	repriced := rollupFees("a", 0, 1)
+	repriced[0].SetPrice(decimal.NewFromInt(2000), "binance_1s")
+	_, err := repo.BatchInsertUniTrxFee(repriced, repository.ConflictOverwrite)
+	require.NoError(t, err)
+	require.NoError(t, svc.Update(context.TODO()))
+
+	day := rollupEpoch.Unix()
+	hours, err := repo.ListTrxFeeRollups(repository.RollupHour, "WETH/USDC", day, day+3600)
+	require.NoError(t, err)
+	require.Len(t, hours, 1)
+	// 0.042 + 0.042 + 0.063 USDT
+	assert.Equal(t, "0.147", hours[0].SumFeeUsdt.String())
+}
+
+// failingWatermarkRepo fails to move the watermark within transactions
+type failingWatermarkRepo struct {
+	repository.Repository
+}
+
+type failingWatermarkTx struct {
+	repository.TxRepository
+}
+
+func (r failingWatermarkRepo) WithTx(ctx context.Context, fn func(tx repository.TxRepository) error) error {
+	return r.Repository.WithTx(ctx, func(tx repository.TxRepository) error {
+		return fn(failingWatermarkTx{tx})
+	})
+}
+
+func (tx failingWatermarkTx) SetJobWatermark(job string, lastModified int64) error {
+	return errors.New("db down")
+}
+
+func TestRollupServiceUpdateIsAtomic(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	insertFees(t, repo, rollupFees("a", 0, 3))
+
+	// the hours saved before the watermark failed are rolled back with it
+	assert.Error(t, NewRollupService(failingWatermarkRepo{repo}).Update(context.TODO()))
+	day := rollupEpoch.Unix()
+	hours, err := repo.ListTrxFeeRollups(repository.RollupHour, "WETH/USDC", day, day+86400)
+	require.NoError(t, err)
+	assert.Empty(t, hours)
+	watermark, err := repo.GetJobWatermark(rollupJob)
+	require.NoError(t, err)
+	assert.Zero(t, watermark)
+}
+
+func TestRollupServiceInvalidate(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	svc := NewRollupService(repo)
+	insertFees(t, repo, rollupFees("a", 0, 3))
+	require.NoError(t, svc.Update(context.TODO()))
+
+	// stale rollups of fees that are gone, like after a reorg or a reprice to another hour
+	stale := rollupFees("stale", 30, 2)
+	for i := range stale {
+		rollup := repository.TrxFeeRollup{Symbol: "WETH/USDC", Period: repository.RollupHour, Start: repository.RollupHour.Truncate(int64(stale[i].TrxTime))}
+		rollup.Add(&stale[i])
+		require.NoError(t, repo.SaveTrxFeeRollup(&rollup))
+	}
+	require.NoError(t, svc.InvalidateBlocks("WETH/USDC", 1030, 1030))
+	svc.InvalidateFees("WETH/USDC", stale[1:])
+	require.NoError(t, svc.Update(context.TODO()))
+
+	day := rollupEpoch.Unix()
+	hours, err := repo.ListTrxFeeRollups(repository.RollupHour, "WETH/USDC", day, day+86400)
+	require.NoError(t, err)
+	require.Len(t, hours, 1)
+	assert.Equal(t, int64(3), hours[0].Count)
+	days, err := repo.ListTrxFeeRollups(repository.RollupDay, "WETH/USDC", day, day+86400)
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, int64(3), days[0].Count)
+}
+
+// settleRollups treats the fees read by the last update of svc as written
+// before its replay window, as if it had passed since
+func settleRollups(t *testing.T, svc RollupService, repo repository.Repository) {
+	watermark, err := repo.GetJobWatermark(rollupJob)
+	require.NoError(t, err)
+	svc.(*rollupService).freshUntil.Store(watermark)
+}
+
+func TestStatsAndSeriesFromRollups(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	insertFees(t, repo, rollupFees("a", 0, 300))
+	rollups := NewRollupService(repo)
+	require.NoError(t, rollups.Update(context.TODO()))
+	settleRollups(t, rollups, repo)
+
+	exact := NewTrxService(nil, nil, repo, nil)
+	rolledUp := NewTrxService(nil, nil, repo, rollups)
+
+	// from the middle of the first day to the middle of the fourth one, so that
+	// fees, hours and days are all read
+	start := rollupEpoch.Add(11*time.Hour + 30*time.Minute).Unix()
+	end := rollupEpoch.Add(3*24*time.Hour + 13*time.Hour + 10*time.Minute).Unix()
+	req := &GetTrxFeeStatsRequest{Symbol: "WETH/USDC", StartTime: start, EndTime: end}
+	expected, err := exact.GetTrxFeeStats(context.TODO(), req)
+	require.NoError(t, err)
+	assert.False(t, expected.Approximate)
+	actual, err := rolledUp.GetTrxFeeStats(context.TODO(), req)
+	require.NoError(t, err)
+	assert.True(t, actual.Approximate)
+
+	assert.Equal(t, expected.Count, actual.Count)
+	assert.Equal(t, expected.FeeUsdt.FeeSummary, actual.FeeUsdt.FeeSummary)
+	assert.Equal(t, expected.FeeEth.FeeSummary, actual.FeeEth.FeeSummary)
+	assert.Equal(t, expected.AvgGasPrice, actual.AvgGasPrice)
+	assert.Equal(t, expected.AvgGasUsed, actual.AvgGasUsed)
+	for _, p := range [][2]decimal.Decimal{
+		{expected.FeeUsdt.Median, actual.FeeUsdt.Median},
+		{expected.FeeUsdt.P90, actual.FeeUsdt.P90},
+		{expected.FeeEth.P99, actual.FeeEth.P99},
+	} {
+		assert.InDelta(t, p[0].InexactFloat64(), p[1].InexactFloat64(), p[0].InexactFloat64()*util.SketchRelativeAccuracy)
+	}
+
+	for _, interval := range []string{IntervalHour, IntervalDay} {
+		for _, tz := range []string{"UTC", "Asia/Kolkata"} {
+			req := &GetTrxFeeSeriesRequest{Symbol: "WETH/USDC", StartTime: start, EndTime: end, Interval: interval, Timezone: tz}
+			expected, err := exact.GetTrxFeeSeries(context.TODO(), req)
+			require.NoError(t, err)
+			actual, err := rolledUp.GetTrxFeeSeries(context.TODO(), req)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual, "%s in %s", interval, tz)
+		}
+	}
+}
+
+func TestStatsReadFeesWrittenSinceTheUpdate(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	insertFees(t, repo, rollupFees("a", 0, 300))
+	rollups := NewRollupService(repo)
+	require.NoError(t, rollups.Update(context.TODO()))
+	settleRollups(t, rollups, repo)
+
+	// right after the update, the fees written before it are all rolled up
+	start, end := rollupEpoch.Unix(), rollupEpoch.Add(4*24*time.Hour).Unix()-1
+	stale, err := rollups.StaleHours("WETH/USDC", start, end+1)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+
+	// fees backfilled for older hours after the update, in a later second than
+	// its watermark
+	time.Sleep(time.Until(time.Unix(time.Now().Unix()+1, 0)))
+	late := rollupFees("late", 30, 2)
+	late = append(late, rollupFees("late", 100, 1)...)
+	insertFees(t, repo, late)
+	stale, err = rollups.StaleHours("WETH/USDC", start, end+1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{start + 10*3600, start + 33*3600}, stale)
+
+	exact := NewTrxService(nil, nil, repo, nil)
+	rolledUp := NewTrxService(nil, nil, repo, rollups)
+	req := &GetTrxFeeStatsRequest{Symbol: "WETH/USDC", StartTime: start, EndTime: end}
+	expected, err := exact.GetTrxFeeStats(context.TODO(), req)
+	require.NoError(t, err)
+	actual, err := rolledUp.GetTrxFeeStats(context.TODO(), req)
+	require.NoError(t, err)
+	assert.True(t, actual.Approximate)
+	assert.Equal(t, int64(291), actual.Count)
+	assert.Equal(t, expected.Count, actual.Count)
+	assert.Equal(t, expected.FeeUsdt.FeeSummary, actual.FeeUsdt.FeeSummary)
+
+	for _, interval := range []string{IntervalHour, IntervalDay} {
+		req := &GetTrxFeeSeriesRequest{Symbol: "WETH/USDC", StartTime: start, EndTime: end, Interval: interval}
+		expected, err := exact.GetTrxFeeSeries(context.TODO(), req)
+		require.NoError(t, err)
+		actual, err := rolledUp.GetTrxFeeSeries(context.TODO(), req)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, interval)
+	}
+
+	// rolled up by the next update
+	require.NoError(t, rollups.Update(context.TODO()))
+	settleRollups(t, rollups, repo)
+	stale, err = rollups.StaleHours("WETH/USDC", start, end+1)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+	actual, err = rolledUp.GetTrxFeeStats(context.TODO(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(291), actual.Count)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+)
+
+const (
+	// MaxTrxFeeBatchSize is the maximum number of trx hashes of a batch query
+	MaxTrxFeeBatchSize = 100
+	// concurrent upstream lookups of a batch query, etherscan calls are rate
+	// limited by the client whatever the concurrency
+	trxFeeBatchConcurrency = 5
+)
+
+type GetTrxFeeBatchRequest struct {
+	TrxHashes []string `json:"trx_hashes"`
+	// one of currency.Supported to also convert the fees to, none if empty
+	Currency string `json:"currency"`
+}
+
+// TrxFeeBatchResult is the outcome of the query of one trx hash of a batch
+type TrxFeeBatchResult struct {
+	TrxHash string
+	// nil if Err is set
+	Fee *repository.UniTrxFee
+	// only if a currency is requested and Err isn't set
+	Conversion *FeeConversion
+	// why the fee of this hash couldn't be served, as the single query would have failed
+	Err error
+}
+
+type GetTrxFeeBatchResponse struct {
+	// in the order of the requested hashes
+	Results []TrxFeeBatchResult
+}
+
+// GetTrxFeeBatch serves the fees of the trx hashes stored in db, then looks up
+// the missing ones concurrently. A failed lookup only fails its own result.
+func (c *trxFeeService) GetTrxFeeBatch(ctx context.Context, req *GetTrxFeeBatchRequest) (*GetTrxFeeBatchResponse, error) {
+	if req == nil {
+		return nil, errors.New("nil req")
+	}
+	if len(req.TrxHashes) == 0 || len(req.TrxHashes) > MaxTrxFeeBatchSize {
+		return nil, fmt.Errorf("%w: between 1 and %d trx hashes are required, got %d", ErrInvalidArgument, MaxTrxFeeBatchSize, len(req.TrxHashes))
+	}
+	if err := validateCurrency(req.Currency); err != nil {
+		return nil, err
+	}
+
+	// results are keyed by the lower-case hash, which is how fees are stored:
+	// the casings of a hash are the same fee
+	results := make(map[string]TrxFeeBatchResult, len(req.TrxHashes))
+	var hashes []string
+	for _, hash := range req.TrxHashes {
+		hash = strings.ToLower(hash)
+		if !util.IsTrxHash(hash) {
+			results[hash] = TrxFeeBatchResult{TrxHash: hash, Err: fmt.Errorf("%w: malformed trx hash %s", ErrInvalidArgument, hash)}
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+
+	stored, err := c.repo.ListTrxFeeByHash(hashes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stored {
+		hash := strings.ToLower(stored[i].TrxHash)
+		results[hash] = TrxFeeBatchResult{TrxHash: hash, Fee: &stored[i]}
+	}
+
+	var misses []string
+	for _, hash := range hashes {
+		if _, ok := results[hash]; !ok {
+			// placeholder so that repeated hashes are looked up once
+			results[hash] = TrxFeeBatchResult{TrxHash: hash}
+			misses = append(misses, hash)
+		}
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, trxFeeBatchConcurrency)
+	)
+	for _, hash := range misses {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			results[hash] = TrxFeeBatchResult{TrxHash: hash, Err: ctx.Err()}
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// shared with the single queries of the same hash
+			lookup, err := c.sharedLookup(ctx, hash)
+			result := TrxFeeBatchResult{TrxHash: hash, Err: err}
+			if err == nil {
+				result.Fee = lookup.fee
+			}
+			mu.Lock()
+			results[hash] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// a fee that can't be converted fails its own result, as it would fail the single query
+	for hash, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		if res.Conversion, res.Err = c.convertFee(res.Fee, req.Currency); res.Err != nil {
+			res.Fee = nil
+		}
+		results[hash] = res
+	}
+
+	resp := &GetTrxFeeBatchResponse{Results: make([]TrxFeeBatchResult, len(req.TrxHashes))}
+	for i, hash := range req.TrxHashes {
+		resp.Results[i] = results[strings.ToLower(hash)]
+	}
+	return resp, nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	mock_components "github.com/jaime1129/fedex/mock/components"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTrxFeeBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(mockEthScanCli, mockBnPriceCli, repo, nil)
+
+	fetchedHash, storedHash, unknownHash, failingHash := testTrxHash(1), testTrxHash(2), testTrxHash(3), testTrxHash(4)
+	stored := repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: storedHash, GasUsed: 21000, GasPrice: util.WeiFromUint64(1e9)}
+	stored.SetPrice(decimal.NewFromInt(1000), "binance_1m")
+	insertFees(t, repo, []repository.UniTrxFee{stored})
+
+	// only the missing hashes are looked up, once each
+	mockEthScanCli.EXPECT().QueryTrxFee(fetchedHash).Return(&components.EthScanTrxResponse{
+		Result: components.EthScanTrxResult{GasUsed: "0x5208", EffectiveGasPrice: "0x3B9ACA00", BlockNumber: "0x10FB78",
+			Logs: []components.EthScanTrxLog{{Address: "0x88E6A0c2dDD26FEEb64F039a2c41296FcB3f5640"}}},
+	}, nil).Times(1)
+	mockEthScanCli.EXPECT().QueryBlock("0x10FB78").Return(&components.EthScanBlockResponse{
+		Result: components.EthScanBlockResult{Timestamp: "0x5BA46680"},
+	}, nil)
+	mockBnPriceCli.EXPECT().QueryETHPrice(gomock.Any(), gomock.Any(), "1m").Return(decimal.NewFromInt(2000), nil)
+	mockEthScanCli.EXPECT().QueryTrxFee(unknownHash).Return(&components.EthScanTrxResponse{}, nil)
+	mockEthScanCli.EXPECT().QueryTrxFee(failingHash).Return(nil, components.ErrUpstreamUnavailable)
+
+	resp, err := service.GetTrxFeeBatch(context.TODO(), &GetTrxFeeBatchRequest{
+		TrxHashes: []string{fetchedHash, storedHash, unknownHash, failingHash, "0xmalformed", fetchedHash},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 6)
+
+	for _, i := range []int{0, 1, 5} {
+		require.NoError(t, resp.Results[i].Err)
+		require.NotNil(t, resp.Results[i].Fee)
+	}
+	assert.Equal(t, fetchedHash, resp.Results[0].TrxHash)
+	assert.Equal(t, "0.042", resp.Results[0].Fee.TrxFeeUsdt.String())
+	assert.Equal(t, "0.021", resp.Results[1].Fee.TrxFeeUsdt.String())
+	assert.Equal(t, "0.042", resp.Results[5].Fee.TrxFeeUsdt.String())
+	assert.ErrorIs(t, resp.Results[2].Err, ErrTrxNotFound)
+	assert.ErrorIs(t, resp.Results[3].Err, components.ErrUpstreamUnavailable)
+	assert.ErrorIs(t, resp.Results[4].Err, ErrInvalidArgument)
+	assert.Equal(t, "0xmalformed", resp.Results[4].TrxHash)
+
+	// fetched fees are stored
+	fee, err := repo.GetTrxFee(fetchedHash)
+	require.NoError(t, err)
+	assert.NotNil(t, fee)
+}
+
+func TestGetTrxFeeBatchNormalizesHashCase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(mockEthScanCli, mockBnPriceCli, repo, nil)
+
+	storedHash, fetchedHash := "0x"+strings.Repeat("cd", 32), "0x"+strings.Repeat("ef", 32)
+	stored := repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: storedHash, GasUsed: 21000, GasPrice: util.WeiFromUint64(1e9)}
+	stored.SetPrice(decimal.NewFromInt(1000), "binance_1m")
+	insertFees(t, repo, []repository.UniTrxFee{stored})
+
+	// the stored hash isn't looked up, the missing one is looked up once
+	// whatever its casings
+	mockEthScanCli.EXPECT().QueryTrxFee(fetchedHash).Return(&components.EthScanTrxResponse{
+		Result: components.EthScanTrxResult{GasUsed: "0x5208", EffectiveGasPrice: "0x3B9ACA00", BlockNumber: "0x10FB78",
+			Logs: []components.EthScanTrxLog{{Address: "0x88E6A0c2dDD26FEEb64F039a2c41296FcB3f5640"}}},
+	}, nil).Times(1)
+	mockEthScanCli.EXPECT().QueryBlock("0x10FB78").Return(&components.EthScanBlockResponse{
+		Result: components.EthScanBlockResult{Timestamp: "0x5BA46680"},
+	}, nil)
+	mockBnPriceCli.EXPECT().QueryETHPrice(gomock.Any(), gomock.Any(), "1m").Return(decimal.NewFromInt(2000), nil)
+
+	resp, err := service.GetTrxFeeBatch(context.TODO(), &GetTrxFeeBatchRequest{
+		TrxHashes: []string{strings.ToUpper(storedHash[2:]), "0x" + strings.ToUpper(storedHash[2:]), "0x" + strings.ToUpper(fetchedHash[2:]), fetchedHash},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 4)
+	assert.ErrorIs(t, resp.Results[0].Err, ErrInvalidArgument)
+	require.NoError(t, resp.Results[1].Err)
+	assert.Equal(t, storedHash, resp.Results[1].TrxHash)
+	assert.Equal(t, "0.021", resp.Results[1].Fee.TrxFeeUsdt.String())
+	for _, i := range []int{2, 3} {
+		require.NoError(t, resp.Results[i].Err)
+		assert.Equal(t, fetchedHash, resp.Results[i].TrxHash)
+		assert.Equal(t, "0.042", resp.Results[i].Fee.TrxFeeUsdt.String())
+	}
+
+	// stored once, in lower case
+	fees, err := repo.ListTrxFee(repository.TrxFeeListQuery{Filter: repository.TrxFeeFilter{Symbol: "WETH/USDC", EndTime: 1 << 40}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, fees, 2)
+	assert.ElementsMatch(t, []string{storedHash, fetchedHash}, []string{fees[0].TrxHash, fees[1].TrxHash})
+}
+
+func TestGetTrxFeeBatchRejectsInvalidSize(t *testing.T) {
+	service := NewTrxService(nil, nil, repository.NewMemoryRepository(), nil)
+
+	hashes := make([]string, MaxTrxFeeBatchSize+1)
+	for i := range hashes {
+		hashes[i] = testTrxHash(i)
+	}
+	for name, req := range map[string]*GetTrxFeeBatchRequest{
+		"no hash":         {},
+		"too many hashes": {TrxHashes: hashes},
+	} {
+		_, err := service.GetTrxFeeBatch(context.TODO(), req)
+		assert.ErrorIs(t, err, ErrInvalidArgument, name)
+	}
+}
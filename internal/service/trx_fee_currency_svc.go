@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jaime1129/fedex/internal/currency"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// TrxFeeServiceOption configures a TrxFeeService created by NewTrxService
+type TrxFeeServiceOption func(c *trxFeeService)
+
+// WithConverter makes the fees requested in other currencies than USDT be
+// converted by conv, which by default only knows the stablecoins
+func WithConverter(conv *currency.Converter) TrxFeeServiceOption {
+	return func(c *trxFeeService) {
+		c.converter = conv
+	}
+}
+
+// FeeConversion is a fee in the requested currency
+type FeeConversion struct {
+	Currency string `json:"currency"`
+	// units of the currency one USDT was worth at the trx time
+	UsdtRate decimal.Decimal `json:"usdt_rate" swaggertype:"string"`
+	Fee      decimal.Decimal `json:"fee" swaggertype:"string"`
+}
+
+// FeeDistributionConversion is a FeeDistribution in USDT converted to the
+// requested currency
+type FeeDistributionConversion struct {
+	Currency string `json:"currency"`
+	// units of the currency one USDT was worth in the middle of the time range
+	UsdtRate decimal.Decimal `json:"usdt_rate" swaggertype:"string"`
+	Fee      FeeDistribution `json:"fee"`
+}
+
+// FeeSummaryConversion is a FeeSummary in USDT converted to the requested
+// currency
+type FeeSummaryConversion struct {
+	Currency string `json:"currency"`
+	// units of the currency one USDT was worth in the middle of the bucket
+	UsdtRate decimal.Decimal `json:"usdt_rate" swaggertype:"string"`
+	Fee      FeeSummary      `json:"fee"`
+}
+
+func validateCurrency(cur string) error {
+	if cur != "" && !currency.IsSupported(cur) {
+		return fmt.Errorf("%w: currency must be one of %s", ErrInvalidArgument, strings.Join(currency.Supported, ", "))
+	}
+	return nil
+}
+
+// convertFee converts a fee from its ETH value at the trx time, it returns nil
+// if no currency is requested
+func (c *trxFeeService) convertFee(fee *repository.UniTrxFee, cur string) (*FeeConversion, error) {
+	if cur == "" {
+		return nil, nil
+	}
+	amount, rate, err := c.converter.FromETH(fee.TrxFeeEth, fee.EthUsdtPrice, cur, int64(fee.TrxTime))
+	if err != nil {
+		return nil, conversionError(err)
+	}
+	return &FeeConversion{Currency: cur, UsdtRate: rate, Fee: amount}, nil
+}
+
+// usdtRate returns the rate of a requested currency at a unix time, or false
+// if no currency is requested
+func (c *trxFeeService) usdtRate(cur string, at int64) (decimal.Decimal, bool, error) {
+	if cur == "" {
+		return decimal.Zero, false, nil
+	}
+	rate, err := c.converter.Rate(cur, at)
+	if err != nil {
+		return decimal.Zero, false, conversionError(err)
+	}
+	return rate, true, nil
+}
+
+// conversionError makes a currency without a rate an invalid argument, other
+// errors come from the rate sources
+func conversionError(err error) error {
+	if errors.Is(err, currency.ErrNoRate) {
+		return fmt.Errorf("%w: %w", ErrInvalidArgument, err)
+	}
+	return err
+}
+
+// convertSummary converts a summary in USDT with a positive rate, which keeps
+// the min, max and percentiles what they are
+func convertSummary(s FeeSummary, rate decimal.Decimal) FeeSummary {
+	return FeeSummary{
+		Sum:  currency.FromUSDT(s.Sum, rate),
+		Min:  currency.FromUSDT(s.Min, rate),
+		Max:  currency.FromUSDT(s.Max, rate),
+		Mean: currency.FromUSDT(s.Mean, rate),
+	}
+}
+
+func convertDistribution(d FeeDistribution, rate decimal.Decimal) FeeDistribution {
+	return FeeDistribution{
+		FeeSummary: convertSummary(d.FeeSummary, rate),
+		Median:     currency.FromUSDT(d.Median, rate),
+		P90:        currency.FromUSDT(d.P90, rate),
+		P99:        currency.FromUSDT(d.P99, rate),
+	}
+}
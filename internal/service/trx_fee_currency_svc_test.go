@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jaime1129/fedex/internal/currency"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeesAreConvertedToTheRequestedCurrency(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	// 0.8 EUR per USDT until 3600, 0.5 after
+	converter := currency.NewConverter(map[string]currency.RateSource{
+		currency.EUR: currency.NewTableSource(map[string][]currency.TableRate{currency.EUR: {
+			{Since: 0, Rate: decimal.RequireFromString("0.8")},
+			{Since: 3600, Rate: decimal.RequireFromString("0.5")},
+		}}),
+	})
+	service := NewTrxService(nil, nil, repo, nil, WithConverter(converter))
+
+	// 0.042 USDT each
+	var fees []repository.UniTrxFee
+	for i, trxTime := range []uint64{1800, 5400} {
+		fee := repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: testTrxHash(i), TrxTime: trxTime, GasUsed: 21000, GasPrice: util.WeiFromUint64(1e9)}
+		fee.SetPrice(decimal.NewFromInt(2000), "binance_1m")
+		fees = append(fees, fee)
+	}
+	insertFees(t, repo, fees)
+
+	single, err := service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: testTrxHash(1), Currency: currency.EUR})
+	require.NoError(t, err)
+	require.NotNil(t, single.Conversion)
+	assert.Equal(t, currency.EUR, single.Conversion.Currency)
+	assert.Equal(t, "0.5", single.Conversion.UsdtRate.String())
+	assert.Equal(t, "0.021", single.Conversion.Fee.String())
+
+	// each fee at the rate of its trx time
+	list, err := service.GetTrxFeeList(context.TODO(), &GetTrxFeeListRequest{
+		TrxFeeFilter: repository.TrxFeeFilter{Symbol: "WETH/USDC", EndTime: 7200},
+		Currency:     currency.EUR,
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Conversions, 2)
+	assert.Equal(t, "0.0336", list.Conversions[0].Fee.String())
+	assert.Equal(t, "0.021", list.Conversions[1].Fee.String())
+
+	batch, err := service.GetTrxFeeBatch(context.TODO(), &GetTrxFeeBatchRequest{TrxHashes: []string{testTrxHash(0)}, Currency: currency.USDT})
+	require.NoError(t, err)
+	assert.Equal(t, "0.042", batch.Results[0].Conversion.Fee.String())
+
+	// aggregates at the rate of the middle of their range
+	stats, err := service.GetTrxFeeStats(context.TODO(), &GetTrxFeeStatsRequest{Symbol: "WETH/USDC", EndTime: 7200, Currency: currency.EUR})
+	require.NoError(t, err)
+	assert.Equal(t, "0.5", stats.Conversion.UsdtRate.String())
+	assert.Equal(t, "0.042", stats.Conversion.Fee.Sum.String())
+	assert.Equal(t, "0.021", stats.Conversion.Fee.Median.String())
+
+	series, err := service.GetTrxFeeSeries(context.TODO(), &GetTrxFeeSeriesRequest{Symbol: "WETH/USDC", EndTime: 7199, Interval: IntervalHour, Currency: currency.EUR})
+	require.NoError(t, err)
+	require.Len(t, series.Buckets, 2)
+	assert.Equal(t, "0.0336", series.Buckets[0].Conversion.Fee.Sum.String())
+	assert.Equal(t, "0.021", series.Buckets[1].Conversion.Fee.Sum.String())
+
+	// fees are only in USDT and ETH unless a currency is requested
+	list, err = service.GetTrxFeeList(context.TODO(), &GetTrxFeeListRequest{TrxFeeFilter: repository.TrxFeeFilter{Symbol: "WETH/USDC", EndTime: 7200}})
+	require.NoError(t, err)
+	assert.Nil(t, list.Conversions)
+}
+
+func TestUnconvertibleCurrenciesAreRejected(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(nil, nil, repo, nil)
+	fee := repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: testTrxHash(1), TrxTime: 100}
+	insertFees(t, repo, []repository.UniTrxFee{fee})
+
+	// unsupported, and supported without a configured rate source
+	for _, cur := range []string{"JPY", currency.GBP} {
+		_, err := service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: testTrxHash(1), Currency: cur})
+		assert.ErrorIs(t, err, ErrInvalidArgument, cur)
+		_, err = service.GetTrxFeeStats(context.TODO(), &GetTrxFeeStatsRequest{Symbol: "WETH/USDC", EndTime: 200, Currency: cur})
+		assert.ErrorIs(t, err, ErrInvalidArgument, cur)
+	}
+
+	// a fee that can't be converted only fails its batch result
+	batch, err := service.GetTrxFeeBatch(context.TODO(), &GetTrxFeeBatchRequest{TrxHashes: []string{testTrxHash(1)}, Currency: currency.GBP})
+	require.NoError(t, err)
+	assert.ErrorIs(t, batch.Results[0].Err, ErrInvalidArgument)
+	assert.Nil(t, batch.Results[0].Fee)
+}
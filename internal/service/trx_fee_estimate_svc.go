@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+)
+
+// MaxEstimateWindow is the longest period, in seconds, gas prices are observed
+// over to estimate a fee at one of their percentiles
+const MaxEstimateWindow = 86400
+
+type EstimateFeeRequest struct {
+	GasUsed uint64
+	// in wei, nil to take GasPricePercentile of the observed gas prices
+	GasPrice *util.Wei
+	// nearest-rank percentile, between 1 and 100, of the gas prices of the
+	// fees of Symbol in [Timestamp-Window, Timestamp]
+	GasPricePercentile int
+	Symbol             string
+	// in seconds, at most MaxEstimateWindow
+	Window int64
+	// unix timestamp the fee is estimated at, not in the future
+	Timestamp int64
+	// one of currency.Supported to also convert the fee to, none if empty
+	Currency string
+}
+
+// ObservedGasPrices tells which fees the gas price of an estimate was taken from
+type ObservedGasPrices struct {
+	Symbol    string `json:"symbol"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	// number of fees in the period
+	Count      int64 `json:"count"`
+	Percentile int   `json:"percentile"`
+}
+
+type EstimateFeeResponse struct {
+	GasUsed uint64 `json:"gas_used"`
+	// in wei, the requested one or the percentile of the observed ones
+	GasPrice util.Wei `json:"gas_price" swaggertype:"string"`
+	// only if the gas price is a percentile of the observed ones
+	ObservedGasPrices *ObservedGasPrices `json:"observed_gas_prices,omitempty"`
+	Timestamp         int64              `json:"timestamp"`
+	FeeWei            util.Wei           `json:"fee_wei" swaggertype:"string"`
+	FeeEth            decimal.Decimal    `json:"fee_eth" swaggertype:"string"`
+	// in USDT, the USD stablecoin fees are priced in
+	FeeUsd decimal.Decimal `json:"fee_usd" swaggertype:"string"`
+	// ETH price in USDT the fee was converted with
+	EthUsdtPrice decimal.Decimal `json:"eth_usdt_price" swaggertype:"string"`
+	// like binance_1m
+	PriceSource string `json:"price_source"`
+	// how the price was computed from its source
+	PriceMethod string `json:"price_method"`
+	// only if a currency is requested
+	Conversion *FeeConversion `json:"conversion,omitempty"`
+}
+
+// EstimateFee prices gas at a past time the way fees computed on demand are
+// priced, with either the given gas price or a percentile of the gas prices of
+// the fees stored before that time
+func (c *trxFeeService) EstimateFee(ctx context.Context, req *EstimateFeeRequest) (*EstimateFeeResponse, error) {
+	if req == nil {
+		return nil, errors.New("nil req")
+	}
+	if err := validateEstimateFeeRequest(req); err != nil {
+		return nil, err
+	}
+
+	resp := &EstimateFeeResponse{GasUsed: req.GasUsed, Timestamp: req.Timestamp}
+	if req.GasPrice != nil {
+		resp.GasPrice = *req.GasPrice
+	} else {
+		observed := &ObservedGasPrices{
+			Symbol:     req.Symbol,
+			StartTime:  req.Timestamp - req.Window,
+			EndTime:    req.Timestamp,
+			Percentile: req.GasPricePercentile,
+		}
+		// ranked by the database, which returns the percentile without the fees
+		percentile, err := c.repo.GetGasPricePercentile(repository.TrxFeeFilter{
+			Symbol:    observed.Symbol,
+			StartTime: observed.StartTime,
+			EndTime:   observed.EndTime,
+		}, req.GasPricePercentile)
+		if err != nil {
+			return nil, err
+		}
+		if percentile.Count == 0 {
+			return nil, fmt.Errorf("%w: no %s fee between %d and %d to take the gas price from, use a longer window or a gas price",
+				ErrInvalidArgument, observed.Symbol, observed.StartTime, observed.EndTime)
+		}
+		observed.Count = percentile.Count
+		resp.GasPrice = percentile.GasPrice
+		resp.ObservedGasPrices = observed
+	}
+
+	price, source, err := c.ethPriceAt(req.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	resp.FeeWei = util.CalculateFeeInWei(resp.GasUsed, resp.GasPrice)
+	resp.FeeEth = util.CalculateFeeInETH(resp.GasUsed, resp.GasPrice)
+	resp.FeeUsd = resp.FeeEth.Mul(price)
+	resp.EthUsdtPrice = price
+	resp.PriceSource = source
+	resp.PriceMethod = priceMethod(source)
+
+	if req.Currency != "" {
+		amount, rate, err := c.converter.FromETH(resp.FeeEth, price, req.Currency, req.Timestamp)
+		if err != nil {
+			return nil, conversionError(err)
+		}
+		resp.Conversion = &FeeConversion{Currency: req.Currency, UsdtRate: rate, Fee: amount}
+	}
+	return resp, nil
+}
+
+func validateEstimateFeeRequest(req *EstimateFeeRequest) error {
+	if req.GasUsed == 0 {
+		return fmt.Errorf("%w: gas_used must be positive", ErrInvalidArgument)
+	}
+	if (req.GasPrice == nil) == (req.GasPricePercentile == 0) {
+		return fmt.Errorf("%w: either gas_price or gas_price_percentile is required", ErrInvalidArgument)
+	}
+	if req.GasPrice == nil {
+		if req.GasPricePercentile < 1 || req.GasPricePercentile > 100 {
+			return fmt.Errorf("%w: gas_price_percentile must be between 1 and 100", ErrInvalidArgument)
+		}
+		if req.Window < 1 || req.Window > MaxEstimateWindow {
+			return fmt.Errorf("%w: window must be between 1 and %d seconds", ErrInvalidArgument, MaxEstimateWindow)
+		}
+	}
+	if req.Timestamp <= 0 || req.Timestamp > time.Now().Unix() {
+		return fmt.Errorf("%w: timestamp must be positive and not in the future", ErrInvalidArgument)
+	}
+	return validateCurrency(req.Currency)
+}
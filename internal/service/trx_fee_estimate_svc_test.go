@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	mock_components "github.com/jaime1129/fedex/mock/components"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateFee(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(nil, mockBnPriceCli, repo, nil)
+
+	// gas prices of 1 to 10 gwei in the hour before 10000, and one of 100 gwei before it
+	var fees []repository.UniTrxFee
+	for i := 1; i <= 10; i++ {
+		fees = append(fees, repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: testTrxHash(i), TrxTime: uint64(10000 - i*60), GasPrice: util.WeiFromUint64(uint64(i) * 1e9)})
+	}
+	fees = append(fees, repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: testTrxHash(11), TrxTime: 1000, GasPrice: util.WeiFromUint64(100e9)})
+	insertFees(t, repo, fees)
+
+	// priced like fees looked up on demand
+	mockBnPriceCli.EXPECT().QueryETHPrice(int64(10000-60), int64(10000+60), "1m").Return(decimal.NewFromInt(2000), nil).Times(2)
+
+	gasPrice := util.WeiFromGwei(decimal.NewFromInt(20))
+	resp, err := service.EstimateFee(context.TODO(), &EstimateFeeRequest{GasUsed: 100000, GasPrice: &gasPrice, Timestamp: 10000})
+	require.NoError(t, err)
+	assert.Equal(t, "2000000000000000", resp.FeeWei.String())
+	assert.Equal(t, "0.002", resp.FeeEth.String())
+	assert.Equal(t, "4", resp.FeeUsd.String())
+	assert.Equal(t, "binance_1m", resp.PriceSource)
+	assert.Nil(t, resp.ObservedGasPrices)
+
+	resp, err = service.EstimateFee(context.TODO(), &EstimateFeeRequest{GasUsed: 100000, GasPricePercentile: 90, Symbol: "WETH/USDC", Window: 3600, Timestamp: 10000})
+	require.NoError(t, err)
+	assert.Equal(t, "9000000000", resp.GasPrice.String())
+	assert.Equal(t, "1.8", resp.FeeUsd.String())
+	assert.Equal(t, &ObservedGasPrices{Symbol: "WETH/USDC", StartTime: 6400, EndTime: 10000, Count: 10, Percentile: 90}, resp.ObservedGasPrices)
+}
+
+func TestEstimateFeeRejectsInvalidArguments(t *testing.T) {
+	service := NewTrxService(nil, nil, repository.NewMemoryRepository(), nil)
+
+	gasPrice := util.WeiFromUint64(1e9)
+	for name, req := range map[string]*EstimateFeeRequest{
+		"no gas":                  {GasPrice: &gasPrice, Timestamp: 100},
+		"no gas price":            {GasUsed: 21000, Timestamp: 100},
+		"both gas prices":         {GasUsed: 21000, GasPrice: &gasPrice, GasPricePercentile: 50, Window: 3600, Timestamp: 100},
+		"percentile out of range": {GasUsed: 21000, GasPricePercentile: 101, Window: 3600, Timestamp: 100},
+		"window too long":         {GasUsed: 21000, GasPricePercentile: 50, Window: MaxEstimateWindow + 1, Timestamp: 100},
+		"future":                  {GasUsed: 21000, GasPrice: &gasPrice, Timestamp: time.Now().Add(time.Hour).Unix()},
+		"unsupported currency":    {GasUsed: 21000, GasPrice: &gasPrice, Timestamp: 100, Currency: "JPY"},
+		"no observed fee":         {GasUsed: 21000, GasPricePercentile: 50, Symbol: "WETH/USDC", Window: 3600, Timestamp: 100},
+	} {
+		_, err := service.EstimateFee(context.TODO(), req)
+		assert.ErrorIs(t, err, ErrInvalidArgument, name)
+	}
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// FeeSummary describes fees in one currency
+type FeeSummary struct {
+	Sum  decimal.Decimal `json:"sum" swaggertype:"string"`
+	Min  decimal.Decimal `json:"min" swaggertype:"string"`
+	Max  decimal.Decimal `json:"max" swaggertype:"string"`
+	Mean decimal.Decimal `json:"mean" swaggertype:"string"`
+}
+
+// FeeDistribution is a FeeSummary with nearest-rank percentiles
+type FeeDistribution struct {
+	FeeSummary
+	Median decimal.Decimal `json:"median" swaggertype:"string"`
+	P90    decimal.Decimal `json:"p90" swaggertype:"string"`
+	P99    decimal.Decimal `json:"p99" swaggertype:"string"`
+}
+
+// FeeAggregate summarizes a set of fees, it is all zero for an empty set
+type FeeAggregate struct {
+	Count   int64      `json:"count"`
+	FeeUsdt FeeSummary `json:"fee_usdt"`
+	FeeEth  FeeSummary `json:"fee_eth"`
+	// in wei
+	AvgGasPrice decimal.Decimal `json:"avg_gas_price" swaggertype:"string"`
+	AvgGasUsed  decimal.Decimal `json:"avg_gas_used" swaggertype:"string"`
+}
+
+// decimal places of the averages, fees are stored with 18 of them
+const (
+	feeMeanPlaces    = 18
+	gasUsedAvgPlaces = 2
+)
+
+func newFeeAggregate(a *repository.TrxFeeAggregate) FeeAggregate {
+	res := FeeAggregate{
+		Count:   a.Count,
+		FeeUsdt: FeeSummary{Sum: a.SumFeeUsdt, Min: a.MinFeeUsdt, Max: a.MaxFeeUsdt},
+		FeeEth:  FeeSummary{Sum: a.SumFeeEth, Min: a.MinFeeEth, Max: a.MaxFeeEth},
+	}
+	if a.Count > 0 {
+		count := decimal.NewFromInt(a.Count)
+		res.FeeUsdt.Mean = a.SumFeeUsdt.DivRound(count, feeMeanPlaces)
+		res.FeeEth.Mean = a.SumFeeEth.DivRound(count, feeMeanPlaces)
+		res.AvgGasPrice = a.SumGasPrice.DivRound(count, 0)
+		res.AvgGasUsed = a.SumGasUsed.DivRound(count, gasUsedAvgPlaces)
+	}
+	return res
+}
+
+type GetTrxFeeStatsRequest struct {
+	Symbol    string
+	StartTime int64
+	EndTime   int64
+	// one of currency.Supported to also convert the USDT fees to, none if empty
+	Currency string
+}
+
+type GetTrxFeeStatsResponse struct {
+	Count   int64           `json:"count"`
+	FeeUsdt FeeDistribution `json:"fee_usdt"`
+	FeeEth  FeeDistribution `json:"fee_eth"`
+	// in wei
+	AvgGasPrice decimal.Decimal `json:"avg_gas_price" swaggertype:"string"`
+	AvgGasUsed  decimal.Decimal `json:"avg_gas_used" swaggertype:"string"`
+	// percentiles are estimated from rollups, within 1%
+	Approximate bool `json:"approximate"`
+	// only if a currency is requested
+	Conversion *FeeDistributionConversion `json:"conversion,omitempty"`
+}
+
+func (c *trxFeeService) GetTrxFeeStats(ctx context.Context, req *GetTrxFeeStatsRequest) (*GetTrxFeeStatsResponse, error) {
+	if req == nil {
+		return nil, errors.New("nil req")
+	}
+	if req.StartTime > req.EndTime {
+		return nil, fmt.Errorf("%w: start_time is after end_time", ErrInvalidArgument)
+	}
+	if err := validateCurrency(req.Currency); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.getTrxFeeStats(req)
+	if err != nil {
+		return nil, err
+	}
+	// fees are aggregated in USDT, so they are converted at a single rate, the
+	// one of the middle of the range
+	rate, ok, err := c.usdtRate(req.Currency, req.StartTime+(req.EndTime-req.StartTime)/2)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		resp.Conversion = &FeeDistributionConversion{Currency: req.Currency, UsdtRate: rate, Fee: convertDistribution(resp.FeeUsdt, rate)}
+	}
+	return resp, nil
+}
+
+func (c *trxFeeService) getTrxFeeStats(req *GetTrxFeeStatsRequest) (*GetTrxFeeStatsResponse, error) {
+	filter := repository.TrxFeeFilter{
+		Symbol:    req.Symbol,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	}
+	spans, raw, err := c.planRollups(req.Symbol, req.StartTime, req.EndTime, repository.RollupDay)
+	if err != nil {
+		return nil, err
+	}
+	if len(spans) == 0 {
+		stats, err := c.repo.GetTrxFeeStats(filter)
+		if err != nil {
+			return nil, err
+		}
+		return newGetTrxFeeStatsResponse(&stats.TrxFeeAggregate, &stats.FeeUsdt, &stats.FeeEth, false), nil
+	}
+
+	// whole hours and days are read from the rollups, the rest of the range from the fees
+	var total repository.TrxFeeRollup
+	for _, span := range spans {
+		rollups, err := c.repo.ListTrxFeeRollups(span.period, req.Symbol, span.start, span.end)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rollups {
+			total.Merge(&rollups[i])
+		}
+	}
+	for _, r := range raw {
+		filter.StartTime, filter.EndTime = r[0], r[1]
+		if err := forEachTrxFee(c.repo, filter, total.Add); err != nil {
+			return nil, err
+		}
+	}
+	usdt := repository.TrxFeePercentiles{
+		Median: total.SketchUsdt.Percentile(50),
+		P90:    total.SketchUsdt.Percentile(90),
+		P99:    total.SketchUsdt.Percentile(99),
+	}
+	eth := repository.TrxFeePercentiles{
+		Median: total.SketchEth.Percentile(50),
+		P90:    total.SketchEth.Percentile(90),
+		P99:    total.SketchEth.Percentile(99),
+	}
+	return newGetTrxFeeStatsResponse(&total.TrxFeeAggregate, &usdt, &eth, true), nil
+}
+
+func newGetTrxFeeStatsResponse(a *repository.TrxFeeAggregate, usdt *repository.TrxFeePercentiles, eth *repository.TrxFeePercentiles, approximate bool) *GetTrxFeeStatsResponse {
+	agg := newFeeAggregate(a)
+	return &GetTrxFeeStatsResponse{
+		Count: agg.Count,
+		FeeUsdt: FeeDistribution{
+			FeeSummary: agg.FeeUsdt,
+			Median:     usdt.Median,
+			P90:        usdt.P90,
+			P99:        usdt.P99,
+		},
+		FeeEth: FeeDistribution{
+			FeeSummary: agg.FeeEth,
+			Median:     eth.Median,
+			P90:        eth.P90,
+			P99:        eth.P99,
+		},
+		AvgGasPrice: agg.AvgGasPrice,
+		AvgGasUsed:  agg.AvgGasUsed,
+		Approximate: approximate,
+	}
+}
+
+// bucket sizes of a fee series
+const (
+	IntervalMinute = "minute"
+	IntervalHour   = "hour"
+	IntervalDay    = "day"
+	IntervalWeek   = "week"
+)
+
+const maxSeriesBuckets = 1000
+
+type GetTrxFeeSeriesRequest struct {
+	Symbol    string
+	StartTime int64
+	EndTime   int64
+	// IntervalMinute, IntervalHour, IntervalDay or IntervalWeek
+	Interval string
+	// IANA name of the timezone buckets are aligned in, UTC if empty
+	Timezone string
+	// one of currency.Supported to also convert the USDT fees to, none if empty
+	Currency string
+}
+
+type FeeSeriesBucket struct {
+	// unix timestamp of the bucket start
+	Start int64 `json:"start"`
+	// bucket start in RFC 3339, in the requested timezone
+	Time string `json:"time"`
+	FeeAggregate
+	// only if a currency is requested
+	Conversion *FeeSummaryConversion `json:"conversion,omitempty"`
+}
+
+type GetTrxFeeSeriesResponse struct {
+	Interval string `json:"interval"`
+	Timezone string `json:"timezone"`
+	// every bucket of the time range, including empty ones
+	Buckets []FeeSeriesBucket `json:"buckets"`
+}
+
+func (c *trxFeeService) GetTrxFeeSeries(ctx context.Context, req *GetTrxFeeSeriesRequest) (*GetTrxFeeSeriesResponse, error) {
+	if req == nil {
+		return nil, errors.New("nil req")
+	}
+	if req.StartTime > req.EndTime {
+		return nil, fmt.Errorf("%w: start_time is after end_time", ErrInvalidArgument)
+	}
+	if err := validateCurrency(req.Currency); err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %s", ErrInvalidArgument, req.Timezone)
+	}
+	bounds, err := seriesBounds(req.StartTime, req.EndTime, req.Interval, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// fees are aggregated in groups of a fixed size, the largest one that
+	// doesn't cross any bound, which are merged into the buckets
+	step := int64(1)
+	for _, size := range []int64{86400, 3600, 900, 60} {
+		if boundsAligned(bounds, size) {
+			step = size
+			break
+		}
+	}
+	// rollups make groups of their period, they are used if it fits in a group
+	spans, raw, err := c.planRollups(req.Symbol, req.StartTime, req.EndTime, repository.RollupPeriod(step))
+	if err != nil {
+		return nil, err
+	}
+	var groups []repository.TrxFeeBucket
+	for _, span := range spans {
+		rollups, err := c.repo.ListTrxFeeRollups(span.period, req.Symbol, span.start, span.end)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rollups {
+			groups = append(groups, repository.TrxFeeBucket{Start: rollups[i].Start, TrxFeeAggregate: rollups[i].TrxFeeAggregate})
+		}
+	}
+	for _, r := range raw {
+		rawGroups, err := c.repo.GetTrxFeeSeries(repository.TrxFeeFilter{
+			Symbol:    req.Symbol,
+			StartTime: r[0],
+			EndTime:   r[1],
+		}, step)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, rawGroups...)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Start < groups[j].Start })
+
+	aggs := make([]repository.TrxFeeAggregate, len(bounds)-1)
+	k := 0
+	for _, g := range groups {
+		for k < len(aggs)-1 && g.Start >= bounds[k+1].Unix() {
+			k++
+		}
+		aggs[k].Merge(g.TrxFeeAggregate)
+	}
+
+	resp := &GetTrxFeeSeriesResponse{
+		Interval: req.Interval,
+		Timezone: loc.String(),
+		Buckets:  make([]FeeSeriesBucket, len(aggs)),
+	}
+	for i := range aggs {
+		resp.Buckets[i] = FeeSeriesBucket{
+			Start:        bounds[i].Unix(),
+			Time:         bounds[i].Format(time.RFC3339),
+			FeeAggregate: newFeeAggregate(&aggs[i]),
+		}
+		// at the rate of the middle of the part of the bucket in the time range
+		start, end := max(bounds[i].Unix(), req.StartTime), min(bounds[i+1].Unix()-1, req.EndTime)
+		rate, ok, err := c.usdtRate(req.Currency, start+(end-start)/2)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			resp.Buckets[i].Conversion = &FeeSummaryConversion{Currency: req.Currency, UsdtRate: rate, Fee: convertSummary(resp.Buckets[i].FeeUsdt, rate)}
+		}
+	}
+	return resp, nil
+}
+
+// rollupSpan is the range [start, end) read from the rollups of period
+type rollupSpan struct {
+	period repository.RollupPeriod
+	start  int64
+	end    int64
+}
+
+// planRollups splits the range [start, end] into spans of whole rollups of
+// at most maxPeriod that are up to date, and the inclusive ranges left to
+// read from the fees
+func (c *trxFeeService) planRollups(symbol string, start int64, end int64, maxPeriod repository.RollupPeriod) (spans []rollupSpan, raw [][2]int64, err error) {
+	hour := repository.RollupHour
+	var hoursStart, hoursEnd int64
+	if c.rollups != nil && maxPeriod >= hour && start >= 0 {
+		hoursStart = hour.Truncate(start + int64(hour) - 1)
+		hoursEnd = hour.Truncate(min(end+1, c.rollups.CoveredUntil()))
+	}
+	if hoursEnd <= hoursStart {
+		return nil, [][2]int64{{start, end}}, nil
+	}
+	// the hours of fees written since the last update are read from the fees
+	stale, err := c.rollups.StaleHours(symbol, hoursStart, hoursEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addRaw := func(from int64, to int64) {
+		if n := len(raw); n > 0 && raw[n-1][1]+1 == from {
+			raw[n-1][1] = to
+			return
+		}
+		raw = append(raw, [2]int64{from, to})
+	}
+	if start < hoursStart {
+		addRaw(start, hoursStart-1)
+	}
+	from := hoursStart
+	for _, h := range append(stale, hoursEnd) {
+		spans = append(spans, rollupSpans(from, h, maxPeriod)...)
+		if h < hoursEnd {
+			addRaw(h, h+int64(hour)-1)
+		}
+		from = h + int64(hour)
+	}
+	if hoursEnd <= end {
+		addRaw(hoursEnd, end)
+	}
+	if len(spans) == 0 {
+		return nil, [][2]int64{{start, end}}, nil
+	}
+	return spans, raw, nil
+}
+
+// rollupSpans splits the whole hours [start, end) into spans of hours and
+// days, days if maxPeriod allows
+func rollupSpans(start int64, end int64, maxPeriod repository.RollupPeriod) []rollupSpan {
+	hour, day := repository.RollupHour, repository.RollupDay
+	if end <= start {
+		return nil
+	}
+	daysStart, daysEnd := day.Truncate(start+int64(day)-1), day.Truncate(end)
+	if maxPeriod < day || daysEnd <= daysStart {
+		return []rollupSpan{{period: hour, start: start, end: end}}
+	}
+	var spans []rollupSpan
+	if start < daysStart {
+		spans = append(spans, rollupSpan{period: hour, start: start, end: daysStart})
+	}
+	spans = append(spans, rollupSpan{period: day, start: daysStart, end: daysEnd})
+	if daysEnd < end {
+		spans = append(spans, rollupSpan{period: hour, start: daysEnd, end: end})
+	}
+	return spans
+}
+
+// seriesBounds returns the starts of the buckets covering [start, end], followed
+// by the end of the last bucket
+func seriesBounds(start int64, end int64, interval string, loc *time.Location) ([]time.Time, error) {
+	var truncate func(t time.Time) time.Time
+	var next func(t time.Time) time.Time
+	switch interval {
+	case IntervalMinute, IntervalHour:
+		width := int64(60)
+		if interval == IntervalHour {
+			width = 3600
+		}
+		// aligned on the local clock, whose offset may not be a whole number of hours
+		truncate = func(t time.Time) time.Time {
+			_, offset := t.Zone()
+			return t.Add(-time.Duration(((t.Unix()+int64(offset))%width+width)%width) * time.Second)
+		}
+		next = func(t time.Time) time.Time {
+			return truncate(t.Add(time.Duration(width) * time.Second))
+		}
+	case IntervalDay, IntervalWeek:
+		days := 1
+		if interval == IntervalWeek {
+			days = 7
+		}
+		// days are not always 24 hours long, so they are counted on the calendar
+		truncate = func(t time.Time) time.Time {
+			y, m, d := t.Date()
+			if interval == IntervalWeek {
+				// weeks start on monday
+				d -= (int(t.Weekday()) + 6) % 7
+			}
+			return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		}
+		next = func(t time.Time) time.Time {
+			y, m, d := t.Date()
+			return time.Date(y, m, d+days, 0, 0, 0, 0, t.Location())
+		}
+	default:
+		return nil, fmt.Errorf("%w: interval must be %s, %s, %s or %s", ErrInvalidArgument,
+			IntervalMinute, IntervalHour, IntervalDay, IntervalWeek)
+	}
+
+	endTime := time.Unix(end, 0)
+	bounds := []time.Time{truncate(time.Unix(start, 0).In(loc))}
+	for !bounds[len(bounds)-1].After(endTime) {
+		if len(bounds) > maxSeriesBuckets {
+			return nil, fmt.Errorf("%w: more than %d buckets, use a shorter time range or a larger interval",
+				ErrInvalidArgument, maxSeriesBuckets)
+		}
+		bounds = append(bounds, next(bounds[len(bounds)-1]))
+	}
+	return bounds, nil
+}
+
+// boundsAligned reports whether every bound is a multiple of size seconds
+func boundsAligned(bounds []time.Time, size int64) bool {
+	for _, b := range bounds {
+		if b.Unix()%size != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTrxFeeStats(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(nil, nil, repo, nil)
+
+	// fees are 0.021, 0.042 and 0.064 USDT
+	var fees []repository.UniTrxFee
+	for i, gasUsed := range []uint64{21000, 42000, 64000} {
+		fee := repository.UniTrxFee{
+			Symbol:   "WETH/USDC",
+			TrxHash:  fmt.Sprintf("0x%d", i),
+			TrxTime:  uint64(10 * (i + 1)),
+			GasUsed:  gasUsed,
+			GasPrice: util.WeiFromGwei(decimal.NewFromInt(1)),
+		}
+		fee.SetPrice(decimal.NewFromInt(1000), "binance_1m")
+		fees = append(fees, fee)
+	}
+	insertFees(t, repo, fees)
+
+	resp, err := service.GetTrxFeeStats(context.TODO(), &GetTrxFeeStatsRequest{Symbol: "WETH/USDC", EndTime: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), resp.Count)
+	assert.Equal(t, "0.127", resp.FeeUsdt.Sum.String())
+	assert.Equal(t, "0.021", resp.FeeUsdt.Min.String())
+	assert.Equal(t, "0.064", resp.FeeUsdt.Max.String())
+	assert.Equal(t, "0.042333333333333333", resp.FeeUsdt.Mean.String())
+	assert.Equal(t, "0.042", resp.FeeUsdt.Median.String())
+	assert.Equal(t, "0.064", resp.FeeUsdt.P90.String())
+	assert.Equal(t, "0.000042", resp.FeeEth.Median.String())
+	assert.Equal(t, "1000000000", resp.AvgGasPrice.String())
+	assert.Equal(t, "42333.33", resp.AvgGasUsed.String())
+
+	resp, err = service.GetTrxFeeStats(context.TODO(), &GetTrxFeeStatsRequest{Symbol: "WETH/USDC", StartTime: 15, EndTime: 25})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), resp.Count)
+	assert.Equal(t, "0.042", resp.FeeUsdt.Mean.String())
+
+	resp, err = service.GetTrxFeeStats(context.TODO(), &GetTrxFeeStatsRequest{Symbol: "WETH/USDT", EndTime: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), resp.Count)
+	assert.True(t, resp.FeeUsdt.Mean.IsZero())
+
+	_, err = service.GetTrxFeeStats(context.TODO(), &GetTrxFeeStatsRequest{Symbol: "WETH/USDC", StartTime: 100, EndTime: 10})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+// seriesFee is a 0.021 USDT fee at trxTime
+func seriesFee(trxTime time.Time) repository.UniTrxFee {
+	fee := repository.UniTrxFee{
+		Symbol:   "WETH/USDC",
+		TrxHash:  fmt.Sprintf("0x%d", trxTime.Unix()),
+		TrxTime:  uint64(trxTime.Unix()),
+		GasUsed:  21000,
+		GasPrice: util.WeiFromGwei(decimal.NewFromInt(1)),
+	}
+	fee.SetPrice(decimal.NewFromInt(1000), "binance_1m")
+	return fee
+}
+
+func bucketCounts(resp *GetTrxFeeSeriesResponse) []int64 {
+	counts := make([]int64, len(resp.Buckets))
+	for i, b := range resp.Buckets {
+		counts[i] = b.Count
+	}
+	return counts
+}
+
+func TestGetTrxFeeSeriesHourInHalfHourTimezone(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(nil, nil, repo, nil)
+
+	// Asia/Kolkata is UTC+5:30, its hours start at half past UTC hours
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	insertFees(t, repo, []repository.UniTrxFee{
+		seriesFee(start.Add(10 * time.Minute)),
+		seriesFee(start.Add(50 * time.Minute)),
+		seriesFee(start.Add(150 * time.Minute)),
+		seriesFee(start.Add(160 * time.Minute)),
+	})
+
+	resp, err := service.GetTrxFeeSeries(context.TODO(), &GetTrxFeeSeriesRequest{
+		Symbol:    "WETH/USDC",
+		StartTime: start.Unix(),
+		EndTime:   start.Add(3 * time.Hour).Unix(),
+		Interval:  IntervalHour,
+		Timezone:  "Asia/Kolkata",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Kolkata", resp.Timezone)
+	assert.Equal(t, []int64{1, 1, 0, 2}, bucketCounts(resp))
+	assert.Equal(t, "2024-01-01T05:00:00+05:30", resp.Buckets[0].Time)
+	assert.Equal(t, start.Add(-30*time.Minute).Unix(), resp.Buckets[0].Start)
+	assert.Equal(t, "0.042", resp.Buckets[3].FeeUsdt.Sum.String())
+	assert.Equal(t, "0.021", resp.Buckets[3].FeeUsdt.Mean.String())
+	assert.True(t, resp.Buckets[2].FeeUsdt.Sum.IsZero())
+}
+
+func TestGetTrxFeeSeriesDayAcrossDST(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(nil, nil, repo, nil)
+
+	// New York moves to daylight saving time on 2024-03-10, which lasts 23 hours
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	insertFees(t, repo, []repository.UniTrxFee{
+		seriesFee(time.Date(2024, 3, 10, 23, 30, 0, 0, ny)),
+		seriesFee(time.Date(2024, 3, 11, 0, 30, 0, 0, ny)),
+	})
+
+	resp, err := service.GetTrxFeeSeries(context.TODO(), &GetTrxFeeSeriesRequest{
+		Symbol:    "WETH/USDC",
+		StartTime: time.Date(2024, 3, 9, 12, 0, 0, 0, ny).Unix(),
+		EndTime:   time.Date(2024, 3, 11, 12, 0, 0, 0, ny).Unix(),
+		Interval:  IntervalDay,
+		Timezone:  "America/New_York",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 1, 1}, bucketCounts(resp))
+	assert.Equal(t, "2024-03-10T00:00:00-05:00", resp.Buckets[1].Time)
+	assert.Equal(t, "2024-03-11T00:00:00-04:00", resp.Buckets[2].Time)
+	assert.Equal(t, int64(23*3600), resp.Buckets[2].Start-resp.Buckets[1].Start)
+}
+
+func TestGetTrxFeeSeriesWeekStartsOnMonday(t *testing.T) {
+	service := NewTrxService(nil, nil, repository.NewMemoryRepository(), nil)
+
+	// 2024-01-03 is a wednesday
+	start := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	resp, err := service.GetTrxFeeSeries(context.TODO(), &GetTrxFeeSeriesRequest{
+		Symbol:    "WETH/USDC",
+		StartTime: start.Unix(),
+		EndTime:   start.AddDate(0, 0, 7).Unix(),
+		Interval:  IntervalWeek,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "UTC", resp.Timezone)
+	require.Len(t, resp.Buckets, 2)
+	assert.Equal(t, "2024-01-01T00:00:00Z", resp.Buckets[0].Time)
+	assert.Equal(t, "2024-01-08T00:00:00Z", resp.Buckets[1].Time)
+}
+
+func TestGetTrxFeeSeriesRejectsInvalidArguments(t *testing.T) {
+	service := NewTrxService(nil, nil, repository.NewMemoryRepository(), nil)
+
+	for name, req := range map[string]*GetTrxFeeSeriesRequest{
+		"unknown interval": {EndTime: 100, Interval: "month"},
+		"unknown timezone": {EndTime: 100, Interval: IntervalHour, Timezone: "Mars/Olympus_Mons"},
+		"inverted range":   {StartTime: 100, EndTime: 10, Interval: IntervalHour},
+		"too many buckets": {EndTime: 2 * 24 * 3600, Interval: IntervalMinute},
+	} {
+		_, err := service.GetTrxFeeSeries(context.TODO(), req)
+		assert.ErrorIs(t, err, ErrInvalidArgument, name)
+	}
+}
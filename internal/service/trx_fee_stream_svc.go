@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jaime1129/fedex/internal/eventbus"
+	"github.com/jaime1129/fedex/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// maximum number of fees read from db per catch-up query
+	streamPageSize = 200
+	// fees are also polled periodically in case an ingestion event was missed
+	streamPollInterval = 15 * time.Second
+)
+
+type TrxFeeStreamService interface {
+	// StreamTrxFee calls send for every fee committed after req.LastEventID
+	// that matches the filters, until ctx is done or send fails. Fees are sent
+	// once per stream, but a resumed stream sends the fees inserted up to
+	// trxFeeReplayWindow before the last event again.
+	StreamTrxFee(ctx context.Context, req *StreamTrxFeeRequest, send func(fee *repository.UniTrxFee) error) error
+}
+
+type trxFeeStreamService struct {
+	repo repository.Repository
+	bus  eventbus.Bus
+}
+
+func NewTrxFeeStreamService(
+	repo repository.Repository,
+	bus eventbus.Bus,
+) TrxFeeStreamService {
+	return &trxFeeStreamService{
+		repo: repo,
+		bus:  bus,
+	}
+}
+
+type StreamTrxFeeRequest struct {
+	Symbol      string
+	MinFeeUsdt  decimal.Decimal
+	FromAddress string
+	// resume after this fee id, from the first fee if 0 and only new fees
+	// are streamed if nil or unknown
+	LastEventID *uint64
+}
+
+func (s *trxFeeStreamService) StreamTrxFee(ctx context.Context, req *StreamTrxFeeRequest, send func(fee *repository.UniTrxFee) error) error {
+	if req == nil {
+		return errors.New("nil req")
+	}
+
+	// ingestion events only wake the stream up, fees are always read from db so
+	// that ids are known and resuming from the last event id is consistent.
+	// One buffered wake-up is enough, further ones can be dropped.
+	sub := s.bus.Subscribe("trxfee-stream", eventbus.SubscribeOptions{
+		BufferSize: 1,
+		Policy:     eventbus.DropNewest,
+		Types:      []eventbus.EventType{eventbus.EventFeeIngested},
+	})
+	defer sub.Unsubscribe()
+
+	filter := repository.StreamFilter{
+		Symbol:      req.Symbol,
+		MinFeeUsdt:  req.MinFeeUsdt,
+		FromAddress: req.FromAddress,
+	}
+	cursor, err := s.startCursor(req.LastEventID, filter)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+	for {
+		if err = s.catchUp(cursor, filter, send); err != nil {
+			return err
+		}
+
+		select {
+		case _, ok := <-sub.Events():
+			if !ok {
+				return errors.New("event bus closed")
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// streamCursor is the position of a stream among the fees in insertion order.
+// Fees inserted up to trxFeeReplayWindow before it are read again in case
+// they were committed late, the ones already sent are remembered to skip them.
+type streamCursor struct {
+	after repository.TrxFeeTimeCursor
+	// insert time of the fees sent in the replay window, by id
+	sent map[uint64]int64
+}
+
+func (c *streamCursor) advance(fee *repository.UniTrxFee) {
+	c.sent[fee.ID] = fee.CreatedAt
+	if fee.CreatedAt > c.after.Time || (fee.CreatedAt == c.after.Time && fee.ID > c.after.ID) {
+		c.after = repository.TrxFeeTimeCursor{Time: fee.CreatedAt, ID: fee.ID}
+	}
+}
+
+func (s *trxFeeStreamService) startCursor(lastEventID *uint64, filter repository.StreamFilter) (*streamCursor, error) {
+	cursor := &streamCursor{sent: make(map[uint64]int64)}
+	if lastEventID != nil && *lastEventID == 0 {
+		return cursor, nil
+	}
+	if lastEventID != nil {
+		fee, err := s.repo.GetTrxFeeByID(*lastEventID)
+		if err != nil {
+			return nil, err
+		}
+		if fee != nil {
+			// what the client got before the last event is unknown
+			cursor.advance(fee)
+			return cursor, nil
+		}
+	}
+
+	// only new fees, the ones committed so far are skipped as already sent
+	cursor.after.Time = time.Now().Unix()
+	if err := s.catchUp(cursor, filter, nil); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// catchUp sends every matching fee after the cursor and the fees of the replay
+// window that were not sent yet, then forgets the fees out of the window. A nil
+// send only marks them as sent.
+func (s *trxFeeStreamService) catchUp(cursor *streamCursor, filter repository.StreamFilter, send func(fee *repository.UniTrxFee) error) error {
+	from := repository.TrxFeeTimeCursor{Time: cursor.after.Time - trxFeeReplayWindow}
+	for {
+		fees, err := s.repo.ListTrxFeeCreatedAfter(from, filter, streamPageSize)
+		if err != nil {
+			return err
+		}
+		for i := range fees {
+			from = repository.TrxFeeTimeCursor{Time: fees[i].CreatedAt, ID: fees[i].ID}
+			if _, ok := cursor.sent[fees[i].ID]; ok {
+				continue
+			}
+			if send != nil {
+				if err = send(&fees[i]); err != nil {
+					return err
+				}
+			}
+			cursor.advance(&fees[i])
+		}
+		if len(fees) < streamPageSize {
+			break
+		}
+	}
+
+	for id, createdAt := range cursor.sent {
+		if createdAt < cursor.after.Time-trxFeeReplayWindow {
+			delete(cursor.sent, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jaime1129/fedex/internal/eventbus"
+	"github.com/jaime1129/fedex/internal/repository"
+	mock_repository "github.com/jaime1129/fedex/mock/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamTrxFeeResumesAndFollowsIngestion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	bus := eventbus.New()
+	defer bus.Close()
+	service := NewTrxFeeStreamService(mockRepo, bus)
+
+	lastEventID := uint64(10)
+	filter := repository.StreamFilter{Symbol: "WETH/USDC"}
+	replayFrom := repository.TrxFeeTimeCursor{Time: 1000 - trxFeeReplayWindow}
+	gomock.InOrder(
+		mockRepo.EXPECT().GetTrxFeeByID(uint64(10)).Return(&repository.UniTrxFee{ID: 10, CreatedAt: 1000}, nil),
+		// catch up from the last event, replaying the window before it
+		mockRepo.EXPECT().ListTrxFeeCreatedAfter(replayFrom, filter, streamPageSize).
+			Return([]repository.UniTrxFee{{ID: 10, CreatedAt: 1000}, {ID: 11, CreatedAt: 1000}, {ID: 12, CreatedAt: 1001}}, nil),
+		// woken up by the ingestion event, 9 was committed late
+		mockRepo.EXPECT().ListTrxFeeCreatedAfter(repository.TrxFeeTimeCursor{Time: 1001 - trxFeeReplayWindow}, filter, streamPageSize).
+			Return([]repository.UniTrxFee{{ID: 9, CreatedAt: 999}, {ID: 10, CreatedAt: 1000}, {ID: 11, CreatedAt: 1000}, {ID: 12, CreatedAt: 1001}, {ID: 15, CreatedAt: 1002}}, nil),
+	)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	var sent []uint64
+	done := make(chan error)
+	go func() {
+		done <- service.StreamTrxFee(ctx, &StreamTrxFeeRequest{Symbol: "WETH/USDC", LastEventID: &lastEventID}, func(fee *repository.UniTrxFee) error {
+			sent = append(sent, fee.ID)
+			if fee.ID == 12 {
+				bus.Publish(eventbus.FeeIngested{})
+			}
+			if fee.ID == 15 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop")
+	}
+	assert.Equal(t, []uint64{11, 12, 9, 15}, sent)
+}
+
+func TestStreamTrxFeeStartsFromLatest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	bus := eventbus.New()
+	defer bus.Close()
+	service := NewTrxFeeStreamService(mockRepo, bus)
+
+	// fees committed before the stream started are not sent, even within the replay window
+	now := time.Now().Unix()
+	old := repository.UniTrxFee{ID: 100, CreatedAt: now - 10}
+	ctx, cancel := context.WithCancel(context.TODO())
+	gomock.InOrder(
+		mockRepo.EXPECT().ListTrxFeeCreatedAfter(gomock.Any(), repository.StreamFilter{}, streamPageSize).
+			DoAndReturn(func(after repository.TrxFeeTimeCursor, _ repository.StreamFilter, _ int) ([]repository.UniTrxFee, error) {
+				assert.GreaterOrEqual(t, after.Time, now-trxFeeReplayWindow)
+				return []repository.UniTrxFee{old}, nil
+			}),
+		mockRepo.EXPECT().ListTrxFeeCreatedAfter(gomock.Any(), repository.StreamFilter{}, streamPageSize).
+			Return([]repository.UniTrxFee{old, {ID: 99, CreatedAt: now - 5}}, nil),
+	)
+
+	var sent []uint64
+	err := service.StreamTrxFee(ctx, &StreamTrxFeeRequest{}, func(fee *repository.UniTrxFee) error {
+		sent = append(sent, fee.ID)
+		cancel()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{99}, sent)
+}
+
+func TestStreamTrxFeeFiltersStoredFees(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	bus := eventbus.New()
+	defer bus.Close()
+	service := NewTrxFeeStreamService(repo, bus)
+
+	_, err := repo.BatchInsertUniTrxFee([]repository.UniTrxFee{
+		{Symbol: "WETH/USDC", TrxHash: "0x1", FromAddress: "0xa"},
+		{Symbol: "WETH/USDT", TrxHash: "0x2", FromAddress: "0xa"},
+		{Symbol: "WETH/USDC", TrxHash: "0x3", FromAddress: "0xb"},
+		{Symbol: "WETH/USDC", TrxHash: "0x4", FromAddress: "0xa"},
+	}, repository.ConflictKeepExisting)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	lastEventID := uint64(0)
+	var sent []string
+	err = service.StreamTrxFee(ctx, &StreamTrxFeeRequest{Symbol: "WETH/USDC", FromAddress: "0xA", LastEventID: &lastEventID}, func(fee *repository.UniTrxFee) error {
+		sent = append(sent, fee.TrxHash)
+		if fee.TrxHash == "0x4" {
+			cancel()
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0x1", "0x4"}, sent)
+}
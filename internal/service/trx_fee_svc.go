@@ -2,77 +2,232 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 
 	"github.com/jaime1129/fedex/internal/components"
+	"github.com/jaime1129/fedex/internal/currency"
 	"github.com/jaime1129/fedex/internal/repository"
 	"github.com/jaime1129/fedex/internal/util"
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrInvalidArgument is wrapped by errors caused by invalid request parameters
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// ErrTrxNotFound is returned when etherscan has no receipt for a trx hash
+var ErrTrxNotFound = errors.New("trx not found")
+
 type TrxFeeService interface {
 	GetSingleTrxFee(ctx context.Context, req *GetSingleTrxFeeRequest) (*GetSingleTrxFeeResponse, error)
+	GetTrxFeeBatch(ctx context.Context, req *GetTrxFeeBatchRequest) (*GetTrxFeeBatchResponse, error)
 	GetTrxFeeList(ctx context.Context, req *GetTrxFeeListRequest) (*GetTrxFeeListResponse, error)
+	GetTrxFeeStats(ctx context.Context, req *GetTrxFeeStatsRequest) (*GetTrxFeeStatsResponse, error)
+	GetTrxFeeSeries(ctx context.Context, req *GetTrxFeeSeriesRequest) (*GetTrxFeeSeriesResponse, error)
+	EstimateFee(ctx context.Context, req *EstimateFeeRequest) (*EstimateFeeResponse, error)
 }
 
 type trxFeeService struct {
 	ethScanCli components.EthScanCli
 	bnPriceCli components.BnPriceCli
 	repo       repository.Repository
+	// stats and series are computed from the fees only if nil
+	rollups RollupService
+	// coalesces concurrent lookups of the same trx hash
+	lookups singleflight.Group
+	// converts fees to the requested currencies
+	converter *currency.Converter
 }
 
 func NewTrxService(
 	ethScanCli components.EthScanCli,
 	bnPriceCli components.BnPriceCli,
 	repo repository.Repository,
+	rollups RollupService,
+	opts ...TrxFeeServiceOption,
 ) TrxFeeService {
-	return &trxFeeService{
+	c := &trxFeeService{
 		ethScanCli: ethScanCli,
 		bnPriceCli: bnPriceCli,
 		repo:       repo,
+		rollups:    rollups,
+		converter:  currency.NewConverter(currency.DefaultSources(bnPriceCli, nil)),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 type GetSingleTrxFeeRequest struct {
 	TrxHash string
+	// one of currency.Supported to also convert the fee to, none if empty
+	Currency string
 }
 
+const (
+	// the fee was stored
+	DataSourceDatabase = "database"
+	// the fee was computed from etherscan and binance for the query
+	DataSourceLive = "live"
+)
+
 type GetSingleTrxFeeResponse struct {
-	TrxFee string `json:"trx_fee"`
+	// in USDT, same as trx_fee_usdt
+	TrxFee      string `json:"trx_fee"`
+	TrxHash     string `json:"trx_hash"`
+	Symbol      string `json:"symbol"`
+	Status      string `json:"status"`
+	BlockNumber uint64 `json:"block_number"`
+	// unix timestamp of the block
+	BlockTime         int64           `json:"block_time"`
+	GasUsed           uint64          `json:"gas_used"`
+	EffectiveGasPrice util.Wei        `json:"effective_gas_price" swaggertype:"string"`
+	TrxFeeWei         util.Wei        `json:"trx_fee_wei" swaggertype:"string"`
+	TrxFeeEth         decimal.Decimal `json:"trx_fee_eth" swaggertype:"string"`
+	TrxFeeUsdt        decimal.Decimal `json:"trx_fee_usdt" swaggertype:"string"`
+	// ETH price in USDT the fee was converted with
+	EthUsdtPrice decimal.Decimal `json:"eth_usdt_price" swaggertype:"string"`
+	// like binance_1m
+	PriceSource string `json:"price_source"`
+	// how the price was computed from its source
+	PriceMethod string `json:"price_method"`
+	// DataSourceDatabase or DataSourceLive
+	DataSource string `json:"data_source" enums:"database,live"`
+	// tracked pools the trx touched, those emitting its logs for a live lookup
+	// and the pool of its symbol for a stored fee
+	Pools []components.Pool `json:"pools"`
+	// only if a currency is requested
+	Conversion *FeeConversion `json:"conversion,omitempty"`
+	// the whole fee, for the responses of later api versions
+	Fee *repository.UniTrxFee `json:"-"`
 }
 
 func (c *trxFeeService) GetSingleTrxFee(ctx context.Context, req *GetSingleTrxFeeRequest) (*GetSingleTrxFeeResponse, error) {
 	if req == nil {
 		return nil, errors.New("nil req")
 	}
+	if !util.IsTrxHash(req.TrxHash) {
+		return nil, fmt.Errorf("%w: malformed trx hash %s", ErrInvalidArgument, req.TrxHash)
+	}
+	if err := validateCurrency(req.Currency); err != nil {
+		return nil, err
+	}
+
+	lookup, err := c.sharedLookup(ctx, req.TrxHash)
+	if err != nil {
+		return nil, err
+	}
+	fee := lookup.fee
+	resp := &GetSingleTrxFeeResponse{
+		TrxFee:            fee.TrxFeeUsdt.String(),
+		TrxHash:           fee.TrxHash,
+		Symbol:            fee.Symbol,
+		Status:            fee.Status,
+		BlockNumber:       fee.BlockNumber,
+		BlockTime:         int64(fee.TrxTime),
+		GasUsed:           fee.GasUsed,
+		EffectiveGasPrice: fee.GasPrice,
+		TrxFeeWei:         fee.TrxFeeWei,
+		TrxFeeEth:         fee.TrxFeeEth,
+		TrxFeeUsdt:        fee.TrxFeeUsdt,
+		EthUsdtPrice:      fee.EthUsdtPrice,
+		PriceSource:       fee.PriceSource,
+		PriceMethod:       priceMethod(fee.PriceSource),
+		DataSource:        DataSourceDatabase,
+		Pools:             lookup.pools,
+		Fee:               fee,
+	}
+	if lookup.live {
+		resp.DataSource = DataSourceLive
+	}
+	if resp.Pools == nil {
+		resp.Pools = []components.Pool{}
+	}
+	if resp.Conversion, err = c.convertFee(fee, req.Currency); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// priceMethod describes how the ETH prices of a price source are computed
+func priceMethod(source string) string {
+	interval, ok := strings.CutPrefix(source, "binance_")
+	if !ok {
+		return "none, the fee wasn't priced"
+	}
+	return "mean of the open and close prices of the binance " + components.ETHUSDT + " " + interval + " klines around the trx time"
+}
+
+// trxFeeLookup is a fee with where it came from
+type trxFeeLookup struct {
+	fee *repository.UniTrxFee
+	// computed from etherscan and binance rather than read from db
+	live  bool
+	pools []components.Pool
+}
+
+// sharedLookup looks the fee of a trx up, concurrent lookups of a hash share
+// the db query and upstream calls. The caller stops waiting when ctx is done,
+// but the lookup goes on for the others and to store the fee.
+func (c *trxFeeService) sharedLookup(ctx context.Context, trxHash string) (*trxFeeLookup, error) {
+	ch := c.lookups.DoChan(trxHash, func() (interface{}, error) {
+		return c.lookupTrxFee(trxHash)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*trxFeeLookup), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
+// lookupTrxFee reads the fee of a trx from db, or computes it from etherscan
+// and binance and stores it so that the next lookups are served from db
+func (c *trxFeeService) lookupTrxFee(trxHash string) (*trxFeeLookup, error) {
 	// prefering directly querying from db
-	res, err := c.repo.GetTrxFee(req.TrxHash)
+	res, err := c.repo.GetTrxFee(trxHash)
 	if err != nil {
 		return nil, err
 	}
 	if res != nil {
-		return &GetSingleTrxFeeResponse{
-			TrxFee: res.TrxFeeUsdt.String(),
-		}, nil
+		lookup := &trxFeeLookup{fee: res}
+		if pool, ok := components.SymbolPool(res.Symbol); ok {
+			lookup.pools = []components.Pool{pool}
+		}
+		return lookup, nil
 	}
 
 	// alternatively querying from etherscan api
-	trxResp, err := c.ethScanCli.QueryTrxFee(req.TrxHash)
+	trxResp, err := c.ethScanCli.QueryTrxFee(trxHash)
 	if err != nil {
 		return nil, err
 	}
+	// unknown and pending trxs have no receipt
+	if trxResp.Result.BlockNumber == "" {
+		return nil, fmt.Errorf("%w: %s", ErrTrxNotFound, trxHash)
+	}
 
-	gasUsed, err := util.HexToInt(trxResp.Result.GasUsed)
+	gasUsed, err := util.ParseHexUint64(trxResp.Result.GasUsed)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: malformed gas used: %w", components.ErrUpstream, err)
 	}
-	gasPrice, err := util.HexToInt(trxResp.Result.EffectiveGasPrice)
+	gasPrice, err := util.ParseWeiHex(trxResp.Result.EffectiveGasPrice)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: malformed gas price: %w", components.ErrUpstream, err)
+	}
+	blockNum, err := util.ParseHexUint64(trxResp.Result.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed block number: %w", components.ErrUpstream, err)
 	}
-
-	// calculate gas fee in eth
-	gasInETH := util.CalculateFeeInETH(gasUsed, gasPrice)
 
 	// get block timestamp
 	blockResp, err := c.ethScanCli.QueryBlock(trxResp.Result.BlockNumber)
@@ -81,46 +236,271 @@ func (c *trxFeeService) GetSingleTrxFee(ctx context.Context, req *GetSingleTrxFe
 	}
 
 	// get eth price in USDT
-	trxTime, err := util.HexToInt(blockResp.Result.Timestamp)
+	trxTime, err := util.ParseHexUint64(blockResp.Result.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed block timestamp: %w", components.ErrUpstream, err)
+	}
+
+	price, source, err := c.ethPriceAt(int64(trxTime))
 	if err != nil {
 		return nil, err
 	}
 
-	// fetch the average price of [trxTime-60, trxTime+60]
+	pools := components.TrackedPools(trxResp.Result.Logs)
+	fee := repository.UniTrxFee{
+		TrxHash:     trxHash,
+		TrxTime:     trxTime,
+		GasUsed:     gasUsed,
+		GasPrice:    gasPrice,
+		BlockNumber: blockNum,
+		FromAddress: strings.ToLower(trxResp.Result.From),
+		Status:      receiptStatus(trxResp.Result.Status),
+	}
+	fee.SetPrice(price, source)
+	// only the trxs of tracked pools are stored, the others are served with
+	// an empty symbol and computed again by every lookup
+	if len(pools) > 0 {
+		fee.Symbol = pools[0].Symbol
+		c.saveTrxFee(&fee)
+	}
+	return &trxFeeLookup{fee: &fee, live: true, pools: pools}, nil
+}
+
+// ethPriceAt returns the ETH price in USDT of fees computed on demand, the
+// average of the binance 1m klines of [trxTime-60, trxTime+60], with its
+// price source
+func (c *trxFeeService) ethPriceAt(trxTime int64) (decimal.Decimal, string, error) {
 	price, err := c.bnPriceCli.QueryETHPrice(trxTime-60, trxTime+60, components.INTERVAL_1MIN)
 	if err != nil {
-		return nil, err
+		return decimal.Zero, "", err
+	}
+	return price, "binance_" + components.INTERVAL_1MIN, nil
+}
+
+// saveTrxFee stores a fee computed on demand, failing to do so only costs the
+// upstream calls of the next lookup
+func (c *trxFeeService) saveTrxFee(fee *repository.UniTrxFee) {
+	fees := []repository.UniTrxFee{*fee}
+	// a tracker may have stored it meanwhile, with a coarser price
+	report, err := c.repo.BatchInsertUniTrxFee(fees, repository.ConflictOverwriteIfBetterPrice)
+	if err != nil {
+		log.Printf("store fee of trx %s err: %s\n", fee.TrxHash, err.Error())
+		return
+	}
+	if report.Updated > 0 && c.rollups != nil {
+		c.rollups.InvalidateFees(fee.Symbol, fees)
 	}
+}
 
-	return &GetSingleTrxFeeResponse{
-		TrxFee: gasInETH.Mul(price).String(),
-	}, nil
+func receiptStatus(status string) string {
+	switch status {
+	case "0x1":
+		return repository.TrxStatusSuccess
+	case "0x0":
+		return repository.TrxStatusFailed
+	default:
+		return repository.TrxStatusUnknown
+	}
 }
 
+const (
+	defaultTrxFeeListLimit = 20
+	maxTrxFeeListLimit     = 50
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
 type GetTrxFeeListRequest struct {
-	Symbol    string
-	StartTime int64
-	EndTime   int64
-	Page      int
-	Limit     int
+	repository.TrxFeeFilter
+	// one of the repository.TrxFeeSortField, trx_time if empty
+	Sort string
+	// OrderAsc or OrderDesc by the sort field then id, ascending if empty
+	Order string
+	// next_cursor of the previous page, the first page if empty
+	Cursor string
+	// 20 if 0
+	Limit int
+	// count every matching fee, which is slower than listing a page
+	IncludeTotal bool
+	// one of currency.Supported to also convert the fees to, none if empty
+	Currency string
 }
 
 type GetTrxFeeListResponse struct {
 	Result []repository.UniTrxFee
+	// opaque cursor of the next page, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	// the fees of Result in the requested currency, in the same order
+	Conversions []FeeConversion `json:"conversions,omitempty"`
 }
 
 func (c *trxFeeService) GetTrxFeeList(ctx context.Context, req *GetTrxFeeListRequest) (*GetTrxFeeListResponse, error) {
 	if req == nil {
 		return nil, errors.New("nil req")
 	}
-	res, err := c.repo.ListTrxFee(req.Symbol, req.StartTime, req.EndTime, req.Page, req.Limit)
+	if err := validateCurrency(req.Currency); err != nil {
+		return nil, err
+	}
+	query, err := newTrxFeeListQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// read one more fee to know whether there is a next page
+	limit := query.Limit
+	query.Limit++
+	res, err := c.repo.ListTrxFee(query)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(res) == 0 {
-		return &GetTrxFeeListResponse{}, nil
+	resp := &GetTrxFeeListResponse{}
+	if len(res) > limit {
+		res = res[:limit]
+		resp.NextCursor = encodeTrxFeeListCursor(trxFeeListCursor{
+			Sort:     query.Sort,
+			Desc:     query.Desc,
+			position: query.NewTrxFeeCursor(&res[limit-1]),
+		})
+	}
+	if len(res) > 0 {
+		resp.Result = res
+	}
+	if req.Currency != "" {
+		resp.Conversions = make([]FeeConversion, len(res))
+		for i := range res {
+			conv, err := c.convertFee(&res[i], req.Currency)
+			if err != nil {
+				return nil, err
+			}
+			resp.Conversions[i] = *conv
+		}
+	}
+
+	if req.IncludeTotal {
+		total, err := c.repo.CountTrxFee(query.Filter)
+		if err != nil {
+			return nil, err
+		}
+		resp.Total = &total
+	}
+	return resp, nil
+}
+
+func newTrxFeeListQuery(req *GetTrxFeeListRequest) (repository.TrxFeeListQuery, error) {
+	query := repository.TrxFeeListQuery{
+		Filter: req.TrxFeeFilter,
+		Sort:   repository.TrxFeeSortField(req.Sort),
+		Limit:  req.Limit,
+	}
+	if err := validateTrxFeeFilter(&query.Filter); err != nil {
+		return query, err
+	}
+
+	if query.Sort == "" {
+		query.Sort = repository.SortByTrxTime
+	}
+	if !query.Sort.Valid() {
+		return query, fmt.Errorf("%w: unknown sort field %s", ErrInvalidArgument, req.Sort)
+	}
+	switch req.Order {
+	case "", OrderAsc:
+	case OrderDesc:
+		query.Desc = true
+	default:
+		return query, fmt.Errorf("%w: order must be %s or %s", ErrInvalidArgument, OrderAsc, OrderDesc)
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultTrxFeeListLimit
+	}
+	if query.Limit < 1 || query.Limit > maxTrxFeeListLimit {
+		return query, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidArgument, maxTrxFeeListLimit)
 	}
 
-	return &GetTrxFeeListResponse{Result: res}, nil
+	if req.Cursor != "" {
+		cursor, err := decodeTrxFeeListCursor(req.Cursor)
+		if err != nil || cursor.Desc != query.Desc || cursor.Sort != query.Sort {
+			return query, fmt.Errorf("%w: invalid cursor", ErrInvalidArgument)
+		}
+		query.After = &cursor.position
+	}
+	return query, nil
+}
+
+func validateTrxFeeFilter(f *repository.TrxFeeFilter) error {
+	if f.StartTime > f.EndTime {
+		return fmt.Errorf("%w: start_time is after end_time", ErrInvalidArgument)
+	}
+	uintRange := func(name string, lo, hi *uint64) error {
+		if lo != nil && hi != nil && *lo > *hi {
+			return fmt.Errorf("%w: min_%s is greater than max_%s", ErrInvalidArgument, name, name)
+		}
+		return nil
+	}
+	decimalRange := func(name string, lo, hi *decimal.Decimal) error {
+		if lo != nil && hi != nil && lo.GreaterThan(*hi) {
+			return fmt.Errorf("%w: min_%s is greater than max_%s", ErrInvalidArgument, name, name)
+		}
+		return nil
+	}
+
+	err := errors.Join(
+		uintRange("block", f.MinBlock, f.MaxBlock),
+		uintRange("gas_used", f.MinGasUsed, f.MaxGasUsed),
+		decimalRange("fee_usdt", f.MinFeeUsdt, f.MaxFeeUsdt),
+		decimalRange("fee_eth", f.MinFeeEth, f.MaxFeeEth),
+	)
+	if err != nil {
+		return err
+	}
+	if f.MinGasPrice != nil && f.MaxGasPrice != nil && f.MinGasPrice.Cmp(*f.MaxGasPrice) > 0 {
+		return fmt.Errorf("%w: min_gas_price is greater than max_gas_price", ErrInvalidArgument)
+	}
+
+	switch f.Status {
+	case "", repository.TrxStatusSuccess, repository.TrxStatusFailed, repository.TrxStatusUnknown:
+	default:
+		return fmt.Errorf("%w: status must be %s, %s or %s", ErrInvalidArgument,
+			repository.TrxStatusSuccess, repository.TrxStatusFailed, repository.TrxStatusUnknown)
+	}
+	return nil
+}
+
+// trxFeeListCursor is the position of the last fee of a page, it also keeps
+// the sort and order so that a cursor can't be used to page differently
+type trxFeeListCursor struct {
+	Sort     repository.TrxFeeSortField
+	Desc     bool
+	position repository.TrxFeeCursor
+}
+
+// trxFeeListCursorJSON is the encoded form of trxFeeListCursor, with short keys
+// to keep urls short
+type trxFeeListCursorJSON struct {
+	Sort  repository.TrxFeeSortField `json:"s"`
+	Desc  bool                       `json:"d,omitempty"`
+	Value decimal.Decimal            `json:"v"`
+	ID    uint64                     `json:"i"`
+}
+
+func encodeTrxFeeListCursor(c trxFeeListCursor) string {
+	data, _ := json.Marshal(trxFeeListCursorJSON{Sort: c.Sort, Desc: c.Desc, Value: c.position.Value, ID: c.position.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeTrxFeeListCursor(s string) (trxFeeListCursor, error) {
+	var c trxFeeListCursorJSON
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return trxFeeListCursor{}, err
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return trxFeeListCursor{}, err
+	}
+	return trxFeeListCursor{Sort: c.Sort, Desc: c.Desc, position: repository.TrxFeeCursor{Value: c.Value, ID: c.ID}}, nil
 }
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	mock_repository "github.com/jaime1129/fedex/mock/repository"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetSingleTrxFee(t *testing.T) {
@@ -23,10 +26,10 @@ func TestGetSingleTrxFee(t *testing.T) {
 	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
 	mockRepo := mock_repository.NewMockRepository(ctrl)
 
-	service := NewTrxService(mockEthScanCli, mockBnPriceCli, mockRepo)
+	service := NewTrxService(mockEthScanCli, mockBnPriceCli, mockRepo, nil)
 
 	ctx := context.TODO()
-	req := &GetSingleTrxFeeRequest{TrxHash: "hash123"}
+	req := &GetSingleTrxFeeRequest{TrxHash: testTrxHash(1)}
 
 	// Setup expectations and return values for the mocks
 	mockRepo.EXPECT().GetTrxFee(req.TrxHash).Return(nil, nil) // Simulate no result in DB
@@ -40,8 +43,8 @@ func TestGetSingleTrxFee(t *testing.T) {
 	}
 	mockEthScanCli.EXPECT().QueryTrxFee(req.TrxHash).Return(ethScanResp, nil)
 
-	gasUsed, _ := util.HexToInt("0x5208")
-	gasPrice, _ := util.HexToInt("0x3B9ACA00")
+	gasUsed, _ := util.ParseHexUint64("0x5208")
+	gasPrice, _ := util.ParseWeiHex("0x3B9ACA00")
 	gasInETH := util.CalculateFeeInETH(gasUsed, gasPrice)
 
 	blockResp := &components.EthScanBlockResponse{
@@ -49,14 +52,125 @@ func TestGetSingleTrxFee(t *testing.T) {
 	}
 	mockEthScanCli.EXPECT().QueryBlock("0x10FB78").Return(blockResp, nil)
 
-	trxTime, _ := util.HexToInt("0x5BA46680")
+	trxTime := int64(0x5BA46680)
 	mockBnPriceCli.EXPECT().QueryETHPrice(trxTime-60, trxTime+60, "1m").Return(decimal.NewFromFloat(2000), nil)
 
+	// the trx touches no tracked pool, its fee is served but not stored: the
+	// mock repository fails on BatchInsertUniTrxFee
+
 	// Call the function under test
 	response, err := service.GetSingleTrxFee(ctx, req)
 
-	assert.NoError(t, err)
+	require.NoError(t, err)
 	assert.Equal(t, gasInETH.Mul(decimal.NewFromFloat(2000)).String(), response.TrxFee)
+	assert.Equal(t, response.TrxFee, response.TrxFeeUsdt.String())
+	assert.Equal(t, gasInETH.String(), response.TrxFeeEth.String())
+	assert.Equal(t, "21000000000000", response.TrxFeeWei.String())
+	assert.Equal(t, uint64(21000), response.GasUsed)
+	assert.Equal(t, "1000000000", response.EffectiveGasPrice.String())
+	assert.Equal(t, uint64(0x10FB78), response.BlockNumber)
+	assert.Equal(t, trxTime, response.BlockTime)
+	assert.Equal(t, "2000", response.EthUsdtPrice.String())
+	assert.Equal(t, "binance_1m", response.PriceSource)
+	assert.Contains(t, response.PriceMethod, "1m klines")
+	assert.Equal(t, DataSourceLive, response.DataSource)
+	// no log of a tracked pool
+	assert.Equal(t, "", response.Symbol)
+	assert.Empty(t, response.Pools)
+	assert.NotNil(t, response.Pools)
+}
+
+func TestGetSingleTrxFeeWritesThrough(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	mockBnPriceCli := mock_components.NewMockBnPriceCli(ctrl)
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(mockEthScanCli, mockBnPriceCli, repo, nil)
+
+	// upstream is called once however many lookups there are, concurrent or later
+	mockEthScanCli.EXPECT().QueryTrxFee(testTrxHash(2)).Return(&components.EthScanTrxResponse{
+		Result: components.EthScanTrxResult{
+			GasUsed:           "0x5208",
+			EffectiveGasPrice: "0x3B9ACA00",
+			BlockNumber:       "0x10FB78",
+			From:              "0xSENDER",
+			Status:            "0x1",
+			Logs:              []components.EthScanTrxLog{{Address: "0xother"}, {Address: "0x88E6A0c2dDD26FEEb64F039a2c41296FcB3f5640"}},
+		},
+	}, nil).Times(1)
+	mockEthScanCli.EXPECT().QueryBlock("0x10FB78").Return(&components.EthScanBlockResponse{
+		Result: components.EthScanBlockResult{Timestamp: "0x5BA46680"},
+	}, nil).Times(1)
+	mockBnPriceCli.EXPECT().QueryETHPrice(gomock.Any(), gomock.Any(), "1m").Return(decimal.NewFromInt(2000), nil).Times(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: testTrxHash(2)})
+			assert.NoError(t, err)
+			assert.Equal(t, "0.042", response.TrxFee)
+			// only the tracked pool the trx logged from
+			assert.Equal(t, []components.Pool{{Address: "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640", Symbol: "WETH/USDC"}}, response.Pools)
+		}()
+	}
+	wg.Wait()
+
+	fee, err := repo.GetTrxFee(testTrxHash(2))
+	require.NoError(t, err)
+	require.NotNil(t, fee)
+	assert.Equal(t, "WETH/USDC", fee.Symbol)
+	assert.Equal(t, "0xsender", fee.FromAddress)
+	assert.Equal(t, repository.TrxStatusSuccess, fee.Status)
+	assert.Equal(t, uint64(0x10FB78), fee.BlockNumber)
+	assert.Equal(t, uint64(0x5BA46680), fee.TrxTime)
+	assert.Equal(t, "2000", fee.EthUsdtPrice.String())
+
+	// later lookups are served from db
+	response, err := service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: testTrxHash(2)})
+	require.NoError(t, err)
+	assert.Equal(t, DataSourceDatabase, response.DataSource)
+	assert.Equal(t, "WETH/USDC", response.Symbol)
+	assert.Equal(t, []components.Pool{{Address: "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640", Symbol: "WETH/USDC"}}, response.Pools)
+	assert.Equal(t, "0.042", response.TrxFee)
+}
+
+func TestGetSingleTrxFeeStopsWaitingWhenCanceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(mockEthScanCli, mock_components.NewMockBnPriceCli(ctrl), repo, nil)
+
+	// upstream hangs until the end of the test
+	release := make(chan struct{})
+	called := make(chan struct{})
+	mockEthScanCli.EXPECT().QueryTrxFee(testTrxHash(3)).DoAndReturn(func(string) (*components.EthScanTrxResponse, error) {
+		close(called)
+		<-release
+		return nil, fmt.Errorf("upstream down")
+	}).Times(1)
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := service.GetSingleTrxFee(ctx, &GetSingleTrxFeeRequest{TrxHash: testTrxHash(3)})
+		done <- err
+	}()
+	<-called
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("the lookup is still awaited after the request was canceled")
+	}
 }
 
 func TestGetTrxFeeList(t *testing.T) {
@@ -64,20 +178,25 @@ func TestGetTrxFeeList(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	service := NewTrxService(nil, nil, mockRepo) // nils are safe here since they are not used in this method
+	service := NewTrxService(nil, nil, mockRepo, nil) // nils are safe here since they are not used in this method
 
 	ctx := context.TODO()
 	req := &GetTrxFeeListRequest{
-		Symbol:    "WETH/USDC",
-		StartTime: time.Now().Unix(),
-		EndTime:   time.Now().Add(24 * time.Hour).Unix(),
-		Page:      1,
-		Limit:     10,
+		TrxFeeFilter: repository.TrxFeeFilter{
+			Symbol:    "WETH/USDC",
+			StartTime: time.Now().Unix(),
+			EndTime:   time.Now().Add(24 * time.Hour).Unix(),
+		},
+		Limit: 10,
 	}
 
-	// Mock response from repository
+	// Mock response from repository, one more fee than the limit is queried to detect the next page
 	mockResponse := []repository.UniTrxFee{{TrxFeeUsdt: decimal.NewFromFloat(300.5)}}
-	mockRepo.EXPECT().ListTrxFee(req.Symbol, req.StartTime, req.EndTime, req.Page, req.Limit).Return(mockResponse, nil)
+	mockRepo.EXPECT().ListTrxFee(repository.TrxFeeListQuery{
+		Filter: req.TrxFeeFilter,
+		Sort:   repository.SortByTrxTime,
+		Limit:  req.Limit + 1,
+	}).Return(mockResponse, nil)
 
 	// Call the function under test
 	response, err := service.GetTrxFeeList(ctx, req)
@@ -85,4 +204,121 @@ func TestGetTrxFeeList(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, response.Result, 1)
 	assert.Equal(t, decimal.NewFromFloat(300.5).String(), response.Result[0].TrxFeeUsdt.String())
+	assert.Empty(t, response.NextCursor)
+	assert.Nil(t, response.Total)
+}
+
+func TestGetTrxFeeListPagesWithCursor(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	service := NewTrxService(nil, nil, repo, nil)
+
+	// two fees share a trx time, so pages must also be ordered by id
+	var fees []repository.UniTrxFee
+	for i, trxTime := range []uint64{30, 10, 20, 20, 40} {
+		fees = append(fees, repository.UniTrxFee{Symbol: "WETH/USDC", TrxHash: fmt.Sprintf("0x%d", i), TrxTime: trxTime})
+	}
+	insertFees(t, repo, fees)
+
+	for _, order := range []string{OrderAsc, OrderDesc} {
+		var hashes []string
+		req := &GetTrxFeeListRequest{
+			TrxFeeFilter: repository.TrxFeeFilter{Symbol: "WETH/USDC", EndTime: 100},
+			Order:        order,
+			Limit:        2,
+			IncludeTotal: true,
+		}
+		for pages := 0; ; pages++ {
+			assert.Less(t, pages, 3, "too many pages")
+			resp, err := service.GetTrxFeeList(context.TODO(), req)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(5), *resp.Total)
+			for _, fee := range resp.Result {
+				hashes = append(hashes, fee.TrxHash)
+			}
+			if resp.NextCursor == "" {
+				break
+			}
+			req.Cursor = resp.NextCursor
+		}
+
+		expected := []string{"0x1", "0x2", "0x3", "0x0", "0x4"}
+		if order == OrderDesc {
+			expected = []string{"0x4", "0x0", "0x3", "0x2", "0x1"}
+		}
+		assert.Equal(t, expected, hashes, order)
+	}
+}
+
+func TestGetTrxFeeListRejectsInvalidArguments(t *testing.T) {
+	service := NewTrxService(nil, nil, repository.NewMemoryRepository(), nil)
+	ascCursor := encodeTrxFeeListCursor(trxFeeListCursor{Sort: repository.SortByTrxTime, position: repository.TrxFeeCursor{ID: 1}})
+	one, two := decimal.NewFromInt(1), decimal.NewFromInt(2)
+	lowGas, highGas := util.WeiFromUint64(1), util.WeiFromUint64(2)
+
+	for name, req := range map[string]*GetTrxFeeListRequest{
+		"limit too large":       {Limit: maxTrxFeeListLimit + 1},
+		"negative limit":        {Limit: -1},
+		"unknown order":         {Order: "random"},
+		"unknown sort":          {Sort: "symbol"},
+		"malformed cursor":      {Cursor: "not a cursor"},
+		"cursor of other order": {Order: OrderDesc, Cursor: ascCursor},
+		"cursor of other sort":  {Sort: string(repository.SortByFeeUsdt), Cursor: ascCursor},
+		"unknown status":        {TrxFeeFilter: repository.TrxFeeFilter{Status: "pending"}},
+		"inverted time range":   {TrxFeeFilter: repository.TrxFeeFilter{StartTime: 2, EndTime: 1}},
+		"inverted fee range":    {TrxFeeFilter: repository.TrxFeeFilter{MinFeeUsdt: &two, MaxFeeUsdt: &one}},
+		"inverted gas price":    {TrxFeeFilter: repository.TrxFeeFilter{MinGasPrice: &highGas, MaxGasPrice: &lowGas}},
+	} {
+		_, err := service.GetTrxFeeList(context.TODO(), req)
+		assert.ErrorIs(t, err, ErrInvalidArgument, name)
+	}
+}
+
+func TestGetSingleTrxFeeRejectsMalformedHash(t *testing.T) {
+	service := NewTrxService(nil, nil, repository.NewMemoryRepository(), nil)
+
+	for _, hash := range []string{"", "hash123", testTrxHash(1)[:65], testTrxHash(1) + "0"} {
+		_, err := service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: hash})
+		assert.ErrorIs(t, err, ErrInvalidArgument, hash)
+	}
+}
+
+func TestGetSingleTrxFeeUpstreamErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEthScanCli := mock_components.NewMockEthScanCli(ctrl)
+	service := NewTrxService(mockEthScanCli, nil, repository.NewMemoryRepository(), nil)
+
+	// etherscan has no receipt for unknown trxs
+	mockEthScanCli.EXPECT().QueryTrxFee(testTrxHash(1)).Return(&components.EthScanTrxResponse{}, nil)
+	_, err := service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: testTrxHash(1)})
+	assert.ErrorIs(t, err, ErrTrxNotFound)
+
+	mockEthScanCli.EXPECT().QueryTrxFee(testTrxHash(2)).Return(&components.EthScanTrxResponse{
+		Result: components.EthScanTrxResult{GasUsed: "not hex", BlockNumber: "0x1"},
+	}, nil)
+	_, err = service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: testTrxHash(2)})
+	assert.ErrorIs(t, err, components.ErrUpstream)
+
+	// a malformed block timestamp fails the lookup instead of pricing the fee at 0
+	mockEthScanCli.EXPECT().QueryTrxFee(testTrxHash(3)).Return(&components.EthScanTrxResponse{
+		Result: components.EthScanTrxResult{GasUsed: "0x5208", EffectiveGasPrice: "0x3B9ACA00", BlockNumber: "0x1"},
+	}, nil)
+	mockEthScanCli.EXPECT().QueryBlock("0x1").Return(&components.EthScanBlockResponse{
+		Result: components.EthScanBlockResult{Timestamp: "-0x1"},
+	}, nil)
+	_, err = service.GetSingleTrxFee(context.TODO(), &GetSingleTrxFeeRequest{TrxHash: testTrxHash(3)})
+	assert.ErrorIs(t, err, components.ErrUpstream)
+}
+
+// testTrxHash returns a well-formed trx hash ending with n
+func testTrxHash(n int) string {
+	return fmt.Sprintf("0x%064x", n)
+}
+
+// insertFees inserts fees and fails the test on error
+func insertFees(t *testing.T, repo repository.TxRepository, fees []repository.UniTrxFee) {
+	t.Helper()
+	_, err := repo.BatchInsertUniTrxFee(fees, repository.ConflictKeepExisting)
+	require.NoError(t, err)
 }
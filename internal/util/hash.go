@@ -0,0 +1,10 @@
+package util
+
+import "regexp"
+
+var trxHashPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
+// IsTrxHash tells whether s is a 0x prefixed 32 bytes hex trx hash
+func IsTrxHash(s string) bool {
+	return trxHashPattern.MatchString(s)
+}
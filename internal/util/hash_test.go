@@ -0,0 +1,15 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTrxHash(t *testing.T) {
+	assert.True(t, IsTrxHash("0x"+strings.Repeat("aB3", 21)+"c"))
+	assert.False(t, IsTrxHash(strings.Repeat("a", 64)))
+	assert.False(t, IsTrxHash("0x"+strings.Repeat("a", 63)))
+	assert.False(t, IsTrxHash("0x"+strings.Repeat("g", 64)))
+}
@@ -0,0 +1,15 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateFeeInETHKeepsAllDecimals(t *testing.T) {
+	// 21000 gas at 1.234567891 gwei
+	assert.Equal(t, "25925925711000", CalculateFeeInWei(21000, WeiFromUint64(1234567891)).String())
+	assert.Equal(t, "0.000025925925711", CalculateFeeInETH(21000, WeiFromUint64(1234567891)).String())
+	// a single wei is still representable
+	assert.Equal(t, "0.000000000000000001", CalculateFeeInETH(1, WeiFromUint64(1)).String())
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"math"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// SketchRelativeAccuracy bounds the relative error of the percentiles estimated by a Sketch
+const SketchRelativeAccuracy = 0.01
+
+var (
+	sketchGamma    = (1 + SketchRelativeAccuracy) / (1 - SketchRelativeAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// Sketch estimates percentiles of non-negative values. Values are counted in
+// buckets whose bounds grow geometrically, so that any value of a bucket is
+// within SketchRelativeAccuracy of its estimate. Sketches of disjoint sets
+// merge into the sketch of their union, which makes them fit for rollups.
+// The zero value is an empty sketch.
+type Sketch struct {
+	// values that are zero, or too small to be told apart from zero
+	Zeros uint64 `json:"z,omitempty"`
+	// count of values v with gamma^(i-1) < v <= gamma^i, by i
+	Buckets map[int]uint64 `json:"b,omitempty"`
+}
+
+// Add counts v, negative values are counted as zeros
+func (s *Sketch) Add(v decimal.Decimal) {
+	f := v.InexactFloat64()
+	if f <= 0 {
+		s.Zeros++
+		return
+	}
+	if s.Buckets == nil {
+		s.Buckets = make(map[int]uint64)
+	}
+	s.Buckets[int(math.Ceil(math.Log(f)/sketchLogGamma))]++
+}
+
+// Merge counts the values of o into s
+func (s *Sketch) Merge(o *Sketch) {
+	s.Zeros += o.Zeros
+	if len(o.Buckets) > 0 && s.Buckets == nil {
+		s.Buckets = make(map[int]uint64, len(o.Buckets))
+	}
+	for i, n := range o.Buckets {
+		s.Buckets[i] += n
+	}
+}
+
+func (s *Sketch) Count() uint64 {
+	n := s.Zeros
+	for _, c := range s.Buckets {
+		n += c
+	}
+	return n
+}
+
+// Percentile estimates the nearest-rank percentile p of the values, that is
+// the smallest value greater than or equal to p percent of them. It is 0 for
+// an empty sketch.
+func (s *Sketch) Percentile(p int64) decimal.Decimal {
+	n := s.Count()
+	if n == 0 {
+		return decimal.Zero
+	}
+	rank := (uint64(p)*n + 99) / 100
+	if rank <= s.Zeros {
+		return decimal.Zero
+	}
+
+	indexes := make([]int, 0, len(s.Buckets))
+	for i := range s.Buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	seen := s.Zeros
+	for _, i := range indexes {
+		seen += s.Buckets[i]
+		if seen >= rank {
+			// the estimate is equally far, relatively, from both bounds of the bucket
+			return decimal.NewFromFloat(2 * math.Pow(sketchGamma, float64(i)) / (sketchGamma + 1))
+		}
+	}
+	return decimal.Zero
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketchPercentile(t *testing.T) {
+	var s Sketch
+	assert.True(t, s.Percentile(50).IsZero())
+
+	// 1 to 1000, shuffled by a stride coprime with 1000
+	for i := 0; i < 1000; i++ {
+		s.Add(decimal.NewFromInt(int64(i*7%1000 + 1)))
+	}
+	assert.Equal(t, uint64(1000), s.Count())
+	for p, exact := range map[int64]float64{1: 10, 50: 500, 90: 900, 99: 990, 100: 1000} {
+		estimate := s.Percentile(p).InexactFloat64()
+		assert.InDelta(t, exact, estimate, exact*SketchRelativeAccuracy, "p%d", p)
+	}
+}
+
+func TestSketchZeros(t *testing.T) {
+	var s Sketch
+	for i := 0; i < 3; i++ {
+		s.Add(decimal.Zero)
+	}
+	s.Add(decimal.NewFromInt(5))
+	assert.True(t, s.Percentile(50).IsZero())
+	assert.InDelta(t, 5, s.Percentile(99).InexactFloat64(), 5*SketchRelativeAccuracy)
+}
+
+func TestSketchMerge(t *testing.T) {
+	var low, high, all Sketch
+	for i := 1; i <= 100; i++ {
+		v := decimal.NewFromFloat(float64(i) / 1000)
+		all.Add(v)
+		if i <= 50 {
+			low.Add(v)
+		} else {
+			high.Add(v)
+		}
+	}
+
+	var merged Sketch
+	merged.Merge(&low)
+	merged.Merge(&high)
+	assert.Equal(t, all, merged)
+
+	data, err := json.Marshal(&merged)
+	require.NoError(t, err)
+	var decoded Sketch
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, all, decoded)
+	assert.Equal(t, all.Percentile(90), decoded.Percentile(90))
+}
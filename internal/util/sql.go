@@ -0,0 +1,22 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RebindDollar replaces ? placeholders with $1, $2... as postgres expects
+func RebindDollar(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
@@ -0,0 +1,12 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebindDollar(t *testing.T) {
+	assert.Equal(t, "SELECT a FROM t WHERE b = $1 AND c IN ($2, $3)", RebindDollar("SELECT a FROM t WHERE b = ? AND c IN (?, ?)"))
+	assert.Equal(t, "SELECT 1", RebindDollar("SELECT 1"))
+}
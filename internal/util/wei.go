@@ -0,0 +1,179 @@
+package util
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	GweiDecimals  = 9
+	EtherDecimals = 18
+)
+
+// Wei is a non-negative amount of ether in its smallest unit. It is immutable,
+// the zero value is 0 wei.
+type Wei struct {
+	v *big.Int
+}
+
+func NewWei(v *big.Int) Wei {
+	if v == nil {
+		return Wei{}
+	}
+	return Wei{v: new(big.Int).Set(v)}
+}
+
+func WeiFromUint64(v uint64) Wei {
+	return Wei{v: new(big.Int).SetUint64(v)}
+}
+
+// WeiFromGwei converts an amount in gwei, fractions of a wei are truncated
+func WeiFromGwei(gwei decimal.Decimal) Wei {
+	return Wei{v: gwei.Shift(GweiDecimals).BigInt()}
+}
+
+// ParseWeiHex parses a 0x-prefixed hex quantity as returned by json-rpc
+func ParseWeiHex(s string) (Wei, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	// big.Int would accept a sign, quantities have none
+	if digits == "" || strings.ContainsAny(digits, "+-") {
+		return Wei{}, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	v, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return Wei{}, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	return Wei{v: v}, nil
+}
+
+// ParseWeiDecimal parses a base 10 integer string as returned by the etherscan account api
+func ParseWeiDecimal(s string) (Wei, error) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok || v.Sign() < 0 {
+		return Wei{}, fmt.Errorf("invalid wei amount %q", s)
+	}
+	return Wei{v: v}, nil
+}
+
+// ParseHexUint64 parses a 0x-prefixed hex quantity that must fit in 64 bits, like gas or block numbers
+func ParseHexUint64(s string) (uint64, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	v, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex quantity %q: %w", s, err)
+	}
+	return v, nil
+}
+
+// ParseUint64 parses a base 10 quantity that must fit in 64 bits
+func ParseUint64(s string) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q: %w", s, err)
+	}
+	return v, nil
+}
+
+func (w Wei) BigInt() *big.Int {
+	if w.v == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(w.v)
+}
+
+func (w Wei) IsZero() bool {
+	return w.v == nil || w.v.Sign() == 0
+}
+
+func (w Wei) Cmp(o Wei) int {
+	return w.BigInt().Cmp(o.BigInt())
+}
+
+// MulGas returns the cost of gas units at w per unit
+func (w Wei) MulGas(gas uint64) Wei {
+	return Wei{v: new(big.Int).Mul(w.BigInt(), new(big.Int).SetUint64(gas))}
+}
+
+func (w Wei) Decimal() decimal.Decimal {
+	return decimal.NewFromBigInt(w.BigInt(), 0)
+}
+
+func (w Wei) Gwei() decimal.Decimal {
+	return decimal.NewFromBigInt(w.BigInt(), -GweiDecimals)
+}
+
+func (w Wei) Ether() decimal.Decimal {
+	return decimal.NewFromBigInt(w.BigInt(), -EtherDecimals)
+}
+
+func (w Wei) String() string {
+	return w.BigInt().String()
+}
+
+// MarshalJSON encodes the amount as a decimal string so that clients don't lose precision
+func (w Wei) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.String())
+}
+
+// UnmarshalJSON also accepts a json number, the encoding of the uint64 amounts
+// serialized before Wei, like the fees of older dead letter payloads
+func (w *Wei) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if json.Unmarshal(data, &n) != nil {
+			return err
+		}
+		s = n.String()
+	}
+	v, err := ParseWeiDecimal(s)
+	if err != nil {
+		return err
+	}
+	*w = v
+	return nil
+}
+
+// Scan reads a decimal(65,0) column
+func (w *Wei) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("negative wei amount %d", v)
+		}
+		*w = WeiFromUint64(uint64(v))
+		return nil
+	case nil:
+		*w = Wei{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Wei", src)
+	}
+
+	// the column may be declared with a scale, like decimal(65,0) read as "1.0"
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return err
+	}
+	if !d.Equal(d.Truncate(0)) || d.IsNegative() {
+		return errors.New("invalid wei amount " + s)
+	}
+	*w = Wei{v: d.BigInt()}
+	return nil
+}
+
+func (w Wei) Value() (driver.Value, error) {
+	return w.String(), nil
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWei(t *testing.T) {
+	// larger than uint64
+	w, err := ParseWeiHex("0x1000000000000000000")
+	assert.NoError(t, err)
+	assert.Equal(t, "4722366482869645213696", w.String())
+
+	w, err = ParseWeiDecimal("4722366482869645213696")
+	assert.NoError(t, err)
+	assert.Equal(t, "4722.366482869645213696", w.Ether().String())
+
+	for _, s := range []string{"", "0x", "0xzz", "0x-1", "0x+1", "-0x1", "12a"} {
+		_, err = ParseWeiHex(s)
+		if s == "12a" {
+			// plain hex without prefix is accepted
+			assert.NoError(t, err)
+			continue
+		}
+		assert.Error(t, err, s)
+	}
+	for _, s := range []string{"", "-1", "1.5", "abc"} {
+		_, err = ParseWeiDecimal(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseUint64RejectsOverflow(t *testing.T) {
+	_, err := ParseUint64("18446744073709551616")
+	assert.Error(t, err)
+	_, err = ParseHexUint64("0x10000000000000000")
+	assert.Error(t, err)
+
+	v, err := ParseHexUint64("0x5208")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(21000), v)
+}
+
+func TestWeiUnits(t *testing.T) {
+	w := WeiFromGwei(decimal.RequireFromString("1.5"))
+	assert.Equal(t, "1500000000", w.String())
+	assert.Equal(t, "1.5", w.Gwei().String())
+	assert.True(t, Wei{}.IsZero())
+	assert.Equal(t, "0", Wei{}.String())
+}
+
+func TestWeiJSONAndScan(t *testing.T) {
+	w, _ := ParseWeiDecimal("4722366482869645213696")
+	data, err := json.Marshal(w)
+	assert.NoError(t, err)
+	assert.Equal(t, `"4722366482869645213696"`, string(data))
+
+	var decoded Wei
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, 0, w.Cmp(decoded))
+	assert.NoError(t, json.Unmarshal([]byte(`4722366482869645213696`), &decoded))
+	assert.Equal(t, 0, w.Cmp(decoded))
+	assert.Error(t, json.Unmarshal([]byte(`1.5`), &decoded))
+	assert.Error(t, json.Unmarshal([]byte(`true`), &decoded))
+
+	var scanned Wei
+	assert.NoError(t, scanned.Scan([]byte("4722366482869645213696")))
+	assert.Equal(t, 0, w.Cmp(scanned))
+	assert.Error(t, scanned.Scan([]byte("1.5")))
+}
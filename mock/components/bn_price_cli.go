@@ -8,6 +8,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	components "github.com/jaime1129/fedex/internal/components"
 	decimal "github.com/shopspring/decimal"
 )
 
@@ -48,3 +49,18 @@ func (mr *MockBnPriceCliMockRecorder) QueryETHPrice(start, end, interval interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryETHPrice", reflect.TypeOf((*MockBnPriceCli)(nil).QueryETHPrice), start, end, interval)
 }
+
+// QueryKlines mocks base method.
+func (m *MockBnPriceCli) QueryKlines(symbol string, start, end int64, interval string) ([]components.Kline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryKlines", symbol, start, end, interval)
+	ret0, _ := ret[0].([]components.Kline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryKlines indicates an expected call of QueryKlines.
+func (mr *MockBnPriceCliMockRecorder) QueryKlines(symbol, start, end, interval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryKlines", reflect.TypeOf((*MockBnPriceCli)(nil).QueryKlines), symbol, start, end, interval)
+}
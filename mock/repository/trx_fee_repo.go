@@ -5,6 +5,7 @@
 package mock_repository
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,31 +36,18 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 }
 
 // BatchInsertUniTrxFee mocks base method.
-func (m *MockRepository) BatchInsertUniTrxFee(fees []repository.UniTrxFee) error {
+func (m *MockRepository) BatchInsertUniTrxFee(fees []repository.UniTrxFee, policy repository.ConflictPolicy) (repository.InsertReport, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BatchInsertUniTrxFee", fees)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "BatchInsertUniTrxFee", fees, policy)
+	ret0, _ := ret[0].(repository.InsertReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // BatchInsertUniTrxFee indicates an expected call of BatchInsertUniTrxFee.
-func (mr *MockRepositoryMockRecorder) BatchInsertUniTrxFee(fees interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) BatchInsertUniTrxFee(fees, policy interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInsertUniTrxFee", reflect.TypeOf((*MockRepository)(nil).BatchInsertUniTrxFee), fees)
-}
-
-// BatchRecordHistoricalTrx mocks base method.
-func (m *MockRepository) BatchRecordHistoricalTrx(fees []repository.UniTrxFee, symbol string, maxBlock uint64) error {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BatchRecordHistoricalTrx", fees, symbol, maxBlock)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// BatchRecordHistoricalTrx indicates an expected call of BatchRecordHistoricalTrx.
-func (mr *MockRepositoryMockRecorder) BatchRecordHistoricalTrx(fees, symbol, maxBlock interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchRecordHistoricalTrx", reflect.TypeOf((*MockRepository)(nil).BatchRecordHistoricalTrx), fees, symbol, maxBlock)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInsertUniTrxFee", reflect.TypeOf((*MockRepository)(nil).BatchInsertUniTrxFee), fees, policy)
 }
 
 // Close mocks base method.
@@ -74,6 +62,66 @@ func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
 }
 
+// CountTrxFee mocks base method.
+func (m *MockRepository) CountTrxFee(filter repository.TrxFeeFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTrxFee", filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTrxFee indicates an expected call of CountTrxFee.
+func (mr *MockRepositoryMockRecorder) CountTrxFee(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTrxFee", reflect.TypeOf((*MockRepository)(nil).CountTrxFee), filter)
+}
+
+// GetDeadLetter mocks base method.
+func (m *MockRepository) GetDeadLetter(id uint64) (*repository.DeadLetterBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeadLetter", id)
+	ret0, _ := ret[0].(*repository.DeadLetterBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeadLetter indicates an expected call of GetDeadLetter.
+func (mr *MockRepositoryMockRecorder) GetDeadLetter(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeadLetter", reflect.TypeOf((*MockRepository)(nil).GetDeadLetter), id)
+}
+
+// GetGasPricePercentile mocks base method.
+func (m *MockRepository) GetGasPricePercentile(filter repository.TrxFeeFilter, p int) (*repository.GasPricePercentile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGasPricePercentile", filter, p)
+	ret0, _ := ret[0].(*repository.GasPricePercentile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGasPricePercentile indicates an expected call of GetGasPricePercentile.
+func (mr *MockRepositoryMockRecorder) GetGasPricePercentile(filter, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGasPricePercentile", reflect.TypeOf((*MockRepository)(nil).GetGasPricePercentile), filter, p)
+}
+
+// GetJobWatermark mocks base method.
+func (m *MockRepository) GetJobWatermark(job string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJobWatermark", job)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJobWatermark indicates an expected call of GetJobWatermark.
+func (mr *MockRepositoryMockRecorder) GetJobWatermark(job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJobWatermark", reflect.TypeOf((*MockRepository)(nil).GetJobWatermark), job)
+}
+
 // GetMaxBlockNum mocks base method.
 func (m *MockRepository) GetMaxBlockNum(symbol string) (uint64, error) {
 	m.ctrl.T.Helper()
@@ -104,17 +152,644 @@ func (mr *MockRepositoryMockRecorder) GetTrxFee(txHash interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFee", reflect.TypeOf((*MockRepository)(nil).GetTrxFee), txHash)
 }
 
+// GetTrxFeeByID mocks base method.
+func (m *MockRepository) GetTrxFeeByID(id uint64) (*repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrxFeeByID", id)
+	ret0, _ := ret[0].(*repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrxFeeByID indicates an expected call of GetTrxFeeByID.
+func (mr *MockRepositoryMockRecorder) GetTrxFeeByID(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFeeByID", reflect.TypeOf((*MockRepository)(nil).GetTrxFeeByID), id)
+}
+
+// GetTrxFeeSeries mocks base method.
+func (m *MockRepository) GetTrxFeeSeries(filter repository.TrxFeeFilter, step int64) ([]repository.TrxFeeBucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrxFeeSeries", filter, step)
+	ret0, _ := ret[0].([]repository.TrxFeeBucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrxFeeSeries indicates an expected call of GetTrxFeeSeries.
+func (mr *MockRepositoryMockRecorder) GetTrxFeeSeries(filter, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFeeSeries", reflect.TypeOf((*MockRepository)(nil).GetTrxFeeSeries), filter, step)
+}
+
+// GetTrxFeeStats mocks base method.
+func (m *MockRepository) GetTrxFeeStats(filter repository.TrxFeeFilter) (*repository.TrxFeeStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrxFeeStats", filter)
+	ret0, _ := ret[0].(*repository.TrxFeeStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrxFeeStats indicates an expected call of GetTrxFeeStats.
+func (mr *MockRepositoryMockRecorder) GetTrxFeeStats(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFeeStats", reflect.TypeOf((*MockRepository)(nil).GetTrxFeeStats), filter)
+}
+
+// InsertDeadLetter mocks base method.
+func (m *MockRepository) InsertDeadLetter(batch *repository.DeadLetterBatch) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertDeadLetter", batch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertDeadLetter indicates an expected call of InsertDeadLetter.
+func (mr *MockRepositoryMockRecorder) InsertDeadLetter(batch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertDeadLetter", reflect.TypeOf((*MockRepository)(nil).InsertDeadLetter), batch)
+}
+
+// ListDeadLetters mocks base method.
+func (m *MockRepository) ListDeadLetters(status string, page, limit int) ([]repository.DeadLetterBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", status, page, limit)
+	ret0, _ := ret[0].([]repository.DeadLetterBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *MockRepositoryMockRecorder) ListDeadLetters(status, page, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*MockRepository)(nil).ListDeadLetters), status, page, limit)
+}
+
+// ListDueDeadLetters mocks base method.
+func (m *MockRepository) ListDueDeadLetters(now int64, limit int) ([]repository.DeadLetterBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDueDeadLetters", now, limit)
+	ret0, _ := ret[0].([]repository.DeadLetterBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDueDeadLetters indicates an expected call of ListDueDeadLetters.
+func (mr *MockRepositoryMockRecorder) ListDueDeadLetters(now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDueDeadLetters", reflect.TypeOf((*MockRepository)(nil).ListDueDeadLetters), now, limit)
+}
+
+// ListRollupHoursByBlock mocks base method.
+func (m *MockRepository) ListRollupHoursByBlock(symbol string, fromBlock, toBlock uint64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRollupHoursByBlock", symbol, fromBlock, toBlock)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRollupHoursByBlock indicates an expected call of ListRollupHoursByBlock.
+func (mr *MockRepositoryMockRecorder) ListRollupHoursByBlock(symbol, fromBlock, toBlock interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRollupHoursByBlock", reflect.TypeOf((*MockRepository)(nil).ListRollupHoursByBlock), symbol, fromBlock, toBlock)
+}
+
 // ListTrxFee mocks base method.
-func (m *MockRepository) ListTrxFee(symbol string, startTime, endTime int64, page, limit int) ([]repository.UniTrxFee, error) {
+func (m *MockRepository) ListTrxFee(query repository.TrxFeeListQuery) ([]repository.UniTrxFee, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTrxFee", symbol, startTime, endTime, page, limit)
+	ret := m.ctrl.Call(m, "ListTrxFee", query)
 	ret0, _ := ret[0].([]repository.UniTrxFee)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListTrxFee indicates an expected call of ListTrxFee.
-func (mr *MockRepositoryMockRecorder) ListTrxFee(symbol, startTime, endTime, page, limit interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) ListTrxFee(query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFee", reflect.TypeOf((*MockRepository)(nil).ListTrxFee), query)
+}
+
+// ListTrxFeeByHash mocks base method.
+func (m *MockRepository) ListTrxFeeByHash(txHashes []string) ([]repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeByHash", txHashes)
+	ret0, _ := ret[0].([]repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeByHash indicates an expected call of ListTrxFeeByHash.
+func (mr *MockRepositoryMockRecorder) ListTrxFeeByHash(txHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeByHash", reflect.TypeOf((*MockRepository)(nil).ListTrxFeeByHash), txHashes)
+}
+
+// ListTrxFeeCreatedAfter mocks base method.
+func (m *MockRepository) ListTrxFeeCreatedAfter(after repository.TrxFeeTimeCursor, filter repository.StreamFilter, limit int) ([]repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeCreatedAfter", after, filter, limit)
+	ret0, _ := ret[0].([]repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeCreatedAfter indicates an expected call of ListTrxFeeCreatedAfter.
+func (mr *MockRepositoryMockRecorder) ListTrxFeeCreatedAfter(after, filter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeCreatedAfter", reflect.TypeOf((*MockRepository)(nil).ListTrxFeeCreatedAfter), after, filter, limit)
+}
+
+// ListTrxFeeHoursModifiedAfter mocks base method.
+func (m *MockRepository) ListTrxFeeHoursModifiedAfter(symbol string, after, start, end int64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeHoursModifiedAfter", symbol, after, start, end)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeHoursModifiedAfter indicates an expected call of ListTrxFeeHoursModifiedAfter.
+func (mr *MockRepositoryMockRecorder) ListTrxFeeHoursModifiedAfter(symbol, after, start, end interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeHoursModifiedAfter", reflect.TypeOf((*MockRepository)(nil).ListTrxFeeHoursModifiedAfter), symbol, after, start, end)
+}
+
+// ListTrxFeeModifiedAfter mocks base method.
+func (m *MockRepository) ListTrxFeeModifiedAfter(after repository.TrxFeeTimeCursor, filter repository.StreamFilter, limit int) ([]repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeModifiedAfter", after, filter, limit)
+	ret0, _ := ret[0].([]repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeModifiedAfter indicates an expected call of ListTrxFeeModifiedAfter.
+func (mr *MockRepositoryMockRecorder) ListTrxFeeModifiedAfter(after, filter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeModifiedAfter", reflect.TypeOf((*MockRepository)(nil).ListTrxFeeModifiedAfter), after, filter, limit)
+}
+
+// ListTrxFeeRollups mocks base method.
+func (m *MockRepository) ListTrxFeeRollups(period repository.RollupPeriod, symbol string, start, end int64) ([]repository.TrxFeeRollup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeRollups", period, symbol, start, end)
+	ret0, _ := ret[0].([]repository.TrxFeeRollup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeRollups indicates an expected call of ListTrxFeeRollups.
+func (mr *MockRepositoryMockRecorder) ListTrxFeeRollups(period, symbol, start, end interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeRollups", reflect.TypeOf((*MockRepository)(nil).ListTrxFeeRollups), period, symbol, start, end)
+}
+
+// RecordMaxBlockNum mocks base method.
+func (m *MockRepository) RecordMaxBlockNum(symbol string, maxBlock uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordMaxBlockNum", symbol, maxBlock)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordMaxBlockNum indicates an expected call of RecordMaxBlockNum.
+func (mr *MockRepositoryMockRecorder) RecordMaxBlockNum(symbol, maxBlock interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordMaxBlockNum", reflect.TypeOf((*MockRepository)(nil).RecordMaxBlockNum), symbol, maxBlock)
+}
+
+// SaveTrxFeeRollup mocks base method.
+func (m *MockRepository) SaveTrxFeeRollup(rollup *repository.TrxFeeRollup) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTrxFeeRollup", rollup)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTrxFeeRollup indicates an expected call of SaveTrxFeeRollup.
+func (mr *MockRepositoryMockRecorder) SaveTrxFeeRollup(rollup interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTrxFeeRollup", reflect.TypeOf((*MockRepository)(nil).SaveTrxFeeRollup), rollup)
+}
+
+// SetJobWatermark mocks base method.
+func (m *MockRepository) SetJobWatermark(job string, lastModified int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetJobWatermark", job, lastModified)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetJobWatermark indicates an expected call of SetJobWatermark.
+func (mr *MockRepositoryMockRecorder) SetJobWatermark(job, lastModified interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetJobWatermark", reflect.TypeOf((*MockRepository)(nil).SetJobWatermark), job, lastModified)
+}
+
+// UpdateDeadLetter mocks base method.
+func (m *MockRepository) UpdateDeadLetter(batch *repository.DeadLetterBatch) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeadLetter", batch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDeadLetter indicates an expected call of UpdateDeadLetter.
+func (mr *MockRepositoryMockRecorder) UpdateDeadLetter(batch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeadLetter", reflect.TypeOf((*MockRepository)(nil).UpdateDeadLetter), batch)
+}
+
+// WithTx mocks base method.
+func (m *MockRepository) WithTx(ctx context.Context, fn func(repository.TxRepository) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositoryMockRecorder) WithTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepository)(nil).WithTx), ctx, fn)
+}
+
+// MockTxRepository is a mock of TxRepository interface.
+type MockTxRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxRepositoryMockRecorder
+}
+
+// MockTxRepositoryMockRecorder is the mock recorder for MockTxRepository.
+type MockTxRepositoryMockRecorder struct {
+	mock *MockTxRepository
+}
+
+// NewMockTxRepository creates a new mock instance.
+func NewMockTxRepository(ctrl *gomock.Controller) *MockTxRepository {
+	mock := &MockTxRepository{ctrl: ctrl}
+	mock.recorder = &MockTxRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTxRepository) EXPECT() *MockTxRepositoryMockRecorder {
+	return m.recorder
+}
+
+// BatchInsertUniTrxFee mocks base method.
+func (m *MockTxRepository) BatchInsertUniTrxFee(fees []repository.UniTrxFee, policy repository.ConflictPolicy) (repository.InsertReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchInsertUniTrxFee", fees, policy)
+	ret0, _ := ret[0].(repository.InsertReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchInsertUniTrxFee indicates an expected call of BatchInsertUniTrxFee.
+func (mr *MockTxRepositoryMockRecorder) BatchInsertUniTrxFee(fees, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInsertUniTrxFee", reflect.TypeOf((*MockTxRepository)(nil).BatchInsertUniTrxFee), fees, policy)
+}
+
+// CountTrxFee mocks base method.
+func (m *MockTxRepository) CountTrxFee(filter repository.TrxFeeFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTrxFee", filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTrxFee indicates an expected call of CountTrxFee.
+func (mr *MockTxRepositoryMockRecorder) CountTrxFee(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTrxFee", reflect.TypeOf((*MockTxRepository)(nil).CountTrxFee), filter)
+}
+
+// GetDeadLetter mocks base method.
+func (m *MockTxRepository) GetDeadLetter(id uint64) (*repository.DeadLetterBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeadLetter", id)
+	ret0, _ := ret[0].(*repository.DeadLetterBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeadLetter indicates an expected call of GetDeadLetter.
+func (mr *MockTxRepositoryMockRecorder) GetDeadLetter(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeadLetter", reflect.TypeOf((*MockTxRepository)(nil).GetDeadLetter), id)
+}
+
+// GetGasPricePercentile mocks base method.
+func (m *MockTxRepository) GetGasPricePercentile(filter repository.TrxFeeFilter, p int) (*repository.GasPricePercentile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGasPricePercentile", filter, p)
+	ret0, _ := ret[0].(*repository.GasPricePercentile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGasPricePercentile indicates an expected call of GetGasPricePercentile.
+func (mr *MockTxRepositoryMockRecorder) GetGasPricePercentile(filter, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGasPricePercentile", reflect.TypeOf((*MockTxRepository)(nil).GetGasPricePercentile), filter, p)
+}
+
+// GetJobWatermark mocks base method.
+func (m *MockTxRepository) GetJobWatermark(job string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJobWatermark", job)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJobWatermark indicates an expected call of GetJobWatermark.
+func (mr *MockTxRepositoryMockRecorder) GetJobWatermark(job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJobWatermark", reflect.TypeOf((*MockTxRepository)(nil).GetJobWatermark), job)
+}
+
+// GetMaxBlockNum mocks base method.
+func (m *MockTxRepository) GetMaxBlockNum(symbol string) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMaxBlockNum", symbol)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMaxBlockNum indicates an expected call of GetMaxBlockNum.
+func (mr *MockTxRepositoryMockRecorder) GetMaxBlockNum(symbol interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMaxBlockNum", reflect.TypeOf((*MockTxRepository)(nil).GetMaxBlockNum), symbol)
+}
+
+// GetTrxFee mocks base method.
+func (m *MockTxRepository) GetTrxFee(txHash string) (*repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrxFee", txHash)
+	ret0, _ := ret[0].(*repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrxFee indicates an expected call of GetTrxFee.
+func (mr *MockTxRepositoryMockRecorder) GetTrxFee(txHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFee", reflect.TypeOf((*MockTxRepository)(nil).GetTrxFee), txHash)
+}
+
+// GetTrxFeeByID mocks base method.
+func (m *MockTxRepository) GetTrxFeeByID(id uint64) (*repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrxFeeByID", id)
+	ret0, _ := ret[0].(*repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrxFeeByID indicates an expected call of GetTrxFeeByID.
+func (mr *MockTxRepositoryMockRecorder) GetTrxFeeByID(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFeeByID", reflect.TypeOf((*MockTxRepository)(nil).GetTrxFeeByID), id)
+}
+
+// GetTrxFeeSeries mocks base method.
+func (m *MockTxRepository) GetTrxFeeSeries(filter repository.TrxFeeFilter, step int64) ([]repository.TrxFeeBucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrxFeeSeries", filter, step)
+	ret0, _ := ret[0].([]repository.TrxFeeBucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrxFeeSeries indicates an expected call of GetTrxFeeSeries.
+func (mr *MockTxRepositoryMockRecorder) GetTrxFeeSeries(filter, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFeeSeries", reflect.TypeOf((*MockTxRepository)(nil).GetTrxFeeSeries), filter, step)
+}
+
+// GetTrxFeeStats mocks base method.
+func (m *MockTxRepository) GetTrxFeeStats(filter repository.TrxFeeFilter) (*repository.TrxFeeStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrxFeeStats", filter)
+	ret0, _ := ret[0].(*repository.TrxFeeStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrxFeeStats indicates an expected call of GetTrxFeeStats.
+func (mr *MockTxRepositoryMockRecorder) GetTrxFeeStats(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrxFeeStats", reflect.TypeOf((*MockTxRepository)(nil).GetTrxFeeStats), filter)
+}
+
+// InsertDeadLetter mocks base method.
+func (m *MockTxRepository) InsertDeadLetter(batch *repository.DeadLetterBatch) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertDeadLetter", batch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertDeadLetter indicates an expected call of InsertDeadLetter.
+func (mr *MockTxRepositoryMockRecorder) InsertDeadLetter(batch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertDeadLetter", reflect.TypeOf((*MockTxRepository)(nil).InsertDeadLetter), batch)
+}
+
+// ListDeadLetters mocks base method.
+func (m *MockTxRepository) ListDeadLetters(status string, page, limit int) ([]repository.DeadLetterBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", status, page, limit)
+	ret0, _ := ret[0].([]repository.DeadLetterBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *MockTxRepositoryMockRecorder) ListDeadLetters(status, page, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*MockTxRepository)(nil).ListDeadLetters), status, page, limit)
+}
+
+// ListDueDeadLetters mocks base method.
+func (m *MockTxRepository) ListDueDeadLetters(now int64, limit int) ([]repository.DeadLetterBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDueDeadLetters", now, limit)
+	ret0, _ := ret[0].([]repository.DeadLetterBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDueDeadLetters indicates an expected call of ListDueDeadLetters.
+func (mr *MockTxRepositoryMockRecorder) ListDueDeadLetters(now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDueDeadLetters", reflect.TypeOf((*MockTxRepository)(nil).ListDueDeadLetters), now, limit)
+}
+
+// ListRollupHoursByBlock mocks base method.
+func (m *MockTxRepository) ListRollupHoursByBlock(symbol string, fromBlock, toBlock uint64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRollupHoursByBlock", symbol, fromBlock, toBlock)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRollupHoursByBlock indicates an expected call of ListRollupHoursByBlock.
+func (mr *MockTxRepositoryMockRecorder) ListRollupHoursByBlock(symbol, fromBlock, toBlock interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRollupHoursByBlock", reflect.TypeOf((*MockTxRepository)(nil).ListRollupHoursByBlock), symbol, fromBlock, toBlock)
+}
+
+// ListTrxFee mocks base method.
+func (m *MockTxRepository) ListTrxFee(query repository.TrxFeeListQuery) ([]repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFee", query)
+	ret0, _ := ret[0].([]repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFee indicates an expected call of ListTrxFee.
+func (mr *MockTxRepositoryMockRecorder) ListTrxFee(query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFee", reflect.TypeOf((*MockTxRepository)(nil).ListTrxFee), query)
+}
+
+// ListTrxFeeByHash mocks base method.
+func (m *MockTxRepository) ListTrxFeeByHash(txHashes []string) ([]repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeByHash", txHashes)
+	ret0, _ := ret[0].([]repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeByHash indicates an expected call of ListTrxFeeByHash.
+func (mr *MockTxRepositoryMockRecorder) ListTrxFeeByHash(txHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeByHash", reflect.TypeOf((*MockTxRepository)(nil).ListTrxFeeByHash), txHashes)
+}
+
+// ListTrxFeeCreatedAfter mocks base method.
+func (m *MockTxRepository) ListTrxFeeCreatedAfter(after repository.TrxFeeTimeCursor, filter repository.StreamFilter, limit int) ([]repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeCreatedAfter", after, filter, limit)
+	ret0, _ := ret[0].([]repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeCreatedAfter indicates an expected call of ListTrxFeeCreatedAfter.
+func (mr *MockTxRepositoryMockRecorder) ListTrxFeeCreatedAfter(after, filter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeCreatedAfter", reflect.TypeOf((*MockTxRepository)(nil).ListTrxFeeCreatedAfter), after, filter, limit)
+}
+
+// ListTrxFeeHoursModifiedAfter mocks base method.
+func (m *MockTxRepository) ListTrxFeeHoursModifiedAfter(symbol string, after, start, end int64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeHoursModifiedAfter", symbol, after, start, end)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeHoursModifiedAfter indicates an expected call of ListTrxFeeHoursModifiedAfter.
+func (mr *MockTxRepositoryMockRecorder) ListTrxFeeHoursModifiedAfter(symbol, after, start, end interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeHoursModifiedAfter", reflect.TypeOf((*MockTxRepository)(nil).ListTrxFeeHoursModifiedAfter), symbol, after, start, end)
+}
+
+// ListTrxFeeModifiedAfter mocks base method.
+func (m *MockTxRepository) ListTrxFeeModifiedAfter(after repository.TrxFeeTimeCursor, filter repository.StreamFilter, limit int) ([]repository.UniTrxFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeModifiedAfter", after, filter, limit)
+	ret0, _ := ret[0].([]repository.UniTrxFee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeModifiedAfter indicates an expected call of ListTrxFeeModifiedAfter.
+func (mr *MockTxRepositoryMockRecorder) ListTrxFeeModifiedAfter(after, filter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeModifiedAfter", reflect.TypeOf((*MockTxRepository)(nil).ListTrxFeeModifiedAfter), after, filter, limit)
+}
+
+// ListTrxFeeRollups mocks base method.
+func (m *MockTxRepository) ListTrxFeeRollups(period repository.RollupPeriod, symbol string, start, end int64) ([]repository.TrxFeeRollup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrxFeeRollups", period, symbol, start, end)
+	ret0, _ := ret[0].([]repository.TrxFeeRollup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrxFeeRollups indicates an expected call of ListTrxFeeRollups.
+func (mr *MockTxRepositoryMockRecorder) ListTrxFeeRollups(period, symbol, start, end interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFeeRollups", reflect.TypeOf((*MockTxRepository)(nil).ListTrxFeeRollups), period, symbol, start, end)
+}
+
+// RecordMaxBlockNum mocks base method.
+func (m *MockTxRepository) RecordMaxBlockNum(symbol string, maxBlock uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordMaxBlockNum", symbol, maxBlock)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordMaxBlockNum indicates an expected call of RecordMaxBlockNum.
+func (mr *MockTxRepositoryMockRecorder) RecordMaxBlockNum(symbol, maxBlock interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordMaxBlockNum", reflect.TypeOf((*MockTxRepository)(nil).RecordMaxBlockNum), symbol, maxBlock)
+}
+
+// SaveTrxFeeRollup mocks base method.
+func (m *MockTxRepository) SaveTrxFeeRollup(rollup *repository.TrxFeeRollup) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTrxFeeRollup", rollup)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTrxFeeRollup indicates an expected call of SaveTrxFeeRollup.
+func (mr *MockTxRepositoryMockRecorder) SaveTrxFeeRollup(rollup interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTrxFeeRollup", reflect.TypeOf((*MockTxRepository)(nil).SaveTrxFeeRollup), rollup)
+}
+
+// SetJobWatermark mocks base method.
+func (m *MockTxRepository) SetJobWatermark(job string, lastModified int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetJobWatermark", job, lastModified)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetJobWatermark indicates an expected call of SetJobWatermark.
+func (mr *MockTxRepositoryMockRecorder) SetJobWatermark(job, lastModified interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetJobWatermark", reflect.TypeOf((*MockTxRepository)(nil).SetJobWatermark), job, lastModified)
+}
+
+// UpdateDeadLetter mocks base method.
+func (m *MockTxRepository) UpdateDeadLetter(batch *repository.DeadLetterBatch) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeadLetter", batch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDeadLetter indicates an expected call of UpdateDeadLetter.
+func (mr *MockTxRepositoryMockRecorder) UpdateDeadLetter(batch interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrxFee", reflect.TypeOf((*MockRepository)(nil).ListTrxFee), symbol, startTime, endTime, page, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeadLetter", reflect.TypeOf((*MockTxRepository)(nil).UpdateDeadLetter), batch)
 }